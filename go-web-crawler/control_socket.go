@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenControl listens on a Unix socket at path, replacing a socket left behind by an earlier crawl
+func listenControl(path string) (net.Listener, error) {
+	//Check if a stale socket is in the way; other files are never removed
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	//Check if the socket could not be created
+	if err != nil {
+		return nil, fmt.Errorf("error creating control socket: %w", err)
+	}
+	return listener, nil
+}
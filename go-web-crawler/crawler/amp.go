@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ampPage is what the AMP check knows about a crawled document
+type ampPage struct {
+	status    int    //HTTP status code of the response, 0 if no response was received
+	amp       bool   //Whether the document is an AMP page, marked with <html amp> or <html ⚡>
+	canonical string //Canonical URL of the rel=canonical link without fragment, empty if there is none
+	ampHTML   string //Canonical URL of the rel=amphtml link without fragment, empty if there is none
+}
+
+// ampCheck verifies the links between AMP pages and their canonical pages: an AMP page must name
+// its canonical page with rel=canonical, and that page must point back with rel=amphtml. Like
+// fragments, pages are compared once the crawl ends, and pages that were not crawled are not
+// verified.
+type ampCheck struct {
+	mutex sync.Mutex          //Protects pages for concurrent workers
+	pages map[string]*ampPage //Crawled documents by URL without fragment
+}
+
+// newAMPCheck creates an empty AMP check
+func newAMPCheck() *ampCheck {
+	return &ampCheck{pages: make(map[string]*ampPage)}
+}
+
+// record stores a crawled document under the URL it was requested by and the URL it came from
+// after redirects; page is nil if the document could not be parsed
+func (a *ampCheck) record(pageURL string, result Result, page *Page) {
+	entry := &ampPage{status: result.Status}
+	//Check if the document was parsed
+	if page != nil {
+		entry.amp = page.AMP
+		//Check if the document names its canonical page
+		if page.Canonical != "" {
+			entry.canonical, _ = splitFragment(page.Canonical)
+		}
+		//Check if the document names an AMP version
+		if page.AMPURL != "" {
+			entry.ampHTML, _ = splitFragment(page.AMPURL)
+		}
+	}
+	requested, _ := splitFragment(pageURL)
+	final, _ := splitFragment(result.FinalURL)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.pages[requested], a.pages[final] = entry, entry
+}
+
+// broken returns an error for every AMP page without a canonical page, every broken link from an
+// AMP page to its canonical page or back, and every rel=amphtml link to a page that is not AMP
+func (a *ampCheck) broken() []error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	found := make(map[string]bool)
+	for link, page := range a.pages {
+		//Check if the page is an AMP page, whose canonical page must link back to it
+		if page.amp {
+			//Check if the page does not name its canonical page
+			if page.canonical == "" {
+				found[fmt.Sprintf("AMP page %s has no rel=canonical link", link)] = true
+			} else if target, ok := a.pages[page.canonical]; ok && page.canonical != link {
+				//Check if the canonical page could not be fetched
+				if target.status != 200 {
+					found[fmt.Sprintf("AMP page %s names canonical page %s, which returned status %d", link, page.canonical, target.status)] = true
+				} else if target.ampHTML != link {
+					found[fmt.Sprintf("canonical page %s of AMP page %s does not link back with rel=amphtml", page.canonical, link)] = true
+				}
+			}
+		}
+		target, ok := a.pages[page.ampHTML]
+		//Check if the page names an AMP version that was not crawled
+		if page.ampHTML == "" || !ok {
+			continue
+		}
+		//Check if the AMP version could not be fetched, is not AMP or names another canonical page; a missing one was reported above
+		if target.status != 200 {
+			found[fmt.Sprintf("rel=amphtml link of %s points at %s, which returned status %d", link, page.ampHTML, target.status)] = true
+		} else if !target.amp {
+			found[fmt.Sprintf("rel=amphtml link of %s points at %s, which is not an AMP page", link, page.ampHTML)] = true
+		} else if target.canonical != "" && target.canonical != link {
+			found[fmt.Sprintf("AMP page %s linked from %s with rel=amphtml names %q as its canonical page", page.ampHTML, link, target.canonical)] = true
+		}
+	}
+	errs := make([]string, 0, len(found))
+	for err := range found {
+		errs = append(errs, err)
+	}
+	sort.Strings(errs)
+	broken := make([]error, 0, len(errs))
+	for _, err := range errs {
+		broken = append(broken, errors.New(err))
+	}
+	return broken
+}
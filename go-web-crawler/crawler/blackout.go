@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones must resolve on hosts without a zoneinfo database
+)
+
+// BlackoutWindow is a recurring period during which the crawler pauses, e.g. business hours
+type BlackoutWindow struct {
+	Days     string `json:"days"`     //Weekdays such as "mon-fri" or "sat,sun", empty for every day
+	Start    string `json:"start"`    //Start time of day, "HH:MM"
+	End      string `json:"end"`      //End time of day, "HH:MM"; earlier than Start for windows past midnight
+	Timezone string `json:"timezone"` //IANA time zone such as "Europe/Berlin", empty for local time
+
+	days     [7]bool        //Weekdays on which the window starts
+	start    int            //Start in minutes after midnight
+	end      int            //End in minutes after midnight
+	location *time.Location //Time zone the times are given in
+}
+
+// weekdayNames maps three-letter day names to time.Weekday
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// compile parses the window's fields; it must succeed before active is used
+func (w *BlackoutWindow) compile() error {
+	var err error
+	//Check if the time zone is unknown
+	if w.location, err = time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+	}
+	for _, field := range []struct {
+		value string
+		dst   *int
+	}{{w.Start, &w.start}, {w.End, &w.end}} {
+		t, err := time.Parse("15:04", field.value)
+		//Check if the time of day is malformed
+		if err != nil {
+			return fmt.Errorf("invalid time %q (expected HH:MM)", field.value)
+		}
+		*field.dst = t.Hour()*60 + t.Minute()
+	}
+	//Check if the window is empty
+	if w.start == w.end {
+		return fmt.Errorf("start and end must differ")
+	}
+	//Check if the window applies to every day
+	if strings.TrimSpace(w.Days) == "" {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+	w.days = [7]bool{}
+	for _, part := range strings.Split(strings.ToLower(w.Days), ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, ok1 := weekdayNames[from]
+		last, ok2 := weekdayNames[to]
+		//Check if the part is a single day
+		if !isRange {
+			last, ok2 = first, ok1
+		}
+		//Check if the day names are unknown
+		if !ok1 || !ok2 {
+			return fmt.Errorf("invalid days %q (expected names like \"mon-fri\" or \"sat,sun\")", w.Days)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			//Check if the end of the range was reached
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// active reports whether t falls into the window and, if so, when the window ends
+func (w *BlackoutWindow) active(t time.Time) (bool, time.Time) {
+	local := t.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.location)
+	at := func(day time.Time, minutes int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, w.location)
+	}
+	today := local.Weekday()
+	//Check if the window lies within a single day
+	if w.start < w.end {
+		return w.days[today] && minute >= w.start && minute < w.end, at(midnight, w.end)
+	}
+	//Check if a window that started today runs past midnight
+	if w.days[today] && minute >= w.start {
+		return true, at(midnight.AddDate(0, 0, 1), w.end)
+	}
+	//Check if a window that started yesterday is still running
+	return w.days[(today+6)%7] && minute < w.end, at(midnight, w.end)
+}
+
+// blackoutCheckInterval is how often the crawler re-evaluates its blackout windows
+const blackoutCheckInterval = 15 * time.Second
+
+// updateBlackout opens or closes the pause gate when a blackout window starts or ends,
+// returning whether one is active now
+func (c *Crawler) updateBlackout(inBlackout bool) bool {
+	var until time.Time
+	active := false
+	for i := range c.blackouts {
+		//Check if the window is active and ends later than the others found so far
+		if ok, end := c.blackouts[i].active(time.Now()); ok && end.After(until) {
+			active, until = true, end
+		}
+	}
+	//Check if a blackout window started or ended
+	if active != inBlackout {
+		c.gate.setBlackout(active)
+		//Check which transition happened
+		if active {
+			c.logf(0, "blackout window started, pausing until %s", until.Format("Mon 15:04 MST"))
+		} else {
+			c.logf(0, "blackout window ended, resuming")
+		}
+	}
+	return active
+}
+
+// startBlackouts pauses the crawl while any blackout window is active until ctx is cancelled.
+// The windows are checked once before it returns, so no request slips out at the start of one.
+func (c *Crawler) startBlackouts(ctx context.Context) {
+	//Check if there are no blackout windows
+	if len(c.blackouts) == 0 {
+		return
+	}
+	inBlackout := c.updateBlackout(false)
+	go func() {
+		ticker := time.NewTicker(blackoutCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				inBlackout = c.updateBlackout(inBlackout)
+			}
+		}
+	}()
+}
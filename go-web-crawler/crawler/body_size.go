@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// sizeLimitedReader reads a body of unknown size up to a limit and notices if it goes on past it
+type sizeLimitedReader struct {
+	r        io.Reader //Body read
+	left     int64     //Bytes that may still be read
+	exceeded bool      //Set when the body had more bytes than the limit
+}
+
+// Read reads from the body until the limit, after which it reports the end of the body
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	//Check if the limit is reached, after which one more byte tells whether the body was cut
+	if l.left <= 0 {
+		var probe [1]byte
+		n, _ := io.ReadFull(l.r, probe[:])
+		l.exceeded = n > 0
+		return 0, io.EOF
+	}
+	//Check if the buffer reaches past the limit
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// fetchSample requests the first sampleSize bytes of a body over the size limit with a Range
+// request, so a huge document's head and early links are parsed without downloading all of it.
+// A server that ignores the range sends the whole body, of which only the sample is read.
+func (c *Crawler) fetchSample(ctx context.Context, pageURL, requestID string) (io.ReadCloser, error) {
+	//Wait for rate limiter to allow the request
+	if err := c.waitForTurn(ctx, pageURL); err != nil {
+		return nil, fmt.Errorf("rate limit error for %s: %v", pageURL, err)
+	}
+	req, err := c.newRequest(ctx, "GET", pageURL, requestID)
+	//Check if request creation failed
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", c.sampleSize-1))
+	resp, err := c.do(req)
+	//Check if the request failed
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sample of %s: %v", pageURL, abortCause(ctx, err))
+	}
+	//Check if the server sent neither the range nor the whole body
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching sample of %s: %s", pageURL, resp.Status)
+	}
+	c.logf(2, "[%s] GET %s (bytes 0-%d) -> %s", requestID, pageURL, c.sampleSize-1, resp.Status)
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, c.sampleSize), resp.Body}, nil
+}
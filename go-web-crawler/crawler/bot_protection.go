@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	botPeekBytes      = 32 << 10        // Body bytes inspected for challenge markers
+	botSlowdownFactor = 2               // Factor by which a host's spacing grows with each further challenge
+	botSlowdownMax    = 5 * time.Minute // Longest spacing between requests to a challenging host
+)
+
+// botMarkers are lower-case body fragments of challenge pages, CAPTCHAs and JavaScript interstitials
+// by the protection they identify. They are only looked for in error responses, since several of
+// them, such as CAPTCHA widgets, also appear on ordinary pages.
+var botMarkers = []struct {
+	text       string //Fragment of the challenge page
+	protection string //Protection reported for it
+}{
+	{"cf-browser-verification", "cloudflare"},
+	{"cf_chl_opt", "cloudflare"},
+	{"/cdn-cgi/challenge-platform/", "cloudflare"},
+	{"attention required! | cloudflare", "cloudflare"},
+	{"_incapsula_resource", "imperva"},
+	{"px-captcha", "perimeterx"},
+	{"captcha-delivery.com", "datadome"},
+	{"sucuri website firewall", "sucuri"},
+	{"ddos protection by", "ddos-guard"},
+	{"challenges.cloudflare.com/turnstile", "captcha"},
+	{"g-recaptcha", "captcha"},
+	{"h-captcha", "captcha"},
+	{"hcaptcha.com", "captcha"},
+	{"verify you are human", "captcha"},
+	{"just a moment...", "js-interstitial"},
+	{"checking your browser", "js-interstitial"},
+	{"please enable javascript and cookies", "js-interstitial"},
+}
+
+// botChallenge identifies a bot-protection challenge served instead of a page and returns the
+// protection, such as "cloudflare", "akamai" or "captcha", or "" for an ordinary response. Successful
+// responses only count when a header marks them as a challenge; error responses are also
+// recognized by the server and the start of their body.
+func botChallenge(status int, header http.Header, prefix []byte) string {
+	//Check if Cloudflare marked the response as a challenge, which it does for any status
+	if strings.EqualFold(header.Get("Cf-Mitigated"), "challenge") {
+		return "cloudflare"
+	}
+	//Check if the response is an error that may be a block page
+	if status != http.StatusForbidden && status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		return ""
+	}
+	body := bytes.ToLower(prefix)
+	for _, marker := range botMarkers {
+		//Check if the body contains the marker
+		if bytes.Contains(body, []byte(marker.text)) {
+			return marker.protection
+		}
+	}
+	server := strings.ToLower(header.Get("Server"))
+	switch {
+	case header.Get("X-Datadome") != "":
+		return "datadome"
+	case strings.Contains(server, "akamaighost") && status == http.StatusForbidden:
+		return "akamai"
+	case strings.Contains(server, "cloudflare") && status == http.StatusForbidden:
+		return "cloudflare"
+	}
+	return ""
+}
+
+// detectBotChallenge inspects a response for a bot-protection challenge. The inspected start of
+// the body stays readable.
+func detectBotChallenge(resp *http.Response) string {
+	var prefix []byte
+	//Check if the body has to be inspected too
+	if resp.StatusCode != http.StatusOK && resp.Body != nil && resp.Body != http.NoBody {
+		prefix, _ = io.ReadAll(io.LimitReader(resp.Body, botPeekBytes))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+	}
+	return botChallenge(resp.StatusCode, resp.Header, prefix)
+}
+
+// hostThrottle spaces out the requests to hosts that served bot challenges
+type hostThrottle struct {
+	mutex    sync.Mutex               //Protects limiters
+	initial  time.Duration            //Spacing after a host's first challenge, 0 to never slow down
+	limiters map[string]*rate.Limiter //Limiters of slowed hosts by lower-case host
+}
+
+// newHostThrottle creates a throttle that spaces a host's requests by interval after its first challenge
+func newHostThrottle(interval time.Duration) *hostThrottle {
+	return &hostThrottle{initial: interval, limiters: make(map[string]*rate.Limiter)}
+}
+
+// slow spaces the host's requests by the initial interval after its first challenge, growing the
+// spacing with each further one, and returns the new spacing
+func (t *hostThrottle) slow(host string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	host = strings.ToLower(host)
+	limiter, ok := t.limiters[host]
+	//Check if this is the host's first challenge
+	if !ok {
+		t.limiters[host] = rate.NewLimiter(rate.Every(t.initial), 1)
+		return t.initial
+	}
+	interval := min(time.Duration(float64(time.Second)/float64(limiter.Limit()))*botSlowdownFactor, botSlowdownMax)
+	limiter.SetLimit(rate.Every(interval))
+	return interval
+}
+
+// wait blocks until a request to the link's host is allowed, returning at once for hosts that were not slowed
+func (t *hostThrottle) wait(ctx context.Context, link string) error {
+	u, err := url.Parse(link)
+	//Check if the link has no host to throttle
+	if err != nil {
+		return nil
+	}
+	t.mutex.Lock()
+	limiter := t.limiters[strings.ToLower(u.Host)]
+	t.mutex.Unlock()
+	//Check if the host was not slowed
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+// reportBotChallenge marks a result as blocked by bot protection and slows down its host if -bot-slowdown is set
+func (c *Crawler) reportBotChallenge(result *Result, protection string, host string) {
+	result.Blocked = protection
+	result.Class = ClassFailure
+	//Check if challenging hosts are not slowed down
+	if c.botThrottle == nil {
+		c.logf(0, "[%s] %s is blocked by bot protection (%s)", result.RequestID, result.URL, protection)
+		return
+	}
+	interval := c.botThrottle.slow(host)
+	c.logf(0, "[%s] %s is blocked by bot protection (%s); slowing %s to one request per %s",
+		result.RequestID, result.URL, protection, host, interval)
+}
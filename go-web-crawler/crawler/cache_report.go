@@ -0,0 +1,296 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheHeaders are the response headers a CacheReport needs in the results, see Options.RecordHeaders
+var CacheHeaders = []string{"cache-control", "expires", "age", "date", "pragma", "x-cache", "cf-cache-status", "x-cache-status"}
+
+// cdnStatusHeaders are headers through which CDNs report whether a response was served from cache
+var cdnStatusHeaders = []string{"cf-cache-status", "x-cache-status", "x-cache"}
+
+// Cache issue names used by the cache report
+const (
+	issueNoPolicy    = "no cache headers"
+	issueUncacheable = "not cacheable"
+	issueConflicting = "conflicting directives"
+	issueShortTTL    = "short TTL"
+)
+
+// cacheIssueOrder is the order in which issues are listed in the report
+var cacheIssueOrder = []string{issueNoPolicy, issueUncacheable, issueConflicting, issueShortTTL}
+
+// cacheAnalysis is the caching behaviour of one response
+type cacheAnalysis struct {
+	ttl       time.Duration //Freshness lifetime granted to shared caches, 0 if none
+	issues    []string      //Problems found, e.g. issueShortTTL
+	details   []string      //Explanation for each issue, in the same order
+	cdnStatus string        //Upper-case first word of the CDN cache status, e.g. "HIT", or ""
+}
+
+// analyzeCaching inspects the cache headers of a response, flagging TTLs shorter than minTTL
+func analyzeCaching(headers map[string]string, minTTL time.Duration) cacheAnalysis {
+	var a cacheAnalysis
+	flag := func(issue, detail string) {
+		a.issues = append(a.issues, issue)
+		a.details = append(a.details, detail)
+	}
+	//Check if a CDN reported its cache status
+	for _, name := range cdnStatusHeaders {
+		//Check if the header is present
+		if value := strings.Fields(headers[name]); len(value) > 0 {
+			a.cdnStatus = strings.ToUpper(value[0])
+			break
+		}
+	}
+
+	directives := parseCacheControl(headers["cache-control"])
+	expires, hasExpires := headers["expires"]
+	//Check if the response carries no caching policy at all
+	if headers["cache-control"] == "" && !hasExpires {
+		flag(issueNoPolicy, "no Cache-Control or Expires")
+		return a
+	}
+
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	_, private := directives["private"]
+	_, public := directives["public"]
+	maxAge, hasMaxAge := directiveSeconds(directives, "max-age")
+	sMaxAge, hasSMaxAge := directiveSeconds(directives, "s-maxage")
+
+	// Work out the freshness lifetime the way a shared cache would
+	switch {
+	case noStore || noCache || private:
+		a.ttl = 0 // Shared caches must not serve the response without revalidating
+	case hasSMaxAge:
+		a.ttl = sMaxAge
+	case hasMaxAge:
+		a.ttl = maxAge
+	case hasExpires:
+		a.ttl = expiresTTL(expires, headers["date"])
+	}
+
+	//Check for directives that contradict each other
+	switch {
+	case noStore && (hasMaxAge && maxAge > 0 || hasSMaxAge && sMaxAge > 0):
+		flag(issueConflicting, "no-store with a positive max-age")
+	case noStore && public:
+		flag(issueConflicting, "no-store with public")
+	case private && public:
+		flag(issueConflicting, "private with public")
+	case directives["max-age"] == "conflict":
+		flag(issueConflicting, "multiple different max-age values")
+	case (noStore || noCache) && hasExpires && expiresTTL(expires, headers["date"]) > 0:
+		flag(issueConflicting, "no-store/no-cache with a future Expires")
+	case strings.Contains(strings.ToLower(headers["pragma"]), "no-cache") && a.ttl > 0:
+		flag(issueConflicting, "Pragma: no-cache with a positive TTL")
+	}
+
+	//Check if shared caches may not store the response
+	if a.ttl == 0 {
+		reason := "zero or expired freshness lifetime"
+		//Check which directive prevents caching
+		switch {
+		case noStore:
+			reason = "no-store"
+		case noCache:
+			reason = "no-cache"
+		case private:
+			reason = "private"
+		}
+		flag(issueUncacheable, reason)
+	} else if a.ttl < minTTL {
+		flag(issueShortTTL, fmt.Sprintf("TTL %s", a.ttl))
+	}
+	return a
+}
+
+// parseCacheControl splits a Cache-Control header into lower-case directives and their values.
+// A directive repeated with different values is marked with the value "conflict".
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		//Check if the part is empty
+		if name == "" {
+			continue
+		}
+		//Check if the directive was already given with another value
+		if previous, ok := directives[name]; ok && previous != value {
+			value = "conflict"
+		}
+		directives[name] = value
+	}
+	return directives
+}
+
+// directiveSeconds returns the duration of a numeric directive such as max-age
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(directives[name])
+	//Check if the directive is missing or not a number
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// expiresTTL returns how far Expires lies after Date, or after now if Date is missing; invalid dates count as expired
+func expiresTTL(expires, date string) time.Duration {
+	expiresAt, err := http.ParseTime(expires)
+	//Check if the expiry date is invalid, which HTTP defines as already expired
+	if err != nil {
+		return 0
+	}
+	now := time.Now()
+	//Check if the response carries its own reference time
+	if sent, err := http.ParseTime(date); err == nil {
+		now = sent
+	}
+	//Check if the expiry date already passed
+	if ttl := expiresAt.Sub(now); ttl > 0 {
+		return ttl.Round(time.Second)
+	}
+	return 0
+}
+
+// pathIDPattern matches path segments that are identifiers rather than part of the site structure
+var pathIDPattern = regexp.MustCompile(`^(\d+|[0-9a-fA-F-]{16,})$`)
+
+// pathPattern groups a URL by its directory, with numeric and hex identifiers collapsed, e.g.
+// "/products/123/reviews.html" becomes "/products/{id}/*"
+func pathPattern(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	//Check if the URL cannot be parsed
+	if err != nil {
+		return rawURL
+	}
+	segments := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	//Check if the URL is the site root
+	if len(segments) == 1 && segments[0] == "" {
+		return "/"
+	}
+	dirs := segments[:len(segments)-1]
+	for i, segment := range dirs {
+		//Check if the segment is an identifier
+		if pathIDPattern.MatchString(segment) {
+			dirs[i] = "{id}"
+		}
+	}
+	//Check if the URL is at the top level
+	if len(dirs) == 0 {
+		return "/*"
+	}
+	return "/" + strings.Join(dirs, "/") + "/*"
+}
+
+// cacheGroup collects the cache analysis of the pages sharing one path pattern
+type cacheGroup struct {
+	pages    int                 //Pages analyzed
+	issues   map[string]int      //Pages per issue
+	examples map[string][]string //Up to cacheReportExamples example lines per issue
+	cdn      map[string]int      //Pages per CDN cache status
+	ttls     []time.Duration     //TTL of every page, for the median
+}
+
+// cacheReportExamples is the number of example URL's listed per issue and path pattern
+const cacheReportExamples = 3
+
+// CacheReport aggregates the caching behaviour of successful pages by path pattern
+type CacheReport struct {
+	minTTL time.Duration          //TTLs below this are reported as short
+	groups map[string]*cacheGroup //Groups by path pattern
+}
+
+// NewCacheReport creates an empty report flagging TTLs below minTTL
+func NewCacheReport(minTTL time.Duration) *CacheReport {
+	return &CacheReport{minTTL: minTTL, groups: make(map[string]*cacheGroup)}
+}
+
+// Add analyzes a result; only successful responses are considered
+func (r *CacheReport) Add(result Result) {
+	//Check if the page was served successfully
+	if result.Status < 200 || result.Status >= 300 {
+		return
+	}
+	pattern := pathPattern(result.URL)
+	group, ok := r.groups[pattern]
+	//Check if this is the first page of the pattern
+	if !ok {
+		group = &cacheGroup{issues: make(map[string]int), examples: make(map[string][]string), cdn: make(map[string]int)}
+		r.groups[pattern] = group
+	}
+	a := analyzeCaching(result.Headers, r.minTTL)
+	group.pages++
+	group.ttls = append(group.ttls, a.ttl)
+	//Check if a CDN status was reported
+	if a.cdnStatus != "" {
+		group.cdn[a.cdnStatus]++
+	}
+	for i, issue := range a.issues {
+		group.issues[issue]++
+		//Check if more examples are wanted for the issue
+		if len(group.examples[issue]) < cacheReportExamples {
+			group.examples[issue] = append(group.examples[issue], fmt.Sprintf("%s (%s)", result.URL, a.details[i]))
+		}
+	}
+}
+
+// Print writes the report, patterns with the most affected pages first
+func (r *CacheReport) Print(w io.Writer) error {
+	patterns := make([]string, 0, len(r.groups))
+	affected := make(map[string]int, len(r.groups))
+	for pattern, group := range r.groups {
+		patterns = append(patterns, pattern)
+		for _, n := range group.issues {
+			affected[pattern] += n
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		//Check if the patterns have as many issues
+		if affected[patterns[i]] == affected[patterns[j]] {
+			return patterns[i] < patterns[j]
+		}
+		return affected[patterns[i]] > affected[patterns[j]]
+	})
+
+	fmt.Fprintf(w, "Cache report (%d path patterns, short TTL below %s)\n", len(patterns), r.minTTL)
+	for _, pattern := range patterns {
+		group := r.groups[pattern]
+		sort.Slice(group.ttls, func(i, j int) bool { return group.ttls[i] < group.ttls[j] })
+		fmt.Fprintf(w, "\n%s: %d pages, median TTL %s", pattern, group.pages, group.ttls[len(group.ttls)/2])
+		//Check if any CDN statuses were seen
+		if len(group.cdn) > 0 {
+			statuses := make([]string, 0, len(group.cdn))
+			for status, n := range group.cdn {
+				statuses = append(statuses, fmt.Sprintf("%s %d", status, n))
+			}
+			sort.Strings(statuses)
+			fmt.Fprintf(w, ", CDN %s", strings.Join(statuses, ", "))
+		}
+		fmt.Fprintln(w)
+		for _, issue := range cacheIssueOrder {
+			//Check if any page of the pattern has the issue
+			if group.issues[issue] == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "  %s: %d\n", issue, group.issues[issue])
+			for _, example := range group.examples[issue] {
+				fmt.Fprintf(w, "    %s\n", example)
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
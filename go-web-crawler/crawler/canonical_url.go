@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// canonicalizeURL rewrites equivalent spellings of a URL's path and query to one form, so
+// /caf%c3%a9, /caf%C3%A9 and /café are deduplicated as the same URL. Percent-encoded unreserved
+// characters are decoded, other escapes get upper-case hex digits, and non-ASCII characters are
+// escaped after applying Unicode NFC to web paths. Local file paths are not normalized to NFC,
+// since file names are byte strings that may be stored in any form. The fragment is dropped, as
+// /page#a and /page#b are the same document; links keep it for -check-fragments.
+func canonicalizeURL(u *url.URL) {
+	u.Fragment, u.RawFragment = "", ""
+	//Check if the path is already canonical, which is the case for most URL's
+	if path := canonicalEscapes(u.EscapedPath(), u.Scheme != "file"); path != u.EscapedPath() {
+		//Check if the canonical path decodes cleanly, which it does unless the URL was malformed
+		if decoded, err := url.PathUnescape(path); err == nil {
+			u.Path, u.RawPath = decoded, path
+		}
+	}
+	u.RawQuery = canonicalEscapes(u.RawQuery, false)
+}
+
+// canonicalLink returns the canonical form of an absolute link, or the link unchanged if it does not parse
+func canonicalLink(link string) string {
+	parsed, err := url.Parse(link)
+	//Check if the link could not be parsed
+	if err != nil {
+		return link
+	}
+	canonicalizeURL(parsed)
+	return parsed.String()
+}
+
+// canonicalEscapes normalizes the percent-encoding of an escaped URL component, optionally
+// applying Unicode NFC to its characters
+func canonicalEscapes(escaped string, nfc bool) string {
+	//Check if the component has nothing to normalize
+	if !strings.Contains(escaped, "%") && isASCII(escaped) {
+		return escaped
+	}
+	// Decode unreserved and non-ASCII escapes, keeping reserved ones as upper-case escapes
+	var decoded strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		//Check if the byte starts a well-formed escape
+		if escaped[i] != '%' || i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			decoded.WriteByte(escaped[i])
+			continue
+		}
+		b := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+		//Check if the escaped byte must stay escaped
+		if b < utf8.RuneSelf && !isUnreserved(b) {
+			decoded.WriteString("%" + strings.ToUpper(escaped[i+1:i+3]))
+		} else {
+			decoded.WriteByte(b)
+		}
+		i += 2
+	}
+	text := decoded.String()
+	//Check if the characters should be normalized, which is only safe for valid UTF-8
+	if nfc && utf8.ValidString(text) {
+		text = norm.NFC.String(text)
+	}
+	// Escape non-ASCII bytes again
+	var result strings.Builder
+	for i := 0; i < len(text); i++ {
+		//Check if the byte is ASCII
+		if text[i] < utf8.RuneSelf {
+			result.WriteByte(text[i])
+			continue
+		}
+		const hexDigits = "0123456789ABCDEF"
+		result.WriteByte('%')
+		result.WriteByte(hexDigits[text[i]>>4])
+		result.WriteByte(hexDigits[text[i]&15])
+	}
+	return result.String()
+}
+
+// isUnreserved reports whether an ASCII byte is an RFC 3986 unreserved character, which never needs escaping
+func isUnreserved(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '-' || b == '.' || b == '_' || b == '~'
+}
+
+// isASCII reports whether s contains only ASCII bytes
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		//Check if the byte is outside ASCII
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isHex reports whether b is a hexadecimal digit
+func isHex(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit
+func unhex(b byte) byte {
+	switch {
+	case b >= 'a':
+		return b - 'a' + 10
+	case b >= 'A':
+		return b - 'A' + 10
+	}
+	return b - '0'
+}
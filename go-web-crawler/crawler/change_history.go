@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pageHistory is what the change history knows about one URL from earlier crawls
+type pageHistory struct {
+	Hash        string    `json:"hash"`         //SHA-256 of the body at the last check
+	FirstSeen   time.Time `json:"first_seen"`   //When the URL was first fetched
+	LastChecked time.Time `json:"last_checked"` //When the URL was last fetched
+	Checks      int       `json:"checks"`       //Number of crawls that fetched the URL
+	Changes     int       `json:"changes"`      //Number of checks whose body differed from the one before
+}
+
+// changeEvery estimates the mean time between changes of the page, 0 if it never changed
+func (p *pageHistory) changeEvery() time.Duration {
+	//Check if no change was observed yet
+	if p.Changes == 0 {
+		return 0
+	}
+	return p.LastChecked.Sub(p.FirstSeen) / time.Duration(p.Changes)
+}
+
+// changesPerDay estimates how often the page changes, smoothed so pages seen once or never rank
+// like pages that change daily until their history says otherwise
+func (p *pageHistory) changesPerDay() float64 {
+	days := p.LastChecked.Sub(p.FirstSeen).Hours() / 24
+	return float64(p.Changes+1) / (days + 1)
+}
+
+// changeHistory tracks the content hashes of pages across crawls in a JSON file, to estimate how
+// often each page changes
+type changeHistory struct {
+	mutex sync.Mutex              //Protects pages
+	path  string                  //File the history is loaded from and saved to
+	pages map[string]*pageHistory //History by URL
+}
+
+// loadChangeHistory reads the history file at path; a missing file starts an empty history
+func loadChangeHistory(path string) (*changeHistory, error) {
+	h := &changeHistory{path: path, pages: make(map[string]*pageHistory)}
+	data, err := os.ReadFile(path)
+	//Check if there is no history yet
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	//Check if the history could not be read
+	if err != nil {
+		return nil, fmt.Errorf("error reading change history: %w", err)
+	}
+	//Check if the history is not valid JSON
+	if err := json.Unmarshal(data, &h.pages); err != nil {
+		return nil, fmt.Errorf("error reading change history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// observe records the body hash of a fetched URL and returns the page's estimated change interval
+func (h *changeHistory) observe(link, sum string) time.Duration {
+	now := time.Now()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	page, ok := h.pages[link]
+	//Check if the URL is fetched for the first time
+	if !ok {
+		page = &pageHistory{FirstSeen: now}
+		h.pages[link] = page
+	} else if page.Hash != sum {
+		page.Changes++
+	}
+	page.Hash, page.LastChecked = sum, now
+	page.Checks++
+	return page.changeEvery()
+}
+
+// prioritizer returns a Prioritizer crawling frequently changing pages first, so a limited
+// -max-visited budget is spent where content is most likely to be new
+func (h *changeHistory) prioritizer() Prioritizer {
+	return func(link string, depth int, meta LinkMeta) float64 {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		page, ok := h.pages[link]
+		//Check if the URL was never fetched, which makes it as interesting as a page changing daily
+		if !ok {
+			return 1
+		}
+		return page.changesPerDay()
+	}
+}
+
+// save writes the history back to its file, replacing it atomically
+func (h *changeHistory) save() error {
+	h.mutex.Lock()
+	data, err := json.Marshal(h.pages)
+	h.mutex.Unlock()
+	//Check if the history could not be encoded
+	if err != nil {
+		return fmt.Errorf("error encoding change history: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(h.path), "tmp-*")
+	//Check if the temporary file could not be created
+	if err != nil {
+		return fmt.Errorf("error writing change history: %w", err)
+	}
+	_, err = file.Write(data)
+	//Check if writing or closing the file failed
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	//Check if the file is complete and can replace the old history
+	if err == nil {
+		err = os.Rename(file.Name(), h.path)
+	}
+	//Check if the history could not be saved
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error writing change history: %w", err)
+	}
+	return nil
+}
+
+// hashedBody returns a reader of a response body that hashes what is read, and a function that
+// reads the rest of the body, if any, and records the hash in the result and change history.
+// Without a change history the body is returned as is.
+func (c *Crawler) hashedBody(body io.Reader) (io.Reader, func(*Result)) {
+	//Check if content changes are not tracked
+	if c.changes == nil {
+		return body, func(*Result) {}
+	}
+	var hasher hash.Hash = sha256.New()
+	tee := io.TeeReader(body, hasher)
+	return tee, func(result *Result) {
+		//Check if the rest of the body could not be read, which leaves the hash incomplete
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return
+		}
+		result.ContentHash = hex.EncodeToString(hasher.Sum(nil))
+		result.ChangeEvery = c.changes.observe(result.URL, result.ContentHash)
+	}
+}
@@ -0,0 +1,61 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// commonPaths are index documents that many sites serve without linking to them
+var commonPaths = []string{"/sitemap.xml", "/sitemap_index.xml", "/feed", "/archive"}
+
+// pathDiscovery remembers the hosts whose common paths were probed
+type pathDiscovery struct {
+	mutex sync.Mutex      //Protects hosts
+	hosts map[string]bool //Probed hosts by lower-case scheme and host
+}
+
+// newPathDiscovery creates a discovery that has probed no host yet
+func newPathDiscovery() *pathDiscovery {
+	return &pathDiscovery{hosts: make(map[string]bool)}
+}
+
+// discoverPaths probes the common paths of a page's host the first time one of its pages is
+// crawled, and queues those that exist at seed depth, so a site with poor internal linking is
+// still covered through its sitemap, feed or archive. Probes use HEAD, so missing paths are
+// neither downloaded nor reported.
+func (c *Crawler) discoverPaths(ctx context.Context, page *url.URL, meta LinkMeta) {
+	//Check if common paths are not probed, or the page is not on a web server
+	if c.discovery == nil || (page.Scheme != "http" && page.Scheme != "https") || c.simulated {
+		return
+	}
+	origin := strings.ToLower(page.Scheme + "://" + page.Host)
+	c.discovery.mutex.Lock()
+	probed := c.discovery.hosts[origin]
+	c.discovery.hosts[origin] = true
+	c.discovery.mutex.Unlock()
+	//Check if the host was probed already
+	if probed {
+		return
+	}
+	for _, path := range commonPaths {
+		link := origin + path
+		probe, err := url.Parse(link)
+		//Check if robots.txt disallows the path
+		if err != nil || !c.robotsAllowed(ctx, probe) {
+			continue
+		}
+		result, err := c.checkURL(ctx, link)
+		//Check if the path does not exist on the host
+		if err != nil || result.Status != http.StatusOK {
+			c.logf(2, "no %s on %s", path, page.Host)
+			continue
+		}
+		//Check if the path was queued, unless it is known already
+		if c.enqueue(link, 1, LinkMeta{Seed: meta.Seed}) {
+			c.logf(1, "discovered %s", link)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Config holds crawl settings loaded from a JSON config file
+type Config struct {
+	MaxDepth      int                     `json:"max_depth"`      //Default max depth, 0 keeps the built-in default
+	MaxVisited    int                     `json:"max_visited"`    //Default max visited URL's, 0 keeps the built-in default
+	Flags         map[string]interface{}  `json:"flags"`          //Default values for command-line flags, keyed by flag name
+	PathRules     []PathRule              `json:"path_rules"`     //Per-path depth and budget overrides
+	QueryRules    []QueryRule             `json:"query_params"`   //Per-parameter crawl policies by host and path
+	Seeds         []Seed                  `json:"seeds"`          //Further start URL's with their own settings
+	StatusClasses *StatusClasses          `json:"status_classes"` //Which status codes count as success, warning or failure
+	Credentials   map[string]*Credential  `json:"credentials"`    //Credentials by host, used to answer 401 challenges
+	Blackouts     []BlackoutWindow        `json:"blackouts"`      //Recurring windows during which crawling pauses
+	Negotiation   map[string]*Negotiation `json:"negotiation"`    //Accept and Accept-Language overrides by host
+	URLPatterns   []string                `json:"url_patterns"`   //URL templates such as "/blog/:slug" that -pattern-report groups by before inferring any
+	Profiles      map[string]*Config      `json:"profiles"`       //Named overlays selected with -profile
+}
+
+// PathRule overrides the crawl depth and page budget for URLs under a path prefix
+type PathRule struct {
+	Prefix   string `json:"prefix"`    //Path prefix the rule applies to, e.g. "/docs/"
+	MaxDepth int    `json:"max_depth"` //Maximum crawl depth under the prefix, 0 keeps the global depth
+	MaxPages int    `json:"max_pages"` //Maximum pages visited under the prefix, 0 means unlimited
+}
+
+// LoadConfig reads and validates a JSON config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	//Check if the config file could not be read
+	if err != nil {
+		return nil, fmt.Errorf("error reading config %s: %w", path, err)
+	}
+	var cfg Config
+	//Check if the config file is not valid JSON
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config %s: %w", path, err)
+	}
+	//Check if the top-level settings are invalid
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	for name, profile := range cfg.Profiles {
+		//Check if the profile is empty or nests further profiles
+		if profile == nil || len(profile.Profiles) > 0 {
+			return nil, fmt.Errorf("config %s: profile %q must be an object without nested profiles", path, name)
+		}
+		//Check if the profile settings are invalid
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("config %s: profile %q: %w", path, name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// validate checks the settings of a config or profile
+func (cfg *Config) validate() error {
+	//Check if the crawl limits are negative
+	if cfg.MaxDepth < 0 || cfg.MaxVisited < 0 {
+		return fmt.Errorf("max_depth and max_visited must not be negative")
+	}
+	//Check if the path rules are invalid
+	if err := validatePathRules(cfg.PathRules); err != nil {
+		return err
+	}
+	for i := range cfg.Seeds {
+		//Check if the seed is invalid
+		if err := cfg.Seeds[i].validate(); err != nil {
+			return fmt.Errorf("seed %d: %w", i, err)
+		}
+	}
+	for host, cred := range cfg.Credentials {
+		//Check if the credential is missing or incomplete
+		if cred == nil {
+			return fmt.Errorf("credentials for %q must be an object", host)
+		}
+		//Check if the credential is invalid
+		if err := cred.validate(); err != nil {
+			return fmt.Errorf("credentials for %q: %w", host, err)
+		}
+	}
+	for i := range cfg.QueryRules {
+		//Check if the query parameter rule is invalid
+		if err := cfg.QueryRules[i].validate(); err != nil {
+			return fmt.Errorf("query_params rule %d: %w", i, err)
+		}
+	}
+	for host, negotiation := range cfg.Negotiation {
+		//Check if the overrides are not an object
+		if negotiation == nil {
+			return fmt.Errorf("negotiation for %q must be an object", host)
+		}
+	}
+	for i := range cfg.Blackouts {
+		//Check if the blackout window is invalid
+		if err := cfg.Blackouts[i].compile(); err != nil {
+			return fmt.Errorf("blackout window %d: %w", i, err)
+		}
+	}
+	//Check if a URL template is invalid
+	if _, err := parseURLPatterns(cfg.URLPatterns); err != nil {
+		return err
+	}
+	//Check if the status classes are malformed or overlap
+	if _, err := newStatusClassifier(cfg.StatusClasses); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validatePathRules checks the prefixes and limits of path rules
+func validatePathRules(rules []PathRule) error {
+	for i, rule := range rules {
+		//Check if the rule has no usable prefix
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return fmt.Errorf("path rule %d: prefix %q must start with \"/\"", i, rule.Prefix)
+		}
+		//Check if the rule limits are negative
+		if rule.MaxDepth < 0 || rule.MaxPages < 0 {
+			return fmt.Errorf("path rule %q: max_depth and max_pages must not be negative", rule.Prefix)
+		}
+	}
+	return nil
+}
+
+// Profile returns the top-level settings with the named profile layered on top.
+// An empty name returns the top-level settings unchanged.
+func (cfg *Config) Profile(name string) (*Config, error) {
+	merged := *cfg
+	merged.Profiles = nil
+	merged.Credentials = mergeCredentials(cfg.Credentials)
+	merged.Negotiation = mergeNegotiation(cfg.Negotiation)
+	//Check if no profile was selected
+	if name == "" {
+		return &merged, nil
+	}
+	profile, ok := cfg.Profiles[name]
+	//Check if the profile is not defined
+	if !ok {
+		names := make([]string, 0, len(cfg.Profiles))
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	//Check if the profile overrides the max depth
+	if profile.MaxDepth > 0 {
+		merged.MaxDepth = profile.MaxDepth
+	}
+	//Check if the profile overrides the max visited URL's
+	if profile.MaxVisited > 0 {
+		merged.MaxVisited = profile.MaxVisited
+	}
+	//Check if the profile replaces the path rules
+	if len(profile.PathRules) > 0 {
+		merged.PathRules = profile.PathRules
+	}
+	//Check if the profile replaces the seeds
+	if len(profile.Seeds) > 0 {
+		merged.Seeds = profile.Seeds
+	}
+	//Check if the profile replaces the query parameter rules
+	if len(profile.QueryRules) > 0 {
+		merged.QueryRules = profile.QueryRules
+	}
+	//Check if the profile replaces the status classes
+	if profile.StatusClasses != nil {
+		merged.StatusClasses = profile.StatusClasses
+	}
+	//Check if the profile replaces the blackout windows
+	if len(profile.Blackouts) > 0 {
+		merged.Blackouts = profile.Blackouts
+	}
+	//Check if the profile replaces the URL templates
+	if len(profile.URLPatterns) > 0 {
+		merged.URLPatterns = profile.URLPatterns
+	}
+	merged.Credentials = mergeCredentials(cfg.Credentials, profile.Credentials)
+	merged.Negotiation = mergeNegotiation(cfg.Negotiation, profile.Negotiation)
+	merged.Flags = make(map[string]interface{}, len(cfg.Flags)+len(profile.Flags))
+	for key, value := range cfg.Flags {
+		merged.Flags[key] = value
+	}
+	for key, value := range profile.Flags {
+		merged.Flags[key] = value
+	}
+	return &merged, nil
+}
+
+// ApplyFlags sets command-line flags from the config's flag defaults, skipping flags given explicitly
+func (cfg *Config) ApplyFlags(flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range cfg.Flags {
+		//Check if the flag was set on the command line, which takes precedence
+		if explicit[name] {
+			continue
+		}
+		//Check if the flag cannot be set from the config
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("flag %q cannot be set from the config file", name)
+		}
+		//Check if the flag does not exist or rejects the value
+		if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid value %v for flag %q: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// matchPathRule returns the rule with the longest prefix matching the path, or nil if none applies
+func matchPathRule(rules []PathRule, path string) *PathRule {
+	var best *PathRule
+	for i := range rules {
+		//Check if the rule covers the path and is more specific than the current match
+		if strings.HasPrefix(path, rules[i].Prefix) && (best == nil || len(rules[i].Prefix) > len(best.Prefix)) {
+			best = &rules[i]
+		}
+	}
+	return best
+}
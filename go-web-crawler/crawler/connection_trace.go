@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+)
+
+// cdnMarkers are response headers that reveal the CDN or edge network serving a response, in the
+// order they are checked. An empty value matches any value of the header.
+var cdnMarkers = []struct {
+	header string //Canonical header name
+	value  string //Lower-case fragment of the header's value, empty for any
+	cdn    string //CDN reported for it
+}{
+	{"Cf-Ray", "", "cloudflare"},
+	{"X-Amz-Cf-Id", "", "cloudfront"},
+	{"X-Fastly-Request-Id", "", "fastly"},
+	{"X-Served-By", "cache-", "fastly"},
+	{"X-Akamai-Transformed", "", "akamai"},
+	{"Akamai-Grn", "", "akamai"},
+	{"X-Azure-Ref", "", "azure-front-door"},
+	{"X-Vercel-Id", "", "vercel"},
+	{"X-Nf-Request-Id", "", "netlify"},
+	{"X-Sucuri-Id", "", "sucuri"},
+	{"X-Iinfo", "", "imperva"},
+	{"Cdn-Pullzone", "", "bunnycdn"},
+	{"Server", "cloudflare", "cloudflare"},
+	{"Server", "akamaighost", "akamai"},
+	{"Server", "cloudfront", "cloudfront"},
+	{"Server", "bunnycdn", "bunnycdn"},
+	{"Server", "gws", "google"},
+	{"Via", "cloudfront", "cloudfront"},
+	{"Via", "varnish", "varnish"},
+}
+
+// connectionTrace records the connection that carried the last request of a fetch, which is the
+// one that got the final response when redirects were followed
+type connectionTrace struct {
+	mutex    sync.Mutex //Protects the fields below, which the transport sets from its own goroutines
+	remoteIP string     //IP address of the server, empty if no connection was used
+}
+
+// traceConnection returns a copy of req whose connections are recorded in the returned trace
+func traceConnection(req *http.Request) (*http.Request, *connectionTrace) {
+	trace := &connectionTrace{}
+	clientTrace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String())
+			//Check if the remote address has no port, as for non-TCP connections
+			if err != nil {
+				host = info.Conn.RemoteAddr().String()
+			}
+			trace.mutex.Lock()
+			trace.remoteIP = host
+			trace.mutex.Unlock()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace)), trace
+}
+
+// apply copies what the trace recorded into a result
+func (t *connectionTrace) apply(result *Result) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	result.RemoteIP = t.remoteIP
+}
+
+// recordServer copies the TLS version, ALPN protocol and CDN of the final response into a result
+func recordServer(result *Result, resp *http.Response) {
+	result.TLSVersion, result.ALPN = "", ""
+	//Check if the response came over TLS
+	if resp.TLS != nil {
+		result.TLSVersion = tls.VersionName(resp.TLS.Version)
+		result.ALPN = resp.TLS.NegotiatedProtocol
+	}
+	result.CDN = detectCDN(resp.Header)
+}
+
+// detectCDN returns the CDN or edge network whose headers a response carries, e.g. "cloudflare",
+// or "" if none is recognized
+func detectCDN(header http.Header) string {
+	for _, marker := range cdnMarkers {
+		value := strings.ToLower(header.Get(marker.header))
+		//Check if the header is present with a matching value
+		if value != "" && strings.Contains(value, marker.value) {
+			return marker.cdn
+		}
+	}
+	return ""
+}
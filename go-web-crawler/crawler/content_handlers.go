@@ -0,0 +1,243 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ContentHandler extracts links, title and text from a response body of one media type.
+// Relative links are resolved against pageURL, the final URL of the response. A handler must
+// return either a non-nil Page or an error.
+type ContentHandler func(body io.Reader, pageURL *url.URL) (*Page, error)
+
+// maxBufferedBody is the most of a body read into memory by handlers that cannot stream it, such
+// as the PDF parser and WASM extractors; the rest is not read and the page is marked truncated
+const maxBufferedBody = 32 << 20
+
+// defaultContentHandlers returns the handlers the crawler starts with
+func defaultContentHandlers() map[string]ContentHandler {
+	return map[string]ContentHandler{
+		"text/html":             ExtractLinks,
+		"application/xhtml+xml": ExtractLinks,
+		"application/json":      extractJSONLinks,
+		"application/xml":       extractXMLLinks,
+		"text/xml":              extractXMLLinks,
+		"application/rss+xml":   extractXMLLinks,
+		"application/atom+xml":  extractXMLLinks,
+		"application/pdf":       extractPDFLinks,
+	}
+}
+
+// HandleContentType registers the handler for responses of the given media type, e.g. "text/html",
+// replacing any existing one. A nil handler stops responses of that type from being parsed.
+func (c *Crawler) HandleContentType(mediaType string, handler ContentHandler) {
+	mediaType = strings.ToLower(mediaType)
+	//Check if the handler for the type should be removed
+	if handler == nil {
+		delete(c.handlers, mediaType)
+		return
+	}
+	c.handlers[mediaType] = handler
+}
+
+// recordContent stores the declared media type and body size of a response in its result
+func recordContent(result *Result, resp *http.Response) {
+	//Check if the server declared a media type, which responseMediaType would otherwise assume
+	if resp.Header.Get("Content-Type") != "" {
+		result.ContentType = responseMediaType(resp)
+	}
+	result.ContentLength = max(resp.ContentLength, 0)
+}
+
+// responseMediaType returns the lower-case media type of a response without parameters.
+// Responses without a Content-Type are treated as HTML, which is what the crawler always assumed.
+func responseMediaType(resp *http.Response) string {
+	header := resp.Header.Get("Content-Type")
+	//Check if the server did not declare a type
+	if header == "" {
+		return "text/html"
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	//Check if the header is malformed; fall back to the part before any parameters
+	if err != nil {
+		mediaType, _, _ = strings.Cut(header, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// jsonLinkKeys are object keys whose string values are followed even when they are relative URL's
+var jsonLinkKeys = map[string]bool{"href": true, "url": true, "link": true, "next": true, "@id": true}
+
+// extractJSONLinks walks a JSON document and returns absolute HTTP(S) URL's found in any string value,
+// plus relative ones stored under link-like keys. A top-level "title" or "name" becomes the page title.
+func extractJSONLinks(body io.Reader, pageURL *url.URL) (*Page, error) {
+	var doc interface{}
+	//Check if the document is not valid JSON
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+	page := &Page{}
+	//Check if the document has a top-level title
+	if obj, ok := doc.(map[string]interface{}); ok {
+		for _, key := range []string{"title", "name"} {
+			//Check if the key holds a usable title
+			if title, ok := obj[key].(string); ok && page.Title == "" {
+				page.Title = collapsedString(appendCollapsed(nil, []byte(title)))
+			}
+		}
+	}
+	var text []byte
+	var walk func(key string, value interface{})
+	walk = func(key string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys) // Keep links in a stable order between runs
+			for _, k := range keys {
+				walk(strings.ToLower(k), v[k])
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(key, child)
+			}
+		case string:
+			text = append(appendCollapsed(text, []byte(v)), ' ')
+			//Check if the value is a link: an absolute URL, or any URL under a link-like key
+			if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") || jsonLinkKeys[key] {
+				//Check if the value normalizes to a crawlable URL
+				if link, err := NormalizeURL(v, pageURL); err == nil && link != "" {
+					page.Links = append(page.Links, Link{URL: link})
+				}
+			}
+		}
+	}
+	walk("", doc)
+	page.Text = collapsedString(appendCollapsed(nil, text))
+	return page, nil
+}
+
+// extractXMLLinks returns the links of sitemaps (<loc>), RSS feeds (<link>URL</link>) and Atom
+// feeds (<link href="URL"/>), along with the document's first <title> and its character data
+func extractXMLLinks(body io.Reader, pageURL *url.URL) (*Page, error) {
+	page := &Page{}
+	var text, elementText []byte
+	addLink := func(raw string) {
+		//Check if the value normalizes to a crawlable URL
+		if link, err := NormalizeURL(strings.TrimSpace(raw), pageURL); err == nil && link != "" {
+			page.Links = append(page.Links, Link{URL: link})
+		}
+	}
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		//Check if the end of the document was reached
+		if err == io.EOF {
+			page.Text = collapsedString(text)
+			return page, nil
+		}
+		//Check if the document is malformed
+		if err != nil {
+			return nil, fmt.Errorf("error parsing XML: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			elementText = elementText[:0]
+			//Check if the element is an Atom-style link
+			if strings.EqualFold(t.Name.Local, "link") {
+				for _, attr := range t.Attr {
+					//Check if the attribute is the link target
+					if attr.Name.Local == "href" {
+						addLink(attr.Value)
+					}
+				}
+			}
+		case xml.CharData:
+			elementText = append(elementText, t...)
+			text = appendCollapsed(text, t)
+			//Check if the text needs a separator from the next element's text
+			if len(text) > 0 && text[len(text)-1] != ' ' {
+				text = append(text, ' ')
+			}
+		case xml.EndElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "loc", "link":
+				//Check if the element holds a URL as text
+				if len(bytes.TrimSpace(elementText)) > 0 {
+					addLink(string(elementText))
+				}
+			case "title":
+				//Check if this is the document's first title
+				if page.Title == "" {
+					page.Title = collapsedString(appendCollapsed(nil, elementText))
+				}
+			}
+			elementText = elementText[:0]
+		}
+	}
+}
+
+// pdfURIPattern matches the URI actions of PDF link annotations, e.g. /URI (https://example.com/)
+var pdfURIPattern = regexp.MustCompile(`/URI\s*\(((?:\\.|[^\\)])*)\)`)
+
+// pdfTitlePattern matches a literal-string title in the PDF document information dictionary
+var pdfTitlePattern = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+
+// extractPDFLinks returns the link annotations of a PDF. Only objects stored uncompressed are
+// searched, which covers most link annotations but not links inside compressed object streams.
+func extractPDFLinks(body io.Reader, pageURL *url.URL) (*Page, error) {
+	data, truncated, err := readBufferedBody(body)
+	//Check if the body could not be read
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF: %w", err)
+	}
+	//Check if the body is not a PDF
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("error parsing PDF: missing %%PDF header")
+	}
+	page := &Page{Truncated: truncated}
+	for _, match := range pdfURIPattern.FindAllSubmatch(data, -1) {
+		//Check if the URI normalizes to a crawlable URL
+		if link, err := NormalizeURL(unescapePDFString(match[1]), pageURL); err == nil && link != "" {
+			page.Links = append(page.Links, Link{URL: link})
+		}
+	}
+	//Check if the document declares a title
+	if match := pdfTitlePattern.FindSubmatch(data); match != nil {
+		page.Title = collapsedString(appendCollapsed(nil, []byte(unescapePDFString(match[1]))))
+	}
+	return page, nil
+}
+
+// readBufferedBody reads a body into memory up to maxBufferedBody and reports whether it went on
+// past the cap
+func readBufferedBody(body io.Reader) ([]byte, bool, error) {
+	limited := &sizeLimitedReader{r: body, left: maxBufferedBody}
+	data, err := io.ReadAll(limited)
+	return data, limited.exceeded, err
+}
+
+// unescapePDFString removes the backslash escapes of a PDF literal string
+func unescapePDFString(s []byte) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		//Check if the byte escapes the next one
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// controlRequest is one line a client sends to the control socket
+type controlRequest struct {
+	Command   string   `json:"command"`    //"status", "pause", "resume", "stop", "rate", "workers", "host_delay" or "add"
+	Rate      float64  `json:"rate"`       //Requests per second, for "rate"
+	Workers   int      `json:"workers"`    //Concurrent workers, for "workers"
+	HostDelay string   `json:"host_delay"` //Least time between requests to a host as a duration like "2s", for "host_delay"
+	URLs      []string `json:"urls"`       //Seed URL's to queue, for "add"
+}
+
+// controlResponse is the line the control socket answers a request with
+type controlResponse struct {
+	OK     bool         `json:"ok"`               //Whether the command succeeded
+	Error  string       `json:"error,omitempty"`  //Why the command failed
+	Status *crawlStatus `json:"status,omitempty"` //Crawl status, for "status"
+	Queued []string     `json:"queued,omitempty"` //URL's that were queued, for "add"
+}
+
+// crawlStatus is a snapshot of a running crawl for the control socket
+type crawlStatus struct {
+	State     string  `json:"state"`      //"running", "paused" or "finished"
+	Queued    int     `json:"queued"`     //URL's waiting in the frontier
+	Visited   int     `json:"visited"`    //URL's queued or visited so far
+	Crawled   int64   `json:"crawled"`    //URL's taken from the frontier for fetching
+	InFlight  int     `json:"in_flight"`  //Fetches in progress
+	Rate      float64 `json:"rate"`       //Requests per second allowed by the rate limiter
+	Workers   int     `json:"workers"`    //Concurrent workers wanted
+	HostDelay string  `json:"host_delay"` //Least time between requests to a host
+}
+
+// status returns a snapshot of the crawl
+func (c *Crawler) status() *crawlStatus {
+	urls, _ := c.watchdog.inFlight()
+	c.workerMutex.Lock()
+	workers := c.workers
+	c.workerMutex.Unlock()
+	s := &crawlStatus{
+		State:     "running",
+		Queued:    c.frontier.len(),
+		Visited:   c.visited.len(),
+		Crawled:   c.crawled.Load(),
+		InFlight:  len(urls),
+		Rate:      float64(c.limiter.Limit()),
+		Workers:   workers,
+		HostDelay: c.hostDelay.get().String(),
+	}
+	switch {
+	case c.frontier.isClosed():
+		s.State = "finished"
+	case c.gate.isPaused():
+		s.State = "paused"
+	}
+	return s
+}
+
+// AddSeeds queues further seed URL's while the crawl runs and returns those that were queued. URL's
+// that are out of scope or already known are skipped like any discovered link.
+func (c *Crawler) AddSeeds(urls []string) ([]string, error) {
+	for _, link := range urls {
+		//Check if the URL cannot be a seed
+		if err := checkSeedURL(link); err != nil {
+			return nil, err
+		}
+	}
+	// Hold the frontier open while queueing, so the crawl cannot end halfway through
+	if !c.frontier.tryHold() {
+		return nil, errors.New("crawl has finished")
+	}
+	defer c.frontier.done()
+	var queued []string
+	for _, link := range urls {
+		//Check if the URL was queued
+		if c.enqueue(link, 1, LinkMeta{Seed: link}) {
+			queued = append(queued, link)
+		}
+	}
+	c.logf(0, "added %d of %d seeds", len(queued), len(urls))
+	return queued, nil
+}
+
+// checkSeedURL returns an error unless link is an absolute http or https URL
+func checkSeedURL(link string) error {
+	//Check if the URL is not an absolute web URL
+	if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid seed %q: must be an absolute http or https URL", link)
+	}
+	return nil
+}
+
+// ServeControl answers clients of the control socket until the listener is closed. Each line a
+// client sends is a JSON request, answered with one line of JSON.
+func (c *Crawler) ServeControl(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		//Check if the listener was closed
+		if err != nil {
+			return
+		}
+		go c.handleControl(conn)
+	}
+}
+
+// handleControl answers the requests of one control socket client
+func (c *Crawler) handleControl(conn net.Conn) {
+	defer conn.Close()
+	encoder := json.NewEncoder(conn)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req controlRequest
+		var resp controlResponse
+		//Check if the request is not valid JSON
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			resp = c.control(req)
+		}
+		//Check if the client went away
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// control runs a control command
+func (c *Crawler) control(req controlRequest) controlResponse {
+	var err error
+	resp := controlResponse{}
+	switch req.Command {
+	case "status":
+		resp.Status = c.status()
+	case "pause":
+		c.Pause()
+	case "resume":
+		c.Resume()
+	case "stop":
+		c.Stop()
+	case "rate":
+		err = c.SetRate(req.Rate)
+	case "workers":
+		err = c.SetWorkers(req.Workers)
+	case "host_delay":
+		var interval time.Duration
+		//Check if the delay is a valid duration
+		if interval, err = time.ParseDuration(req.HostDelay); err == nil {
+			err = c.SetHostDelay(interval)
+		}
+	case "add":
+		resp.Queued, err = c.AddSeeds(req.URLs)
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+	//Check if the command failed
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.OK = true
+	return resp
+}
@@ -0,0 +1,157 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Credential holds how to authenticate to one host
+type Credential struct {
+	Type     string `json:"type"`     //Authentication scheme: "basic", "digest", "bearer" or "oauth2"
+	Username string `json:"username"` //User name for basic and digest authentication
+	Password string `json:"password"` //Password for basic and digest authentication
+	Token    string `json:"token"`    //Token sent for bearer authentication
+
+	// OAuth2 client credentials grant
+	TokenURL     string   `json:"token_url"`     //Token endpoint of the authorization server
+	ClientID     string   `json:"client_id"`     //Client identifier
+	ClientSecret string   `json:"client_secret"` //Client secret
+	Scopes       []string `json:"scopes"`        //Requested scopes, optional
+
+	mutex  sync.Mutex         //Protects tokens
+	tokens oauth2.TokenSource //Caches and renews OAuth2 access tokens, created on first use
+}
+
+// validate checks that the credential has the fields its type needs
+func (cred *Credential) validate() error {
+	switch cred.Type {
+	case "basic", "digest":
+		//Check if the user name is missing
+		if cred.Username == "" {
+			return fmt.Errorf("%s credentials need a username", cred.Type)
+		}
+	case "bearer":
+		//Check if the token is missing
+		if cred.Token == "" {
+			return fmt.Errorf("bearer credentials need a token")
+		}
+	case "oauth2":
+		//Check if the client credentials grant is incomplete
+		if cred.TokenURL == "" || cred.ClientID == "" {
+			return fmt.Errorf("oauth2 credentials need a token_url and client_id")
+		}
+	default:
+		return fmt.Errorf("unknown credential type %q (expected \"basic\", \"digest\", \"bearer\" or \"oauth2\")", cred.Type)
+	}
+	return nil
+}
+
+// authorize adds the credential to a request answering the 401 challenge in resp
+func (cred *Credential) authorize(req *http.Request, resp *http.Response) error {
+	switch cred.Type {
+	case "basic":
+		req.SetBasicAuth(cred.Username, cred.Password)
+	case "digest":
+		return cred.authorizeDigest(req, resp)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+cred.Token)
+	case "oauth2":
+		// The preemptively sent token was rejected, so fetch a new one
+		token, err := cred.oauth2Token(true)
+		//Check if no new token could be fetched
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// authorizePreemptive adds credentials that are sent without waiting for a 401 challenge. Only
+// OAuth2 tokens are, since OAuth-protected sites often redirect to a login page instead of answering 401.
+func (c *Crawler) authorizePreemptive(req *http.Request) error {
+	cred := c.credentialFor(req.URL)
+	//Check if the host uses OAuth2
+	if cred == nil || cred.Type != "oauth2" {
+		return nil
+	}
+	token, err := cred.oauth2Token(false)
+	//Check if no token could be fetched
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// mergeCredentials combines credential maps keyed by host, later maps taking precedence, and lower-cases the hosts
+func mergeCredentials(layers ...map[string]*Credential) map[string]*Credential {
+	merged := make(map[string]*Credential)
+	for _, layer := range layers {
+		for host, cred := range layer {
+			merged[strings.ToLower(host)] = cred
+		}
+	}
+	return merged
+}
+
+// credentialFor returns the credential configured for a URL's host, trying the host with its port
+// first and then without, or nil if there is none
+func (c *Crawler) credentialFor(u *url.URL) *Credential {
+	//Check if credentials are configured for the host and port
+	if cred, ok := c.credentials[strings.ToLower(u.Host)]; ok {
+		return cred
+	}
+	return c.credentials[strings.ToLower(u.Hostname())]
+}
+
+// do sends a request and, if the server answers 401 Unauthorized, retries it once with the
+// credentials configured for the host that sent the challenge
+func (c *Crawler) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	//Check if hosts' Strict-Transport-Security policies are remembered
+	if err == nil && c.hsts != nil {
+		c.hsts.record(resp)
+	}
+	//Check if the request failed or did not ask for authentication
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	cred := c.credentialFor(resp.Request.URL)
+	//Check if there are no credentials to answer the challenge with
+	if cred == nil {
+		return resp, nil
+	}
+	retry := resp.Request.Clone(req.Context())
+	//Check if the challenge cannot be answered with the credentials
+	if err := cred.authorize(retry, resp); err != nil {
+		c.logf(1, "cannot authenticate to %s: %v", resp.Request.URL.Host, err)
+		return resp, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Drain the challenge so the connection can be reused
+	resp.Body.Close()
+	//Wait for rate limiter to allow the retry
+	if err := c.waitForTurn(req.Context(), retry.URL.String()); err != nil {
+		return nil, err
+	}
+	c.logf(1, "%s %s -> 401, retrying with %s credentials", req.Method, retry.URL, cred.Type)
+	return c.client.Do(retry)
+}
+
+// authError describes a 401 response, telling pages without configured credentials apart from
+// pages whose credentials were rejected
+func (c *Crawler) authError(pageURL string, resp *http.Response) error {
+	host := resp.Request.URL.Host
+	//Check if credentials were configured for the host
+	if c.credentialFor(resp.Request.URL) == nil {
+		return fmt.Errorf("authentication required for %s: no credentials configured for %s (challenge %q)",
+			pageURL, host, resp.Header.Get("WWW-Authenticate"))
+	}
+	return fmt.Errorf("authentication failed for %s: credentials for %s were rejected", pageURL, host)
+}
@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadLetter is one permanently failed URL, stored as a line of NDJSON
+type DeadLetter struct {
+	SchemaVersion string    `json:"schema_version,omitempty"` //Version of output.schema.json the entry follows, empty before versioning
+	URL           string    `json:"url"`                      //URL that failed
+	Depth         int       `json:"depth"`                    //Depth at which the URL was crawled
+	Status        int       `json:"status,omitempty"`         //HTTP status code, omitted if no response was received
+	ErrorClass    string    `json:"error_class"`              //Coarse failure category, see errorClass
+	Error         string    `json:"error,omitempty"`          //Error message of the failed attempt
+	FailedAt      time.Time `json:"failed_at"`                //When the failure was recorded
+	RequestID     string    `json:"request_id,omitempty"`     //ID of the failed fetch in the crawl's log
+}
+
+// errorClass groups a failed result into a coarse category such as "http-4xx", "timeout" or "dns"
+func errorClass(result Result) string {
+	//Check if bot protection blocked the URL
+	if result.Blocked != "" {
+		return "blocked-by-bot-protection"
+	}
+	//Check if the server asked for credentials
+	if result.Status == 401 {
+		//Check if credentials were sent and rejected
+		if strings.HasPrefix(result.Error, "authentication failed") {
+			return "auth-failed"
+		}
+		return "auth-required"
+	}
+	//Check if the server answered with a failing status
+	if result.Status >= 400 && result.Status < 600 {
+		return fmt.Sprintf("http-%dxx", result.Status/100)
+	}
+	//Check if the status was classified as a failure outside the error ranges
+	if result.Status != 0 {
+		return "http-status"
+	}
+	msg := strings.ToLower(result.Error)
+	switch {
+	case strings.Contains(msg, errWatchdogAbort.Error()):
+		return "stalled"
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(msg, "no such host"):
+		return "dns"
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset"):
+		return "connection"
+	case strings.Contains(msg, "tls") || strings.Contains(msg, "x509") || strings.Contains(msg, "certificate"):
+		return "tls"
+	case strings.Contains(msg, "error parsing"):
+		return "parse"
+	case strings.Contains(msg, "redirects"):
+		return "redirect"
+	}
+	return "network"
+}
+
+// deadLetterFile appends failed results to an NDJSON file shared by all workers
+type deadLetterFile struct {
+	mutex   sync.Mutex    //Serializes writes from concurrent workers
+	file    *os.File      //Open dead-letter file
+	encoder *json.Encoder //Writes one JSON object per line
+}
+
+// createDeadLetterFile creates or truncates the dead-letter file at path
+func createDeadLetterFile(path string) (*deadLetterFile, error) {
+	file, err := os.Create(path)
+	//Check if the file could not be created
+	if err != nil {
+		return nil, fmt.Errorf("error creating dead-letter file: %w", err)
+	}
+	return &deadLetterFile{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// record writes a failed result to the file
+func (d *deadLetterFile) record(result Result) error {
+	letter := DeadLetter{
+		SchemaVersion: schemaVersion,
+		URL:           result.URL,
+		Depth:         result.Depth,
+		Status:        result.Status,
+		ErrorClass:    errorClass(result),
+		Error:         result.Error,
+		FailedAt:      time.Now().UTC(),
+		RequestID:     result.RequestID,
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.encoder.Encode(letter)
+}
+
+// Close closes the underlying file
+func (d *deadLetterFile) Close() error {
+	return d.file.Close()
+}
+
+// ReadDeadLetters reads the entries of a dead-letter file, skipping blank lines
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	//Check if the file could not be opened
+	if err != nil {
+		return nil, fmt.Errorf("error opening dead-letter file: %w", err)
+	}
+	defer file.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		//Check if the line is blank
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var letter DeadLetter
+		//Check if the line is not a valid entry
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil || letter.URL == "" {
+			return nil, fmt.Errorf("invalid dead-letter entry on line %d of %s", line, path)
+		}
+		//Check if the entry was written by a newer, incompatible version
+		if err := checkSchemaVersion(letter.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("dead-letter entry on line %d of %s: %w", line, path, err)
+		}
+		letters = append(letters, letter)
+	}
+	//Check if reading the file failed
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading dead-letter file: %w", err)
+	}
+	return letters, nil
+}
+
+// RetryDeadLetters fetches each dead-lettered URL once more at its original depth, without following
+// its links. The results and errors channels are closed when done.
+func (c *Crawler) RetryDeadLetters(ctx context.Context, letters []DeadLetter) error {
+	depths := make(map[string]int, len(letters))
+	urls := make([]string, 0, len(letters))
+	for _, letter := range letters {
+		urls = append(urls, letter.URL)
+		depths[letter.URL] = letter.Depth
+	}
+	return c.checkAll(ctx, urls, func(ctx context.Context, link string) (Result, error) {
+		result, _, err := c.fetchPage(ctx, link, depths[link])
+		//Check if the page failed before a response could be classified
+		if result.Class == "" {
+			result.Class = ClassFailure
+		}
+		return result, err
+	})
+}
@@ -0,0 +1,293 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// connectRule reroutes connections like curl's --connect-to HOST1:PORT1:HOST2:PORT2
+type connectRule struct {
+	fromHost string //Lower-case host whose connections are rerouted, empty for any
+	fromPort string //Port whose connections are rerouted, empty for any
+	toHost   string //Host or IP connected to instead, empty to keep the original
+	toPort   string //Port connected to instead, empty to keep the original
+}
+
+// crawlDialer opens the transport's connections. Rerouting happens below TLS and HTTP, so the
+// server still gets the URL's host in the Host header and in TLS SNI, and its certificate is
+// verified for that host.
+type crawlDialer struct {
+	dialer    net.Dialer    //Dialer for the connections
+	connectTo []connectRule //Connection overrides, the first matching one applies
+	network   string        //"tcp4" or "tcp6" to restrict TCP connections to one address family, empty for both
+
+	addressTimeout time.Duration //Time each address of a host gets to connect, 0 to share the dialer's timeout between them
+	logf           func(level int, format string, args ...interface{})
+}
+
+// newCrawlDialer creates a dialer with the timeouts of http.DefaultTransport
+func newCrawlDialer(logf func(level int, format string, args ...interface{})) *crawlDialer {
+	return &crawlDialer{dialer: net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}, logf: logf}
+}
+
+// DialContext connects to addr, or to the address a -connect-to rule reroutes it to
+func (d *crawlDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	//Check if the address is rerouted
+	if target := d.reroute(addr); target != addr {
+		d.logf(2, "connecting to %s instead of %s", target, addr)
+		addr = target
+	}
+	//Check if TCP connections are restricted to one address family
+	if d.network != "" && strings.HasPrefix(network, "tcp") {
+		network = d.network
+	}
+	//Check if each address gets its own timeout
+	if d.addressTimeout > 0 && strings.HasPrefix(network, "tcp") {
+		return d.dialAddresses(ctx, network, addr)
+	}
+	return d.dialer.DialContext(ctx, network, addr)
+}
+
+// dialResult is the outcome of dialing one family's addresses
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialAddresses resolves the host of addr and connects to its addresses one at a time, giving
+// each addressTimeout. Like the standard dialer, addresses of the family the resolver listed first
+// are tried first, and the other family starts after the fallback delay unless one connected.
+func (d *crawlDialer) dialAddresses(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	//Check if the address cannot be split into host and port
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip"+strings.TrimPrefix(network, "tcp"), host)
+	//Check if the host could not be resolved
+	if err != nil {
+		return nil, err
+	}
+	var primary, fallback []net.IP
+	for _, ip := range ips {
+		//Check if the address belongs to the first listed family
+		if (ip.To4() != nil) == (ips[0].To4() != nil) {
+			primary = append(primary, ip)
+		} else {
+			fallback = append(fallback, ip)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	dialEach := func(list []net.IP) {
+		var lastErr error
+		for _, ip := range list {
+			attemptCtx, stop := context.WithTimeout(ctx, d.addressTimeout)
+			conn, err := d.dialer.DialContext(attemptCtx, network, net.JoinHostPort(ip.String(), port))
+			stop()
+			//Check if the address accepted the connection
+			if err == nil {
+				results <- dialResult{conn: conn}
+				return
+			}
+			d.logf(2, "connecting to %s at %s failed: %v", addr, ip, err)
+			lastErr = err
+			//Check if the dial was cancelled, so the remaining addresses are not tried
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		results <- dialResult{err: lastErr}
+	}
+	go dialEach(primary)
+	pending, fallbackStarted := 1, false
+	startFallback := func() {
+		//Check if the other family exists and was not tried yet
+		if !fallbackStarted && len(fallback) > 0 {
+			fallbackStarted = true
+			go dialEach(fallback)
+			pending++
+		}
+	}
+	var fallbackTimer <-chan time.Time
+	//Check if the other family races the first one after the fallback delay; a negative delay only tries it once the first one failed
+	if len(fallback) > 0 && d.dialer.FallbackDelay >= 0 {
+		delay := d.dialer.FallbackDelay
+		//Check if the default delay of the standard dialer applies
+		if delay == 0 {
+			delay = 300 * time.Millisecond
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		fallbackTimer = timer.C
+	}
+	var firstErr error
+	for {
+		select {
+		case <-fallbackTimer:
+			startFallback()
+		case result := <-results:
+			pending--
+			//Check if a connection was made; one the other family makes meanwhile is closed
+			if result.err == nil {
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						//Check if the other family connected too
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return result.conn, nil
+			}
+			//Check if this is the first failure, which is the one reported
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			startFallback()
+			//Check if no dial is left
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// bind makes connections originate from a local IP address or from the address of a network
+// interface, preferring its IPv4 address. Connections are restricted to the address's family,
+// since its packets could not reach servers of the other one.
+func (d *crawlDialer) bind(local string) error {
+	ip := net.ParseIP(local)
+	//Check if the address is an interface name
+	if ip == nil {
+		iface, err := net.InterfaceByName(local)
+		//Check if there is no such interface
+		if err != nil {
+			return fmt.Errorf("invalid -bind %q: not an IP address or network interface", local)
+		}
+		addrs, err := iface.Addrs()
+		//Check if the interface's addresses could not be listed
+		if err != nil {
+			return fmt.Errorf("error reading addresses of %s: %w", local, err)
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			//Check if the address is an IP address that is better than the one found so far
+			if ok && (ip == nil || (ip.To4() == nil && ipNet.IP.To4() != nil)) {
+				ip = ipNet.IP
+			}
+		}
+		//Check if the interface has no IP address
+		if ip == nil {
+			return fmt.Errorf("network interface %s has no IP address", local)
+		}
+	}
+	network := "tcp6"
+	//Check if the address is an IPv4 address
+	if ip.To4() != nil {
+		network = "tcp4"
+	}
+	//Check if -ip-version asks for the other address family
+	if d.network != "" && d.network != network {
+		return fmt.Errorf("-bind %s cannot make %s connections", local, d.network)
+	}
+	d.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	d.network = network
+	return nil
+}
+
+// setIPVersion restricts TCP connections to IPv4 for "4" or IPv6 for "6"; "auto" uses both
+func (d *crawlDialer) setIPVersion(version string) error {
+	switch version {
+	case "auto":
+		d.network = ""
+	case "4", "6":
+		d.network = "tcp" + version
+	default:
+		return fmt.Errorf("invalid -ip-version %q (expected 4, 6 or auto)", version)
+	}
+	return nil
+}
+
+// reroute returns the address to connect to for addr
+func (d *crawlDialer) reroute(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	//Check if the address has no port to match rules against
+	if err != nil {
+		return addr
+	}
+	host = strings.ToLower(host)
+	for _, rule := range d.connectTo {
+		//Check if the rule applies to the address
+		if (rule.fromHost == "" || rule.fromHost == host) && (rule.fromPort == "" || rule.fromPort == port) {
+			toHost, toPort := host, port
+			//Check if the rule replaces the host
+			if rule.toHost != "" {
+				toHost = rule.toHost
+			}
+			//Check if the rule replaces the port
+			if rule.toPort != "" {
+				toPort = rule.toPort
+			}
+			return net.JoinHostPort(toHost, toPort)
+		}
+	}
+	return addr
+}
+
+// parseConnectTo parses comma-separated HOST1:PORT1:HOST2:PORT2 rules. Any part may be empty to
+// match any host or port, or to keep the original one; IPv6 addresses are written in brackets.
+func parseConnectTo(list string) ([]connectRule, error) {
+	var rules []connectRule
+	for _, entry := range strings.Split(list, ",") {
+		//Check if the entry is blank
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts, err := splitConnectTo(strings.TrimSpace(entry))
+		//Check if the entry does not have four parts
+		if err != nil {
+			return nil, fmt.Errorf("invalid -connect-to %q: %v (expected HOST1:PORT1:HOST2:PORT2)", entry, err)
+		}
+		rules = append(rules, connectRule{fromHost: strings.ToLower(parts[0]), fromPort: parts[1], toHost: parts[2], toPort: parts[3]})
+	}
+	return rules, nil
+}
+
+// splitConnectTo splits a rule at its colons, keeping bracketed IPv6 addresses together
+func splitConnectTo(entry string) ([]string, error) {
+	var parts []string
+	for len(parts) < 3 {
+		var part string
+		//Check if the part is a bracketed IPv6 address
+		if strings.HasPrefix(entry, "[") {
+			end := strings.Index(entry, "]")
+			//Check if the bracket is not closed
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket")
+			}
+			part, entry = entry[1:end], entry[end+1:]
+			//Check if the address is not followed by a separator
+			if !strings.HasPrefix(entry, ":") {
+				return nil, fmt.Errorf("missing colon after [%s]", part)
+			}
+			entry = entry[1:]
+		} else {
+			var ok bool
+			//Check if the rule has fewer than four parts
+			if part, entry, ok = strings.Cut(entry, ":"); !ok {
+				return nil, fmt.Errorf("too few parts")
+			}
+		}
+		parts = append(parts, part)
+	}
+	// The last part is a port, which has no brackets or colons
+	if strings.Contains(entry, ":") {
+		return nil, fmt.Errorf("too many parts")
+	}
+	return append(parts, entry), nil
+}
@@ -0,0 +1,190 @@
+package crawler
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms maps the RFC 7616 algorithm names to their hash functions, strongest first
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+// digestChallenge is a parsed Digest WWW-Authenticate challenge
+type digestChallenge struct {
+	params    map[string]string //Challenge parameters by lower-case name, e.g. realm and nonce
+	algorithm string            //Upper-case algorithm without the -sess suffix
+	hash      func() hash.Hash  //Hash function of the algorithm
+	session   bool              //Whether the algorithm is a -sess variant
+	strength  int               //Index into digestAlgorithms, lower is stronger
+}
+
+// parseDigestChallenge parses the parameters of one WWW-Authenticate header value starting with "Digest",
+// returning false if it is not a Digest challenge or uses an unsupported algorithm
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	//Check if the challenge uses the Digest scheme
+	if !strings.EqualFold(scheme, "Digest") {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		name, value, ok := strings.Cut(rest, "=")
+		//Check if the parameter has no value
+		if !ok {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimLeft(value, " \t")
+		//Check if the value is a quoted string
+		if strings.HasPrefix(value, `"`) {
+			var unquoted strings.Builder
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				//Check if the character is escaped
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				unquoted.WriteByte(value[i])
+			}
+			params[name] = unquoted.String()
+			rest = value[min(i+1, len(value)):]
+		} else {
+			end := strings.IndexByte(value, ',')
+			//Check if this is the last parameter
+			if end < 0 {
+				end = len(value)
+			}
+			params[name] = strings.TrimSpace(value[:end])
+			rest = value[end:]
+		}
+		rest = strings.TrimLeft(rest, ", \t")
+	}
+	//Check if the challenge lacks the nonce every response needs
+	if params["nonce"] == "" {
+		return nil, false
+	}
+	challenge := &digestChallenge{params: params}
+	algorithm := strings.ToUpper(params["algorithm"])
+	//Check if the server relies on the default algorithm
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	challenge.algorithm, challenge.session = strings.CutSuffix(algorithm, "-SESS")
+	for i, a := range digestAlgorithms {
+		//Check if the algorithm is supported
+		if a.name == challenge.algorithm {
+			challenge.hash, challenge.strength = a.hash, i
+			return challenge, true
+		}
+	}
+	return nil, false
+}
+
+// digestHash returns the lower-case hex digest of the parts joined with colons
+func (ch *digestChallenge) digestHash(parts ...string) string {
+	h := ch.hash()
+	h.Write([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorizeDigest answers the strongest supported Digest challenge in resp following RFC 7616
+func (cred *Credential) authorizeDigest(req *http.Request, resp *http.Response) error {
+	var challenge *digestChallenge
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		//Check if the header holds a supported challenge that is stronger than the current one
+		if ch, ok := parseDigestChallenge(header); ok && (challenge == nil || ch.strength < challenge.strength) {
+			challenge = ch
+		}
+	}
+	//Check if the server offered no usable Digest challenge
+	if challenge == nil {
+		return fmt.Errorf("no supported Digest challenge in %q", resp.Header.Values("WWW-Authenticate"))
+	}
+	params := challenge.params
+	qop := ""
+	//Check if the server supports quality of protection "auth"; "auth-int" would need the request body
+	if params["qop"] != "" {
+		for _, option := range strings.Split(params["qop"], ",") {
+			//Check if the option is plain authentication
+			if strings.TrimSpace(option) == "auth" {
+				qop = "auth"
+			}
+		}
+		//Check if only unsupported options were offered
+		if qop == "" {
+			return fmt.Errorf("unsupported Digest qop %q", params["qop"])
+		}
+	}
+
+	nonceBytes := make([]byte, 16)
+	//Check if no client nonce could be generated
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return fmt.Errorf("error generating Digest client nonce: %w", err)
+	}
+	cnonce := hex.EncodeToString(nonceBytes)
+	const nc = "00000001" // Every challenge is answered once, so the nonce count never grows
+	uri := req.URL.RequestURI()
+
+	ha1 := challenge.digestHash(cred.Username, params["realm"], cred.Password)
+	//Check if the session variant mixes the nonces into the secret
+	if challenge.session {
+		ha1 = challenge.digestHash(ha1, params["nonce"], cnonce)
+	}
+	ha2 := challenge.digestHash(req.Method, uri)
+	response := challenge.digestHash(ha1, params["nonce"], ha2)
+	//Check if the response must cover the client nonce and qop
+	if qop != "" {
+		response = challenge.digestHash(ha1, params["nonce"], nc, cnonce, qop, ha2)
+	}
+
+	username := cred.Username
+	//Check if the server wants the user name hashed
+	if strings.EqualFold(params["userhash"], "true") {
+		username = challenge.digestHash(cred.Username, params["realm"])
+	}
+	algorithm := challenge.algorithm
+	//Check if the session variant was used
+	if challenge.session {
+		algorithm += "-sess"
+	}
+	fields := []string{
+		"username=" + quoteDigest(username),
+		"realm=" + quoteDigest(params["realm"]),
+		"nonce=" + quoteDigest(params["nonce"]),
+		"uri=" + quoteDigest(uri),
+		"algorithm=" + algorithm,
+		"response=" + quoteDigest(response),
+	}
+	//Check if the server has to get its opaque value back
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, "opaque="+quoteDigest(opaque))
+	}
+	//Check if the qop parameters are part of the response
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, "cnonce="+quoteDigest(cnonce))
+	}
+	//Check if the user name was hashed
+	if username != cred.Username {
+		fields = append(fields, "userhash=true")
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+	return nil
+}
+
+// quoteDigest formats a value as an HTTP quoted-string
+func quoteDigest(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// DryRun fetches only the seeds, the command-line seed and those of the config file, and writes
+// which of their links would be crawled or filtered, and by which rule. Links are normalized and
+// checked like during a crawl, including HTTPS upgrades and robots.txt.
+func (c *Crawler) DryRun(w io.Writer) error {
+	seeds := c.Seeds()
+	//Check if there is no seed to preview
+	if len(seeds) == 0 {
+		return fmt.Errorf("no seed to preview")
+	}
+	seen := make(map[string]bool)
+	inScope, filtered := 0, 0
+	for i, seed := range seeds {
+		//Check if a previous seed was listed, which a blank line separates
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		seedInScope, seedFiltered, err := c.previewSeed(seed, seen, w)
+		//Check if the seed could not be previewed
+		if err != nil {
+			return err
+		}
+		inScope, filtered = inScope+seedInScope, filtered+seedFiltered
+	}
+	fmt.Fprintf(w, "\n%d links in scope, %d filtered\n", inScope, filtered)
+	return nil
+}
+
+// previewSeed fetches one seed and lists its links, skipping those already listed for another
+// seed. It returns how many links are in scope and how many are filtered.
+func (c *Crawler) previewSeed(seed string, seen map[string]bool, w io.Writer) (int, int, error) {
+	ctx := withSeed(context.Background(), c.seeds[seed])
+	// The seed is normalized like when it is queued
+	pageURL, _, reason, err := c.admitLink(seed, 1, LinkMeta{Seed: seed})
+	//Check if the seed cannot be parsed
+	if err != nil {
+		return 0, 0, err
+	}
+	fmt.Fprintf(w, "Seed: %s\n", pageURL)
+	//Check if a scope rule excludes the seed itself
+	if reason == "" {
+		reason = c.robotsReason(ctx, pageURL)
+	}
+	//Check if the seed would not be crawled, which leaves no links to preview
+	if reason != "" {
+		fmt.Fprintf(w, "filtered  %s (%s)\n", pageURL, reason)
+		return 0, 1, nil
+	}
+	seen[pageURL] = true
+	result, page, err := c.fetchPage(ctx, pageURL, 1)
+	//Check if the seed could not be fetched
+	if err != nil {
+		return 0, 0, err
+	}
+	//Check if the seed response has no links to preview
+	if page == nil {
+		return 0, 0, fmt.Errorf("seed %s returned status %d without links to preview", pageURL, result.Status)
+	}
+
+	inScope, filtered := 0, 0
+	for _, l := range page.Links {
+		depth := 2
+		//Check if the link is a frame, which is crawled at the seed's depth
+		if l.Frame {
+			depth = 1
+		}
+		link, checkOnly, reason, err := c.admitLink(l.URL, depth, LinkMeta{Parent: pageURL, Frame: l.Frame, Seed: seed})
+		//Check if the link cannot be parsed
+		if err != nil {
+			link, reason = l.URL, "invalid-url"
+		}
+		//Check if the link was already listed, in another spelling or for another seed
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		//Check if robots.txt would keep the crawler from fetching the page
+		if reason == "" && !checkOnly {
+			reason = c.robotsReason(ctx, link)
+		}
+		//Check if a rule would exclude the link
+		if reason != "" {
+			filtered++
+			fmt.Fprintf(w, "filtered  %s (%s)\n", link, reason)
+			continue
+		}
+		inScope++
+		fmt.Fprintf(w, "in-scope  %s\n", link)
+	}
+	return inScope, filtered, nil
+}
+
+// robotsReason returns "robots.txt" if the host's robots.txt disallows a normalized URL, "" otherwise
+func (c *Crawler) robotsReason(ctx context.Context, link string) string {
+	parsed, err := url.Parse(link)
+	//Check if robots.txt disallows the page
+	if err == nil && !c.robotsAllowed(ctx, parsed) {
+		return "robots.txt"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// edgeFile writes the link graph as a CSV edge list with one row per link found on a crawled page
+type edgeFile struct {
+	mutex  sync.Mutex  //Serializes writes from concurrent workers
+	file   *os.File    //Open edge list file
+	writer *csv.Writer //Writes the rows
+}
+
+// createEdgeFile creates or truncates the edge list at path and writes its header row
+func createEdgeFile(path string) (*edgeFile, error) {
+	file, err := os.Create(path)
+	//Check if the file could not be created
+	if err != nil {
+		return nil, fmt.Errorf("error creating edge list: %w", err)
+	}
+	edges := &edgeFile{file: file, writer: csv.NewWriter(file)}
+	//Check if the header could not be written
+	if err := edges.write([][]string{{"source", "target", "anchor_text", "nofollow", "position"}}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return edges, nil
+}
+
+// record writes the links found on a page as edges from source. Targets are canonicalized like
+// queued URL's, so they match the URL's in the crawl results.
+func (e *edgeFile) record(source string, links []Link) error {
+	rows := make([][]string, 0, len(links))
+	for _, link := range links {
+		rows = append(rows, []string{source, canonicalLink(link.URL), link.Text, strconv.FormatBool(link.NoFollow), link.Position})
+	}
+	return e.write(rows)
+}
+
+// write appends rows and flushes them, so the file is complete even if the process exits abruptly
+func (e *edgeFile) write(rows [][]string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	//Check if the rows could not be written
+	if err := e.writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("error writing edge list: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file
+func (e *edgeFile) Close() error {
+	return e.file.Close()
+}
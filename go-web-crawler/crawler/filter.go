@@ -0,0 +1,336 @@
+package crawler
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a compiled filter expression that decides which results are output
+type Filter func(Result) bool
+
+// valueKind is the type of a filter expression operand
+type valueKind int
+
+const (
+	numberKind valueKind = iota
+	stringKind
+	boolKind
+)
+
+// operand evaluates one side of a comparison against a result
+type operand struct {
+	kind    valueKind
+	literal bool //Whether the operand is a literal, whose value doesn't depend on the result
+	eval    func(Result) interface{}
+}
+
+// filterParser is a recursive descent parser for filter expressions such as
+// `status >= 400 && depth <= 2` or `url =~ "/blog/" || !(title == "")`
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// ParseFilter compiles a filter expression over Result fields. Field names are
+// case-insensitive; supported operators are == != < <= > >= =~ !~ && || ! and parentheses.
+// Durations such as Elapsed are compared in milliseconds, and duration literals like 500ms or 2s
+// are converted to milliseconds. An empty expression selects every result.
+func ParseFilter(expr string) (Filter, error) {
+	//Check if there is no expression, which filters nothing out
+	if strings.TrimSpace(expr) == "" {
+		return func(Result) bool { return true }, nil
+	}
+	tokens, err := tokenizeFilter(expr)
+	//Check if the expression contains invalid characters
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	//Check if the expression is malformed
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+	//Check if there is trailing input after a complete expression
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid filter %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+// tokenizeFilter splits a filter expression into identifiers, literals and operators
+func tokenizeFilter(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expr[i+1:], ch)
+			//Check if the string literal is terminated
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in filter %q", expr)
+			}
+			tokens = append(tokens, expr[i:i+end+2])
+			i += end + 2
+		case strings.ContainsRune("=!<>&|~", rune(ch)):
+			j := i + 1
+			//Check if the operator has two characters
+			if j < len(expr) && strings.ContainsRune("=&|~", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, string(ch))
+			i++
+		case unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_' || ch == '.' || ch == '-':
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) || expr[j] == '_' || expr[j] == '.' || expr[j] == '-') {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in filter %q", ch, expr)
+		}
+	}
+	return tokens, nil
+}
+
+// peek returns the next token without consuming it
+func (p *filterParser) peek() string {
+	//Check if all tokens were consumed
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token
+func (p *filterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// parseOr parses a sequence of && expressions joined by ||
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r Result) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+// parseAnd parses a sequence of unary expressions joined by &&
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r Result) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+// parseUnary parses negation, parenthesized expressions and comparisons
+func (p *filterParser) parseUnary() (Filter, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r Result) bool { return !inner(r) }, nil
+	case "(":
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		//Check if the parenthesis is closed
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing \")\"")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses `operand op operand`, or a lone boolean operand
+func (p *filterParser) parseComparison() (Filter, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		p.next()
+	default:
+		//Check if the lone operand can be used as a condition
+		if left.kind != boolKind {
+			return nil, fmt.Errorf("expected comparison operator, got %q", op)
+		}
+		return func(r Result) bool { return left.eval(r).(bool) }, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compare(left, op, right)
+}
+
+// parseOperand parses a field name or a number, string or boolean literal
+func (p *filterParser) parseOperand() (operand, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return operand{}, fmt.Errorf("unexpected end of expression")
+	case token[0] == '"' || token[0] == '\'':
+		value := token[1 : len(token)-1]
+		return operand{kind: stringKind, literal: true, eval: func(Result) interface{} { return value }}, nil
+	case token == "true" || token == "false":
+		value := token == "true"
+		return operand{kind: boolKind, literal: true, eval: func(Result) interface{} { return value }}, nil
+	}
+	//Check if the token is a number literal
+	if number, err := strconv.ParseFloat(token, 64); err == nil {
+		return operand{kind: numberKind, literal: true, eval: func(Result) interface{} { return number }}, nil
+	}
+	//Check if the token is a duration literal, which is compared in milliseconds
+	if duration, err := time.ParseDuration(token); err == nil {
+		number := durationMillis(duration)
+		return operand{kind: numberKind, literal: true, eval: func(Result) interface{} { return number }}, nil
+	}
+	return resultField(token)
+}
+
+// resultField returns an operand reading the Result field with the given case-insensitive name
+func resultField(name string) (operand, error) {
+	field, ok := reflect.TypeOf(Result{}).FieldByNameFunc(func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+	//Check if the field exists
+	if !ok {
+		return operand{}, fmt.Errorf("unknown field %q", name)
+	}
+	index := field.Index
+	//Check if the field is a duration, which is compared in milliseconds like elapsed_ms in the JSON output
+	if field.Type == reflect.TypeOf(time.Duration(0)) {
+		return operand{kind: numberKind, eval: func(r Result) interface{} {
+			return durationMillis(time.Duration(reflect.ValueOf(r).FieldByIndex(index).Int()))
+		}}, nil
+	}
+	switch field.Type.Kind() {
+	case reflect.Int, reflect.Int64:
+		return operand{kind: numberKind, eval: func(r Result) interface{} {
+			return float64(reflect.ValueOf(r).FieldByIndex(index).Int())
+		}}, nil
+	case reflect.Float64:
+		return operand{kind: numberKind, eval: func(r Result) interface{} {
+			return reflect.ValueOf(r).FieldByIndex(index).Float()
+		}}, nil
+	case reflect.String:
+		return operand{kind: stringKind, eval: func(r Result) interface{} {
+			return reflect.ValueOf(r).FieldByIndex(index).String()
+		}}, nil
+	case reflect.Bool:
+		return operand{kind: boolKind, eval: func(r Result) interface{} {
+			return reflect.ValueOf(r).FieldByIndex(index).Bool()
+		}}, nil
+	}
+	return operand{}, fmt.Errorf("field %q cannot be used in filters", name)
+}
+
+// compare builds a filter comparing two operands with the given operator
+func compare(left operand, op string, right operand) (Filter, error) {
+	//Check if the operator is a regular expression match
+	if op == "=~" || op == "!~" {
+		//Check if the pattern is a string literal applied to a string operand
+		if left.kind != stringKind || right.kind != stringKind || !right.literal {
+			return nil, fmt.Errorf("%s requires a string operand and a string literal pattern", op)
+		}
+		pattern, err := regexp.Compile(right.eval(Result{}).(string))
+		//Check if the regular expression is invalid
+		if err != nil {
+			return nil, err
+		}
+		negate := op == "!~"
+		return func(r Result) bool {
+			return pattern.MatchString(left.eval(r).(string)) != negate
+		}, nil
+	}
+	//Check if both sides have the same type
+	if left.kind != right.kind {
+		return nil, fmt.Errorf("cannot compare operands of different types with %s", op)
+	}
+	//Check if ordering is requested on booleans
+	if left.kind == boolKind && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("%s cannot be applied to booleans", op)
+	}
+	return func(r Result) bool {
+		a, b := left.eval(r), right.eval(r)
+		cmp := 0
+		switch a := a.(type) {
+		case float64:
+			b := b.(float64)
+			//Check how the numbers are ordered
+			if a < b {
+				cmp = -1
+			} else if a > b {
+				cmp = 1
+			}
+		case string:
+			cmp = strings.Compare(a, b.(string))
+		case bool:
+			//Check if the booleans differ
+			if a != b.(bool) {
+				cmp = 1
+			}
+		}
+		switch op {
+		case "==":
+			return cmp == 0
+		case "!=":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}, nil
+}
+
+// durationMillis converts a duration to fractional milliseconds
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,113 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fragmentRef is a link from a page to a fragment of a document
+type fragmentRef struct {
+	source   string //URL of the page holding the link
+	fragment string //Decoded fragment the link points at
+}
+
+// fragmentCheck verifies that links to fragments, e.g. /page#section, point at an element id or
+// anchor name of the target document. Links and anchors are collected while pages are crawled and
+// compared once the crawl ends, since a target may be crawled after the pages linking to it.
+type fragmentCheck struct {
+	mutex   sync.Mutex                          //Protects the maps below for concurrent workers
+	anchors map[string]map[string]bool          //Ids and anchor names by URL of the crawled document, without fragment
+	links   map[string]map[fragmentRef]struct{} //Links to fragments by URL of the target document, without fragment
+}
+
+// newFragmentCheck creates an empty fragment check
+func newFragmentCheck() *fragmentCheck {
+	return &fragmentCheck{anchors: make(map[string]map[string]bool), links: make(map[string]map[fragmentRef]struct{})}
+}
+
+// splitFragment returns the canonical URL of a link's document and its fragment. Fragments that
+// do not name an element are returned empty: "top", text fragments (#:~:text=) and the routes of
+// single-page applications (#!/path or #/path).
+func splitFragment(link string) (string, string) {
+	u, err := url.Parse(link)
+	//Check if the link cannot be parsed
+	if err != nil {
+		return link, ""
+	}
+	fragment := u.Fragment
+	u.Fragment, u.RawFragment = "", ""
+	canonicalizeURL(u)
+	//Check if the fragment is handled by the browser or a script rather than by an element
+	if strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, ":~:") || strings.HasPrefix(fragment, "!") || strings.HasPrefix(fragment, "/") {
+		fragment = ""
+	}
+	return u.String(), fragment
+}
+
+// recordAnchors stores the anchors of a crawled document under the URL it was requested by and
+// the URL it came from after redirects. Documents whose handler does not collect anchors are
+// skipped, so links to their fragments are not verified.
+func (f *fragmentCheck) recordAnchors(pageURL, finalURL string, page *Page) {
+	//Check if the document's anchors are unknown
+	if page.Anchors == nil {
+		return
+	}
+	requested, _ := splitFragment(pageURL)
+	final, _ := splitFragment(finalURL)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.anchors[requested], f.anchors[final] = page.Anchors, page.Anchors
+}
+
+// recordLinks stores the links to fragments found on a page
+func (f *fragmentCheck) recordLinks(pageURL string, links []Link) {
+	source, _ := splitFragment(pageURL)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, link := range links {
+		target, fragment := splitFragment(link.URL)
+		//Check if the link points at a whole document or a frame
+		if fragment == "" || link.Frame {
+			continue
+		}
+		refs, ok := f.links[target]
+		//Check if this is the first link to a fragment of the target
+		if !ok {
+			refs = make(map[fragmentRef]struct{})
+			f.links[target] = refs
+		}
+		refs[fragmentRef{source: source, fragment: fragment}] = struct{}{}
+	}
+}
+
+// broken returns an error for every link whose fragment is missing from the crawled target
+// document, sorted by target and source. Links to documents that were not crawled are not
+// verified.
+func (f *fragmentCheck) broken() []error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var errs []string
+	for target, refs := range f.links {
+		anchors, ok := f.anchors[target]
+		//Check if the target was not crawled
+		if !ok {
+			continue
+		}
+		for ref := range refs {
+			//Check if the target has no element with the fragment as id or name
+			if !anchors[ref.fragment] {
+				errs = append(errs, fmt.Sprintf("broken fragment %s#%s linked from %s: no element with that id or name", target, ref.fragment, ref.source))
+			}
+		}
+	}
+	sort.Strings(errs)
+	broken := make([]error, 0, len(errs))
+	for _, err := range errs {
+		broken = append(broken, errors.New(err))
+	}
+	return broken
+}
@@ -0,0 +1,345 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LinkMeta describes where a link was discovered, for use by a Prioritizer
+type LinkMeta struct {
+	Parent     string //URL of the page the link was found on, or of the page framing it, empty for seeds
+	AnchorText string //Text content of the anchor element
+	Frame      bool   //Set for <frame> and <iframe> sources, which are crawled at their parent's depth
+	Seed       string //Start URL the link was discovered from, which decides its scope and settings
+	Asset      bool   //Set for resources a page loads, which are only checked for their status at the page's depth
+}
+
+// Prioritizer scores a frontier entry; entries with higher scores are crawled first
+type Prioritizer func(url string, depth int, meta LinkMeta) float64
+
+// SubstringPrioritizer scores URL's by how many of the given substrings they contain
+func SubstringPrioritizer(substrings []string) Prioritizer {
+	return func(url string, depth int, meta LinkMeta) float64 {
+		score := 0.0
+		for _, substring := range substrings {
+			//Check if the URL contains the substring
+			if substring != "" && strings.Contains(url, substring) {
+				score++
+			}
+		}
+		return score
+	}
+}
+
+// frontierItem is a URL waiting to be crawled
+type frontierItem struct {
+	url      string   //Normalized URL to crawl
+	depth    int      //Depth at which the URL was discovered
+	meta     LinkMeta //Discovery metadata
+	priority float64  //Score assigned by the prioritizer
+	seq      uint64   //Insertion order, used to keep equal priorities first-in first-out
+	external bool     //Set for off-site links that are only checked for their status
+	host     string   //Lower-case host of the URL, which per-host limits apply to
+}
+
+// ranksBefore reports whether an item with priority p1 and sequence number s1 is crawled before one with p2 and s2
+func ranksBefore(p1 float64, s1 uint64, p2 float64, s2 uint64) bool {
+	//Check if both items have the same priority
+	if p1 == p2 {
+		return s1 < s2
+	}
+	return p1 > p2
+}
+
+// spilledItem is a frontierItem as written to disk under memory pressure
+type spilledItem struct {
+	URL      string  `json:"url"`                //Normalized URL to crawl
+	Depth    int     `json:"depth"`              //Depth at which the URL was discovered
+	Parent   string  `json:"parent,omitempty"`   //Page the link was found on
+	Anchor   string  `json:"anchor,omitempty"`   //Anchor text of the link
+	Frame    bool    `json:"frame,omitempty"`    //Set for frame sources
+	Seed     string  `json:"seed,omitempty"`     //Start URL the link was discovered from
+	Priority float64 `json:"priority,omitempty"` //Score assigned by the prioritizer
+	Seq      uint64  `json:"seq"`                //Insertion order
+	External bool    `json:"external,omitempty"` //Set for off-site links that are only checked
+}
+
+// spilledBefore orders spilled items like frontierHeap orders items in memory
+func spilledBefore(a, b spilledItem) bool {
+	return ranksBefore(a.Priority, a.Seq, b.Priority, b.Seq)
+}
+
+// frontierHeap implements heap.Interface ordered by priority, then insertion order
+type frontierHeap []*frontierItem
+
+func (h frontierHeap) Len() int { return len(h) }
+func (h frontierHeap) Less(i, j int) bool {
+	return ranksBefore(h[i].priority, h[i].seq, h[j].priority, h[j].seq)
+}
+func (h frontierHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *frontierHeap) Push(x interface{}) { *h = append(*h, x.(*frontierItem)) }
+func (h *frontierHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// frontier is a blocking priority queue of URLs waiting to be crawled. It counts items that
+// were pushed but not yet finished and closes itself once that count drops to zero.
+type frontier struct {
+	mutex   sync.Mutex   //Protects the heap, pending count and closed flag
+	cond    *sync.Cond   //Signals workers when items arrive or the frontier closes
+	items   frontierHeap //Pending items
+	seq     uint64       //Next insertion sequence number
+	pending int          //Items pushed or held but not yet finished
+	closed  bool         //Set once no more items will be popped
+
+	// Per-host concurrency
+	hostLimit int            //Most items of one host popped and not yet finished, 0 for no limit
+	active    map[string]int //Popped items not yet finished by host, if hostLimit is set
+
+	// Items spilled to disk under memory pressure
+	runs    []*spillRun[spilledItem] //Sorted runs, merged back into the heap as they come up
+	spilled int                      //Items in runs, or abandoned in them by close
+	err     error                    //Error that lost spilled items, reported when the crawl ends
+}
+
+// newFrontier creates an empty frontier
+func newFrontier() *frontier {
+	f := &frontier{active: make(map[string]int)}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+// push adds an item to the frontier and wakes one waiting worker
+func (f *frontier) push(item *frontierItem) {
+	f.mutex.Lock()
+	item.seq = f.seq
+	f.seq++
+	f.pending++
+	heap.Push(&f.items, item)
+	f.mutex.Unlock()
+	f.cond.Signal()
+}
+
+// pop blocks until an item is available and returns it, or returns false once the frontier is closed.
+// Every popped item must be released with done.
+func (f *frontier) pop() (*frontierItem, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for {
+		for len(f.items) == 0 && f.spilled == 0 && !f.closed {
+			f.cond.Wait()
+		}
+		//Check if the frontier was closed while waiting
+		if f.closed {
+			return nil, false
+		}
+		f.refill()
+		//Check if an item is available, which is only not the case if a spilled run was lost
+		if len(f.items) > 0 {
+			i := f.next()
+			//Check if every queued host is at its limit, in which case one of its items has to finish first
+			if i < 0 {
+				f.cond.Wait()
+				continue
+			}
+			item := heap.Remove(&f.items, i).(*frontierItem)
+			//Check if concurrent items are limited per host
+			if f.hostLimit > 0 {
+				f.active[item.host]++
+			}
+			return item, true
+		}
+		//Check if the lost run was the only work left
+		if f.pending == 0 {
+			f.closed = true
+			f.cond.Broadcast()
+		}
+	}
+}
+
+// next returns the index of the best-ranked item whose host is below the per-host limit, or -1 if
+// there is none; the caller must hold the mutex
+func (f *frontier) next() int {
+	//Check if the best-ranked item may be popped, which is always the case without a limit
+	if f.hostLimit <= 0 || f.active[f.items[0].host] < f.hostLimit {
+		return 0
+	}
+	best := -1
+	for i, item := range f.items {
+		//Check if the item's host is busy or the item ranks after the best one found
+		if f.active[item.host] >= f.hostLimit || (best >= 0 && !ranksBefore(item.priority, item.seq, f.items[best].priority, f.items[best].seq)) {
+			continue
+		}
+		best = i
+	}
+	return best
+}
+
+// refill moves spilled items that rank before everything in memory back into the heap; the caller
+// must hold the mutex
+func (f *frontier) refill() {
+	for {
+		i := nextSpilled(f.runs, spilledBefore)
+		//Check if the best spilled item ranks after the best item in memory
+		if i < 0 || (len(f.items) > 0 && !ranksBefore(f.runs[i].head.Priority, f.runs[i].head.Seq, f.items[0].priority, f.items[0].seq)) {
+			break
+		}
+		run := f.runs[i]
+		head := run.head
+		heap.Push(&f.items, &frontierItem{
+			url:      head.URL,
+			depth:    head.Depth,
+			meta:     LinkMeta{Parent: head.Parent, AnchorText: head.Anchor, Frame: head.Frame, Seed: head.Seed},
+			priority: head.Priority,
+			seq:      head.Seq,
+			external: head.External,
+			host:     urlHost(head.URL),
+		})
+		f.spilled--
+		//Check if the rest of the run could not be read back
+		if err := run.advance(); err != nil {
+			f.spilled -= run.remaining
+			f.pending -= run.remaining
+			run.remaining = 0
+			run.close()
+			//Check if this is the first lost run
+			if f.err == nil {
+				f.err = fmt.Errorf("lost queued URL's: %w", err)
+			}
+		}
+	}
+	// Drop exhausted runs
+	runs := f.runs[:0]
+	for _, run := range f.runs {
+		//Check if the run has items left
+		if run.remaining > 0 {
+			runs = append(runs, run)
+		}
+	}
+	f.runs = runs
+}
+
+// spill moves all but the keep best-ranked items to disk and returns how many were moved. Nothing
+// is moved unless at least keep items would be, so spilling never opens many tiny runs.
+func (f *frontier) spill(keep int) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	//Check if the frontier is small enough already, or will not be popped from again
+	if len(f.items) < 2*keep || f.closed {
+		return 0, nil
+	}
+	sort.Sort(f.items) // A sorted slice is still a valid heap
+	items := make([]spilledItem, 0, len(f.items)-keep)
+	for _, item := range f.items[keep:] {
+		items = append(items, spilledItem{
+			URL:      item.url,
+			Depth:    item.depth,
+			Parent:   item.meta.Parent,
+			Anchor:   item.meta.AnchorText,
+			Frame:    item.meta.Frame,
+			Seed:     item.meta.Seed,
+			Priority: item.priority,
+			Seq:      item.seq,
+			External: item.external,
+		})
+	}
+	run, err := writeSpillRun(items)
+	//Check if the items could not be spilled
+	if err != nil {
+		return 0, err
+	}
+	f.runs = append(f.runs, run)
+	f.spilled += len(items)
+	f.items = append(frontierHeap(nil), f.items[:keep]...) // Copy, so the spilled items can be collected
+	return len(items), nil
+}
+
+// spillError returns the error that lost spilled items, if any
+func (f *frontier) spillError() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// len returns the number of items waiting to be popped, including spilled ones
+func (f *frontier) len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.items) + f.spilled
+}
+
+// hold keeps the frontier open while seeds are being queued, until released with done
+func (f *frontier) hold() {
+	f.mutex.Lock()
+	f.pending++
+	f.mutex.Unlock()
+}
+
+// finish marks a popped item as finished, letting another item of its host be popped
+func (f *frontier) finish(item *frontierItem) {
+	f.mutex.Lock()
+	limited := f.hostLimit > 0
+	//Check if concurrent items are limited per host
+	if limited {
+		//Check if this was the host's last active item
+		if f.active[item.host]--; f.active[item.host] <= 0 {
+			delete(f.active, item.host)
+		}
+	}
+	f.mutex.Unlock()
+	//Check if workers may be waiting for the host's limit
+	if limited {
+		f.cond.Broadcast()
+	}
+	f.done()
+}
+
+// tryHold holds the frontier open like hold, unless it is closed already, and reports whether it did
+func (f *frontier) tryHold() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	//Check if the frontier can no longer be held open
+	if f.closed {
+		return false
+	}
+	f.pending++
+	return true
+}
+
+// isClosed reports whether the frontier was closed, after which nothing is popped from it
+func (f *frontier) isClosed() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.closed
+}
+
+// done marks a popped item or a hold as finished, closing the frontier when no work remains
+func (f *frontier) done() {
+	f.mutex.Lock()
+	f.pending--
+	idle := f.pending == 0
+	f.mutex.Unlock()
+	//Check if the crawl ran out of work
+	if idle {
+		f.close()
+	}
+}
+
+// close releases all workers blocked in pop; items still queued are abandoned
+func (f *frontier) close() {
+	f.mutex.Lock()
+	f.closed = true
+	for _, run := range f.runs {
+		run.close()
+	}
+	f.runs = nil
+	f.mutex.Unlock()
+	f.cond.Broadcast()
+}
@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// parseHeaderNames splits a comma-separated list of header names into lower-case names, dropping blanks
+func parseHeaderNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		//Check if the entry is not blank
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// captureHeaders returns the configured response headers by lower-case name, joining repeated
+// values with ", ". It returns nil when no headers are configured or none of them are present.
+func (c *Crawler) captureHeaders(header http.Header) map[string]string {
+	//Check if header capture is disabled
+	if len(c.headerNames) == 0 {
+		return nil
+	}
+	var captured map[string]string
+	add := func(name string, values []string) {
+		//Check if this is the first captured header
+		if captured == nil {
+			captured = make(map[string]string)
+		}
+		captured[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	for _, name := range c.headerNames {
+		//Check if all headers should be recorded
+		if name == "*" {
+			for key, values := range header {
+				add(key, values)
+			}
+			return captured
+		}
+		//Check if the response has the header
+		if values := header.Values(name); len(values) > 0 {
+			add(name, values)
+		}
+	}
+	return captured
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hookInput is the JSON document a page hook receives on stdin: the page's result as written by
+// -format json, plus the links found on the page
+type hookInput struct {
+	resultRecord
+	Links []string `json:"links"` //Absolute URL's of the links on the page, empty if it was not parsed
+}
+
+// hookDirectives is the JSON document a page hook may print to stdout to steer the crawl. Unknown
+// properties are ignored and empty output means no directives.
+type hookDirectives struct {
+	SkipLinks   bool              `json:"skip_links"`  //Don't follow any link of the page
+	DropLinks   []string          `json:"drop_links"`  //Links of the page not to follow
+	AddURLs     []string          `json:"add_urls"`    //Further URL's to queue as if linked from the page, absolute or relative to it
+	Annotations map[string]string `json:"annotations"` //Values attached to the page's result
+}
+
+// follows reports whether the directives let a link of the page be followed
+func (d *hookDirectives) follows(link string) bool {
+	//Check if all links are skipped
+	if d.SkipLinks {
+		return false
+	}
+	for _, dropped := range d.DropLinks {
+		//Check if the link is dropped
+		if dropped == link {
+			return false
+		}
+	}
+	return true
+}
+
+// runPageHook runs the -hook-on-page command for a crawled page, passing it the result and the
+// page's links as JSON on stdin, and returns the directives it printed. A failing hook is logged
+// and leaves the crawl unchanged.
+func (c *Crawler) runPageHook(ctx context.Context, result Result, page *Page) hookDirectives {
+	input := hookInput{resultRecord: newResultRecord(result), Links: []string{}}
+	//Check if the page was parsed for links
+	if page != nil {
+		for _, link := range page.Links {
+			input.Links = append(input.Links, link.URL)
+		}
+	}
+	stdin, err := json.Marshal(input)
+	//Check if the input could not be encoded
+	if err != nil {
+		c.logf(-1, "[%s] page hook for %s: %v", result.RequestID, result.URL, err)
+		return hookDirectives{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.pageHook[0], c.pageHook[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	started := time.Now()
+	//Check if the hook failed or timed out
+	if err := cmd.Run(); err != nil {
+		//Check if the hook explained its failure
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		c.logf(-1, "[%s] page hook for %s failed: %v", result.RequestID, result.URL, err)
+		return hookDirectives{}
+	}
+	c.logf(2, "[%s] page hook for %s took %s", result.RequestID, result.URL, time.Since(started).Round(time.Millisecond))
+	var directives hookDirectives
+	//Check if the hook printed nothing
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return directives
+	}
+	//Check if the hook's output is not a directives document
+	if err := json.Unmarshal(stdout.Bytes(), &directives); err != nil {
+		c.logf(-1, "[%s] page hook for %s printed invalid directives: %v", result.RequestID, result.URL, err)
+		return hookDirectives{}
+	}
+	return directives
+}
+
+// annotate attaches values to a result's annotations, replacing earlier values of the same name
+func annotate(result *Result, values map[string]string) {
+	for name, value := range values {
+		//Check if this is the result's first annotation
+		if result.Annotations == nil {
+			result.Annotations = make(map[string]string)
+		}
+		result.Annotations[name] = value
+	}
+}
+
+// parseHookCommand splits a -hook-on-page value into the program and its arguments
+func parseHookCommand(command string) ([]string, error) {
+	fields := strings.Fields(command)
+	//Check if the command is empty
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty hook command")
+	}
+	//Check if the program cannot be found
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return nil, fmt.Errorf("error finding hook %s: %w", fields[0], err)
+	}
+	return fields, nil
+}
+
+// queueHookURLs queues the URL's a page hook added, resolved against the page's URL
+func (c *Crawler) queueHookURLs(pageURL string, depth int, meta LinkMeta, links []string) {
+	base, err := url.Parse(pageURL)
+	//Check if the page URL cannot be resolved against
+	if err != nil {
+		return
+	}
+	for _, link := range links {
+		absolute, err := NormalizeURL(link, base)
+		//Check if the added URL is invalid
+		if err != nil {
+			c.logf(1, "page hook for %s added invalid URL %q: %v", pageURL, link, err)
+			continue
+		}
+		c.enqueue(absolute, depth+1, LinkMeta{Parent: pageURL, Seed: meta.Seed})
+	}
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// hostMapTransport sends requests for mapped hosts to their substitutes while the rest of the
+// crawler, and its output, keep seeing the logical URL's
+type hostMapTransport struct {
+	next     http.RoundTripper //Transport that performs the rewritten requests
+	hosts    map[string]string //Substitute host by lower-case logical host, with or without a port
+	keepHost bool              //Whether the logical host is sent in the Host header
+	reverse  map[string]string //Logical host by lower-case substitute host, for rewriting redirects
+}
+
+// parseHostMap parses comma-separated "logical=substitute" host pairs
+func parseHostMap(list string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		//Check if the entry is blank
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.ToLower(strings.TrimSpace(from)), strings.TrimSpace(to)
+		//Check if the pair is incomplete
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid host mapping %q (expected host=substitute)", pair)
+		}
+		hosts[from] = to
+	}
+	return hosts, nil
+}
+
+// mapHosts routes requests for the given logical hosts to their substitutes, optionally still
+// sending the logical host in the Host header so virtual hosts on the substitute match
+func (c *Crawler) mapHosts(hosts map[string]string, keepHost bool) {
+	reverse := make(map[string]string, len(hosts))
+	for from, to := range hosts {
+		reverse[strings.ToLower(to)] = from
+	}
+	c.client.Transport = &hostMapTransport{next: c.client.Transport, hosts: hosts, keepHost: keepHost, reverse: reverse}
+}
+
+// RoundTrip rewrites the host of a mapped request, and of absolute redirects pointing back at the substitute
+func (t *hostMapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	to, ok := t.hosts[strings.ToLower(req.URL.Host)]
+	//Check if the host is mapped with any port
+	if !ok {
+		to, ok = t.hosts[strings.ToLower(req.URL.Hostname())]
+		//Check if the substitute keeps the original port
+		if ok && req.URL.Port() != "" && !strings.Contains(to, ":") {
+			to += ":" + req.URL.Port()
+		}
+	}
+	//Check if the request is for a mapped host
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	mapped := req.Clone(req.Context())
+	mapped.URL.Host = to
+	mapped.Host = to
+	//Check if the substitute should see the logical host
+	if t.keepHost {
+		mapped.Host = req.URL.Host
+	}
+	resp, err := t.next.RoundTrip(mapped)
+	//Check if the request failed
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	//Check if the substitute redirected to itself by absolute URL
+	if location, err := resp.Location(); err == nil {
+		//Check if the redirect targets the substitute host
+		if from, ok := t.reverse[strings.ToLower(location.Host)]; ok {
+			location.Host = from
+			resp.Header.Set("Location", location.String())
+		}
+	}
+	return resp, nil
+}
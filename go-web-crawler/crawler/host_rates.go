@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiters gives hosts rate limiters of their own, so the hosts of a multi-site crawl are not
+// throttled together by one shared limit. Hosts get the default rate unless an override matches
+// them; with a default of 0, hosts without an override share the crawl's limiter.
+type hostLimiters struct {
+	mutex     sync.Mutex               //Protects perHost and hosts
+	perHost   rate.Limit               //Requests per second of hosts without an override, 0 to share the crawl's limiter
+	overrides map[string]rate.Limit    //Requests per second by lower-case host, host:port, *.domain or *
+	hosts     map[string]*rate.Limiter //Limiters by lower-case host, nil for hosts sharing the crawl's
+}
+
+// newHostLimiters creates limiters running each host at perHost requests per second, or at the
+// rate of its override
+func newHostLimiters(perHost float64, overrides map[string]rate.Limit) *hostLimiters {
+	return &hostLimiters{perHost: rate.Limit(perHost), overrides: overrides, hosts: make(map[string]*rate.Limiter)}
+}
+
+// parseHostRates parses comma-separated host=rate pairs, e.g. "example.com=10,*.example.org=0.5"
+func parseHostRates(list string) (map[string]rate.Limit, error) {
+	rates := make(map[string]rate.Limit)
+	for _, entry := range strings.Split(list, ",") {
+		//Check if the entry is blank
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		perSecond, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		//Check if the entry is not a host with a positive rate
+		if !ok || host == "" || err != nil || perSecond <= 0 {
+			return nil, fmt.Errorf("invalid host rate %q (expected host=requests per second)", strings.TrimSpace(entry))
+		}
+		//Check if a wildcard is used other than for every host or in front of a domain
+		if host != "*" && strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return nil, fmt.Errorf("invalid host rate %q (a wildcard is only allowed as * or *.domain)", strings.TrimSpace(entry))
+		}
+		rates[host] = rate.Limit(perSecond)
+	}
+	return rates, nil
+}
+
+// override returns the rate of the most specific override matching a host; the caller must hold
+// the mutex
+func (l *hostLimiters) override(host string) (rate.Limit, bool) {
+	for _, key := range hostKeys(&url.URL{Host: host}) {
+		//Check if the key has a rate of its own
+		if limit, ok := l.overrides[key]; ok {
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
+// limiter returns the limiter of a host, creating it on the host's first request, or nil if the
+// host shares the crawl's limiter
+func (l *hostLimiters) limiter(host string) *rate.Limiter {
+	//Check if the link has no host, e.g. a local file
+	if host == "" {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	//Check if the host was seen before
+	if limiter, ok := l.hosts[host]; ok {
+		return limiter
+	}
+	limit, ok := l.override(host)
+	//Check if the host has no override, which runs it at the default rate
+	if !ok {
+		limit = l.perHost
+	}
+	var limiter *rate.Limiter
+	//Check if the host gets a limiter of its own
+	if limit > 0 {
+		limiter = rate.NewLimiter(limit, 1)
+	}
+	l.hosts[host] = limiter
+	return limiter
+}
+
+// setDefault changes the rate of hosts without an override, including those already crawled, and
+// reports whether there is such a rate; hosts sharing the crawl's limiter are left alone
+func (l *hostLimiters) setDefault(limit rate.Limit) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	//Check if hosts without an override share the crawl's limiter
+	if l.perHost == 0 {
+		return false
+	}
+	l.perHost = limit
+	for host, limiter := range l.hosts {
+		//Check if the host runs at the default rate
+		if _, ok := l.override(host); !ok && limiter != nil {
+			limiter.SetLimit(limit)
+		}
+	}
+	return true
+}
+
+// hostRate returns the requests per second the crawl sends to a host: its own rate, or the
+// crawl's if it shares the crawl's limiter
+func (c *Crawler) hostRate(host string) rate.Limit {
+	//Check if hosts may have rates of their own
+	if c.hostLimits != nil {
+		//Check if the host has a limiter of its own
+		if limiter := c.hostLimits.limiter(host); limiter != nil {
+			return limiter.Limit()
+		}
+	}
+	return c.limiter.Limit()
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hstsMaxAge caps the lifetime of a remembered policy, so huge max-age values cannot overflow
+const hstsMaxAge = 10 * 365 * 24 * time.Hour
+
+// hstsPolicy is the Strict-Transport-Security policy a host announced
+type hstsPolicy struct {
+	expires           time.Time //When the policy lapses unless the host renews it
+	includeSubDomains bool      //Whether the policy covers the host's subdomains too
+}
+
+// hstsStore remembers the hosts that announced Strict-Transport-Security over HTTPS, so later
+// http:// URL's of those hosts are requested over HTTPS like a browser would
+type hstsStore struct {
+	mutex    sync.Mutex            //Protects policies
+	policies map[string]hstsPolicy //Policies by lower-case host name without port
+}
+
+// newHSTSStore creates an empty store
+func newHSTSStore() *hstsStore {
+	return &hstsStore{policies: make(map[string]hstsPolicy)}
+}
+
+// record remembers the policies announced by a response and the redirects that led to it.
+// Headers of plain HTTP responses are ignored, since anyone on the path could have added them.
+func (s *hstsStore) record(resp *http.Response) {
+	for ; resp != nil; resp = resp.Request.Response {
+		header := resp.Header.Get("Strict-Transport-Security")
+		//Check if the response announces a policy over a secure connection
+		if header == "" || resp.Request.URL.Scheme != "https" {
+			continue
+		}
+		maxAge, includeSubDomains, ok := parseHSTS(header)
+		//Check if the header is malformed, which makes browsers ignore it
+		if !ok {
+			continue
+		}
+		host := strings.ToLower(resp.Request.URL.Hostname())
+		s.mutex.Lock()
+		//Check if the host withdrew its policy
+		if maxAge <= 0 {
+			delete(s.policies, host)
+		} else {
+			s.policies[host] = hstsPolicy{expires: time.Now().Add(maxAge), includeSubDomains: includeSubDomains}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// parseHSTS parses a Strict-Transport-Security header, reporting whether it has a valid max-age
+func parseHSTS(header string) (time.Duration, bool, bool) {
+	var maxAge time.Duration
+	found, includeSubDomains := false, false
+	for _, directive := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			seconds, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(value), `"`), 10, 64)
+			//Check if the max-age is not a number of seconds
+			if err != nil || seconds < 0 {
+				return 0, false, false
+			}
+			maxAge, found = time.Duration(min(seconds, int64(hstsMaxAge/time.Second)))*time.Second, true
+		case "includesubdomains":
+			includeSubDomains = true
+		}
+	}
+	return maxAge, includeSubDomains, found
+}
+
+// covers reports whether a live policy applies to the host name, directly or through a parent
+// domain that includes its subdomains
+func (s *hstsStore) covers(host string) bool {
+	host = strings.ToLower(host)
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name := host; ; {
+		policy, ok := s.policies[name]
+		//Check if the name has a live policy covering the host
+		if ok && now.Before(policy.expires) && (name == host || policy.includeSubDomains) {
+			return true
+		}
+		_, parent, more := strings.Cut(name, ".")
+		//Check if there are no parent domains left
+		if !more {
+			return false
+		}
+		name = parent
+	}
+}
+
+// upgradeHTTPS switches an http:// URL on the default port to https:// if HTTPS is preferred for
+// all hosts or the host announced Strict-Transport-Security, and reports whether it did
+func (c *Crawler) upgradeHTTPS(u *url.URL) bool {
+	//Check if the URL is not plain HTTP on the default port, whose HTTPS counterpart is unambiguous
+	if u.Scheme != "http" || u.Port() != "" {
+		return false
+	}
+	//Check if neither option asks for the upgrade
+	if !c.preferHTTPS && (c.hsts == nil || !c.hsts.covers(u.Hostname())) {
+		return false
+	}
+	u.Scheme = "https"
+	return true
+}
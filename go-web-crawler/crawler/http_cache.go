@@ -0,0 +1,444 @@
+package crawler
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cacheableStatuses are the status codes a cache may store without explicit freshness
+// information (RFC 7231 section 6.1, plus 308 from RFC 7538)
+var cacheableStatuses = map[int]bool{200: true, 203: true, 204: true, 300: true, 301: true, 308: true, 404: true, 405: true, 410: true, 414: true, 501: true}
+
+// cacheEntry describes a stored response. It is the first line of the response's cache file, followed by the body.
+type cacheEntry struct {
+	URL          string      `json:"url"`           //Request URL the response belongs to
+	Status       int         `json:"status"`        //Response status code
+	Header       http.Header `json:"header"`        //Response headers
+	Vary         http.Header `json:"vary"`          //Values of the request headers named by Vary when the response was stored
+	RequestTime  time.Time   `json:"request_time"`  //When the request that produced or last revalidated the response was sent
+	ResponseTime time.Time   `json:"response_time"` //When the response to that request arrived
+}
+
+// httpCache is a shared HTTP cache following RFC 7234, stored on disk so it outlives the crawl.
+// Fresh responses are served without contacting the server, stale ones are revalidated with
+// their ETag or Last-Modified date, and Vary is honored by storing the request headers a
+// response varies on and ignoring it for requests that differ. One variant is kept per URL.
+type httpCache struct {
+	dir  string            //Directory holding one file per cached URL
+	next http.RoundTripper //Transport that sends requests the cache cannot answer
+
+	hits        atomic.Int64 //Requests answered from the cache without contacting the server
+	revalidated atomic.Int64 //Requests answered from the cache after the server replied 304 Not Modified
+	misses      atomic.Int64 //Requests answered with a new response from the server
+}
+
+// openHTTPCache creates the cache directory if needed and returns a cache in front of next
+func openHTTPCache(dir string, next http.RoundTripper) (*httpCache, error) {
+	//Check if the cache directory could not be created
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating HTTP cache: %w", err)
+	}
+	return &httpCache{dir: dir, next: next}, nil
+}
+
+// useHTTPCache puts an on-disk HTTP cache in front of the crawler's transport
+func (c *Crawler) useHTTPCache(dir string) error {
+	cache, err := openHTTPCache(dir, c.client.Transport)
+	//Check if the cache could not be opened
+	if err != nil {
+		return err
+	}
+	c.httpCache = cache
+	c.client.Transport = cache
+	return nil
+}
+
+// path returns the cache file for a URL
+func (h *httpCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(h.dir, name[:2], name)
+}
+
+// load reads the stored response for a URL. It returns nil if there is none or the file is
+// damaged, and otherwise the open cache file positioned at the start of the body.
+func (h *httpCache) load(key string) (*cacheEntry, *os.File) {
+	file, err := os.Open(h.path(key))
+	//Check if nothing is stored for the URL
+	if err != nil {
+		return nil, nil
+	}
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	var entry cacheEntry
+	//Check if the entry is incomplete, damaged or belongs to another URL with the same hash
+	if err != nil || json.Unmarshal(line, &entry) != nil || entry.URL != key {
+		file.Close()
+		return nil, nil
+	}
+	//Check if the body cannot be reached
+	if _, err := file.Seek(int64(len(line)), io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil
+	}
+	return &entry, file
+}
+
+// create opens a temporary file next to the URL's cache file and writes the entry to it. The
+// caller appends the body and renames the file to path to replace the stored response atomically.
+func (h *httpCache) create(entry *cacheEntry) (file *os.File, path string, err error) {
+	path = h.path(entry.URL)
+	//Check if the cache subdirectory could not be created
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, "", err
+	}
+	file, err = os.CreateTemp(filepath.Dir(path), "tmp-*")
+	//Check if the temporary file could not be created
+	if err != nil {
+		return nil, "", err
+	}
+	line, err := json.Marshal(entry)
+	//Check if the entry could not be encoded or written
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", err
+	}
+	return file, path, nil
+}
+
+// store writes an entry with the given body to the cache
+func (h *httpCache) store(entry *cacheEntry, body io.Reader) error {
+	file, path, err := h.create(entry)
+	//Check if the cache file could not be started
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	//Check if the body was copied but the file could not be closed
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	//Check if the file is complete and can replace the stored response
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// fresh reports whether a fresh response is stored for the URL, which the crawler then fetches
+// without waiting for the rate limiter. Vary is only checked when the response is served, so a
+// request for another variant may skip the wait too.
+func (h *httpCache) fresh(link string) bool {
+	//Check if there is no cache
+	if h == nil {
+		return false
+	}
+	entry, body := h.load(link)
+	//Check if nothing is stored for the URL
+	if entry == nil {
+		return false
+	}
+	body.Close()
+	return entry.fresh(time.Now())
+}
+
+// RoundTrip answers GET and HEAD requests from the cache where possible and stores cacheable responses
+func (h *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	//Check if the request cannot be answered from the cache
+	if req.Method != http.MethodGet && req.Method != http.MethodHead || req.URL.Scheme != "http" && req.URL.Scheme != "https" || req.Header.Get("Range") != "" {
+		return h.next.RoundTrip(req)
+	}
+	key := req.URL.String()
+	entry, body := h.load(key)
+	//Check if the stored response is for a different variant of the URL
+	if entry != nil && !entry.matches(req) {
+		body.Close()
+		entry, body = nil, nil
+	}
+	//Check if the stored response can be served as is
+	if entry != nil && entry.fresh(time.Now()) {
+		h.hits.Add(1)
+		return entry.response(req, body), nil
+	}
+
+	outgoing := req
+	etag, lastModified := "", ""
+	//Check if there is a stale response that could be revalidated
+	if entry != nil {
+		etag, lastModified = entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+	}
+	//Check if the stale response can be revalidated instead of downloaded again
+	if etag != "" || lastModified != "" {
+		outgoing = req.Clone(req.Context())
+		//Check if the response has an entity tag
+		if etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		//Check if the response has a modification date
+		if lastModified != "" {
+			outgoing.Header.Set("If-Modified-Since", lastModified)
+		}
+	} else if entry != nil {
+		body.Close()
+		entry, body = nil, nil
+	}
+
+	requestTime := time.Now()
+	resp, err := h.next.RoundTrip(outgoing)
+	//Check if the request failed
+	if err != nil {
+		//Check if a stale response was held open for revalidation
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	//Check if the server confirmed the stale response
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return h.revalidate(req, entry, body, resp.Header, requestTime)
+	}
+	//Check if a stale response was held open for revalidation
+	if body != nil {
+		body.Close()
+	}
+	h.misses.Add(1)
+	//Check if the new response may be stored
+	if req.Method == http.MethodGet && storable(req, resp) {
+		entry := &cacheEntry{URL: key, Status: resp.StatusCode, Header: resp.Header.Clone(), Vary: varyValues(req, resp.Header), RequestTime: requestTime, ResponseTime: time.Now()}
+		//Check if the response has no date, which the cache supplies for computing its age
+		if entry.Header.Get("Date") == "" {
+			entry.Header.Set("Date", entry.ResponseTime.UTC().Format(http.TimeFormat))
+		}
+		//Check if the cache file could be started; a full disk only costs the caching
+		if file, path, err := h.create(entry); err == nil {
+			resp.Body = &cacheWriter{body: resp.Body, file: file, path: path}
+		}
+	}
+	return resp, nil
+}
+
+// revalidate updates a stored response with the headers of a 304 Not Modified response (RFC 7234
+// section 4.3.4) and serves it
+func (h *httpCache) revalidate(req *http.Request, entry *cacheEntry, body *os.File, header http.Header, requestTime time.Time) (*http.Response, error) {
+	for name, values := range header {
+		//Check if the header describes the empty 304 body rather than the stored one
+		if name == "Content-Length" {
+			continue
+		}
+		entry.Header[name] = values
+	}
+	entry.RequestTime, entry.ResponseTime = requestTime, time.Now()
+	err := h.store(entry, body)
+	body.Close()
+	//Check if the updated entry could not be stored
+	if err != nil {
+		return nil, fmt.Errorf("error updating HTTP cache: %w", err)
+	}
+	updated, body := h.load(entry.URL)
+	//Check if the updated entry disappeared, e.g. because another crawl replaced it
+	if updated == nil {
+		return nil, fmt.Errorf("error updating HTTP cache: entry for %s vanished", entry.URL)
+	}
+	h.revalidated.Add(1)
+	return updated.response(req, body), nil
+}
+
+// storable reports whether a shared cache may store a response (RFC 7234 section 3). Responses
+// that can neither be fresh nor revalidated are not stored either, since they could never be used.
+func storable(req *http.Request, resp *http.Response) bool {
+	requestDirectives := parseCacheControl(req.Header.Get("Cache-Control"))
+	directives := parseCacheControl(resp.Header.Get("Cache-Control"))
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	_, public := directives["public"]
+	_, mustRevalidate := directives["must-revalidate"]
+	_, hasSMaxAge := directives["s-maxage"]
+	_, hasMaxAge := directives["max-age"]
+	//Check if the request or response forbids storing
+	if _, ok := requestDirectives["no-store"]; ok || noStore || private {
+		return false
+	}
+	//Check if the response was for a logged-in request, which a shared cache only stores when allowed explicitly
+	if req.Header.Get("Authorization") != "" && !public && !mustRevalidate && !hasSMaxAge {
+		return false
+	}
+	//Check if the response varies on something other than request headers
+	if slices.Contains(varyFields(resp.Header), "*") {
+		return false
+	}
+	explicit := hasSMaxAge || hasMaxAge || resp.Header.Get("Expires") != ""
+	//Check if the status may only be stored with explicit freshness information
+	if !cacheableStatuses[resp.StatusCode] && !explicit && !public {
+		return false
+	}
+	return explicit || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// varyFields returns the canonical names of the request headers listed in a response's Vary headers
+func varyFields(header http.Header) []string {
+	var fields []string
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			//Check if the field is not blank
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	return fields
+}
+
+// varyValues returns the values of the request headers a response varies on
+func varyValues(req *http.Request, header http.Header) http.Header {
+	values := make(http.Header)
+	for _, field := range varyFields(header) {
+		values[field] = req.Header.Values(field)
+	}
+	return values
+}
+
+// matches reports whether the stored response was selected with the same varying request headers as req
+func (e *cacheEntry) matches(req *http.Request) bool {
+	for _, field := range varyFields(e.Header) {
+		//Check if the request header differs from the one the response was stored for
+		if strings.Join(req.Header.Values(field), ",") != strings.Join(e.Vary.Values(field), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// lifetime returns the freshness lifetime of the stored response for a shared cache (RFC 7234
+// section 4.2.1), using 10% of the time since Last-Modified when no lifetime is given
+func (e *cacheEntry) lifetime() time.Duration {
+	directives := parseCacheControl(e.Header.Get("Cache-Control"))
+	//Check if shared caches get their own lifetime
+	if lifetime, ok := directiveSeconds(directives, "s-maxage"); ok {
+		return lifetime
+	}
+	//Check if the response sets a lifetime
+	if lifetime, ok := directiveSeconds(directives, "max-age"); ok {
+		return lifetime
+	}
+	//Check if the response sets an expiry date
+	if expires := e.Header.Get("Expires"); expires != "" {
+		return expiresTTL(expires, e.Header.Get("Date"))
+	}
+	date, dateErr := http.ParseTime(e.Header.Get("Date"))
+	lastModified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	//Check if a heuristic lifetime can be derived from the modification date
+	if err == nil && dateErr == nil && cacheableStatuses[e.Status] && date.After(lastModified) {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// age returns the current age of the stored response (RFC 7234 section 4.2.3)
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := time.Duration(0)
+	//Check if the response's date allows estimating how long it traveled
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		apparentAge = max(0, e.ResponseTime.Sub(date))
+	}
+	ageValue, _ := strconv.Atoi(e.Header.Get("Age"))
+	correctedAge := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+// fresh reports whether the stored response may be served without revalidation
+func (e *cacheEntry) fresh(now time.Time) bool {
+	//Check if the response must be revalidated every time
+	if _, noCache := parseCacheControl(e.Header.Get("Cache-Control"))["no-cache"]; noCache {
+		return false
+	}
+	return e.age(now) < e.lifetime()
+}
+
+// response builds the response served from the cache, with its current age in the Age header
+func (e *cacheEntry) response(req *http.Request, body *os.File) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.age(time.Now()).Seconds())))
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}
+	//Check if the request only asked for the headers
+	if req.Method == http.MethodHead {
+		body.Close()
+		resp.Body = http.NoBody
+	}
+	return resp
+}
+
+// cacheWriter copies a response body into its cache file while the crawler reads it. The file
+// replaces the stored response once the body was read to the end, and is discarded otherwise.
+type cacheWriter struct {
+	body io.ReadCloser //Body received from the server
+	file *os.File      //Temporary cache file, nil once the body is stored or writing failed
+	path string        //Cache file the temporary file replaces
+}
+
+// Read reads from the body, copying the data to the cache file
+func (w *cacheWriter) Read(p []byte) (int, error) {
+	n, err := w.body.Read(p)
+	//Check if the data is still being cached
+	if w.file != nil && n > 0 {
+		//Check if the cache file could not be written
+		if _, werr := w.file.Write(p[:n]); werr != nil {
+			w.discard()
+		}
+	}
+	//Check if the whole body was read and can be stored
+	if err == io.EOF && w.file != nil {
+		name := w.file.Name()
+		//Check if the cache file could not be completed
+		if cerr := w.file.Close(); cerr != nil || os.Rename(name, w.path) != nil {
+			os.Remove(name)
+		}
+		w.file = nil
+	}
+	return n, err
+}
+
+// Close closes the body, discarding the cache file if the body was not read completely
+func (w *cacheWriter) Close() error {
+	w.discard()
+	return w.body.Close()
+}
+
+// discard removes an unfinished cache file
+func (w *cacheWriter) discard() {
+	//Check if there is an unfinished file
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(w.file.Name())
+		w.file = nil
+	}
+}
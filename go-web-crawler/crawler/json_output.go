@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of output.schema.json that JSON output follows. The minor version
+// grows when optional properties are added, which consumers must ignore if they don't know them;
+// the major version grows when a property is removed, renamed or changes its meaning.
+const (
+	schemaVersion = "1.8"
+	schemaMajor   = 1 // Major part of schemaVersion
+)
+
+// OutputSchema is the JSON Schema of results written as JSON and of dead-letter entries
+//
+//go:embed output.schema.json
+var OutputSchema []byte
+
+// checkSchemaVersion returns an error if a document was written with a newer major schema version
+// than this build understands. Documents without a version predate versioning and are accepted.
+func checkSchemaVersion(version string) error {
+	//Check if the document predates versioning
+	if version == "" {
+		return nil
+	}
+	major, _, _ := strings.Cut(version, ".")
+	//Check if the major version is not understood
+	if n, err := strconv.Atoi(major); err != nil || n > schemaMajor {
+		return fmt.Errorf("unsupported schema version %q (this build reads up to %d.x)", version, schemaMajor)
+	}
+	return nil
+}
+
+// resultRecord is a Result as written by -format json, following the "result" definition of output.schema.json
+type resultRecord struct {
+	SchemaVersion string            `json:"schema_version"`           //Version of output.schema.json the record follows
+	URL           string            `json:"url"`                      //Normalized URL of the page, as requested
+	FinalURL      string            `json:"final_url,omitempty"`      //URL of the final response after redirects, since 1.3
+	Depth         int               `json:"depth"`                    //Depth at which the page was discovered
+	Seed          string            `json:"seed,omitempty"`           //Start URL the page was discovered from
+	Status        int               `json:"status"`                   //HTTP status code, 0 if no response was received
+	Class         string            `json:"class"`                    //Status classification
+	ErrorClass    string            `json:"error_class,omitempty"`    //Coarse failure category, only for failures
+	Title         string            `json:"title,omitempty"`          //Page title
+	Error         string            `json:"error,omitempty"`          //Error that prevented fetching or parsing the page
+	Headers       map[string]string `json:"headers,omitempty"`        //Captured response headers
+	Redirect      string            `json:"redirect,omitempty"`       //Off-host URL the page redirected to
+	Redirects     []redirectRecord  `json:"redirects,omitempty"`      //Redirects followed before the final response
+	ElapsedMS     float64           `json:"elapsed_ms,omitempty"`     //Milliseconds until the final response headers arrived
+	RequestID     string            `json:"request_id,omitempty"`     //ID of the fetch
+	Blocked       string            `json:"blocked,omitempty"`        //Bot protection that served a challenge
+	Annotations   map[string]string `json:"annotations,omitempty"`    //Values attached by the page hook, since 1.1
+	AliasOf       string            `json:"alias_of,omitempty"`       //URL of the result reporting the page this URL redirected to, since 1.2
+	ContentHash   string            `json:"content_hash,omitempty"`   //SHA-256 of the body with -change-history, since 1.4
+	ChangeEveryS  float64           `json:"change_every_s,omitempty"` //Mean seconds between content changes seen across crawls, since 1.4
+	RemoteIP      string            `json:"remote_ip,omitempty"`      //IP address of the server that sent the final response, since 1.5
+	TLSVersion    string            `json:"tls_version,omitempty"`    //TLS version of the final response's connection, since 1.6
+	ALPN          string            `json:"alpn,omitempty"`           //Protocol negotiated through ALPN, since 1.6
+	CDN           string            `json:"cdn,omitempty"`            //CDN whose headers the final response carries, since 1.6
+	Parent        string            `json:"parent,omitempty"`         //Page the URL was found on, since 1.7
+	ContentType   string            `json:"content_type,omitempty"`   //Media type of the final response, since 1.7
+	ContentLength int64             `json:"content_length,omitempty"` //Size of the final response's body in bytes, since 1.7
+	Truncated     bool              `json:"truncated,omitempty"`      //Whether the body was over -max-size, or too large to hold in memory for its parser, and only partly parsed, since 1.8
+}
+
+// redirectRecord is a RedirectHop in JSON output
+type redirectRecord struct {
+	URL    string `json:"url"`    //URL that answered with the redirect
+	Status int    `json:"status"` //Redirect status code
+	To     string `json:"to"`     //URL the redirect pointed to
+}
+
+// newResultRecord converts a result for JSON output
+func newResultRecord(result Result) resultRecord {
+	record := resultRecord{
+		SchemaVersion: schemaVersion,
+		URL:           result.URL,
+		FinalURL:      result.FinalURL,
+		Depth:         result.Depth,
+		Seed:          result.Seed,
+		Status:        result.Status,
+		Class:         result.Class,
+		Title:         result.Title,
+		Error:         result.Error,
+		Headers:       result.Headers,
+		Redirect:      result.Redirect,
+		ElapsedMS:     float64(result.Elapsed) / float64(time.Millisecond),
+		RequestID:     result.RequestID,
+		Blocked:       result.Blocked,
+		Annotations:   result.Annotations,
+		AliasOf:       result.AliasOf,
+		ContentHash:   result.ContentHash,
+		ChangeEveryS:  result.ChangeEvery.Seconds(),
+		RemoteIP:      result.RemoteIP,
+		TLSVersion:    result.TLSVersion,
+		ALPN:          result.ALPN,
+		CDN:           result.CDN,
+		Parent:        result.Parent,
+		ContentType:   result.ContentType,
+		ContentLength: result.ContentLength,
+		Truncated:     result.Truncated,
+	}
+	//Check if the result is a failure, which gets an error class
+	if result.Class == ClassFailure {
+		record.ErrorClass = errorClass(result)
+	}
+	for _, hop := range result.Redirects {
+		record.Redirects = append(record.Redirects, redirectRecord{URL: hop.URL, Status: hop.Status, To: hop.To})
+	}
+	return record
+}
+
+// NewJSONPrinter returns a function that writes each result to w as a line of JSON following
+// OutputSchema
+func NewJSONPrinter(w io.Writer) func(Result) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return func(result Result) {
+		//Check if the record could not be written
+		if err := encoder.Encode(newResultRecord(result)); err != nil {
+			fmt.Fprintf(os.Stderr, "json error for %s: %v\n", result.URL, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package crawler
+
+// Positions of a link on its page, from the landmark element or ARIA role around it
+const (
+	PositionNav    = "nav"    //Inside <nav> or role="navigation"
+	PositionHeader = "header" //Inside the page's <header> or role="banner"
+	PositionFooter = "footer" //Inside the page's <footer> or role="contentinfo"
+	PositionAside  = "aside"  //Inside <aside> or role="complementary"
+	PositionMain   = "main"   //Inside <main> or role="main"
+)
+
+// landmarkTags maps landmark elements to the position of the links inside them
+var landmarkTags = map[string]string{
+	"nav": PositionNav, "header": PositionHeader, "footer": PositionFooter, "aside": PositionAside, "main": PositionMain,
+}
+
+// landmarkRoles maps ARIA landmark roles to the position of the links inside them
+var landmarkRoles = map[string]string{
+	"navigation": PositionNav, "banner": PositionHeader, "contentinfo": PositionFooter, "complementary": PositionAside, "main": PositionMain,
+}
+
+// pageRegion is an open landmark element
+type pageRegion struct {
+	tag      string //Name of the element
+	position string //Position of the links inside it
+	nested   int    //Open elements inside it with the same name, whose end tags don't close it
+}
+
+// regionStack tracks the landmark elements around the tokenizer's position. Only landmarks are
+// kept, so unclosed elements elsewhere in sloppy markup don't matter.
+type regionStack []pageRegion
+
+// open records a start tag with its role attribute, if any
+func (s *regionStack) open(tag, role string) {
+	position, ok := landmarkRoles[role]
+	//Check if the role does not make the element a landmark, which leaves its name
+	if !ok {
+		position = landmarkTags[tag]
+	}
+	//Check if the element is not a landmark, which only matters if it shares a landmark's name
+	if position == "" {
+		for i := len(*s) - 1; i >= 0; i-- {
+			//Check if the element nests inside a landmark of the same name
+			if (*s)[i].tag == tag {
+				(*s)[i].nested++
+				return
+			}
+		}
+		return
+	}
+	//Check if a header or footer belongs to the main content, where it heads a section instead of the page
+	if (position == PositionHeader || position == PositionFooter) && s.position() == PositionMain {
+		position = PositionMain
+	}
+	*s = append(*s, pageRegion{tag: tag, position: position})
+}
+
+// close records an end tag, closing the innermost landmark of that name unless an element of the
+// same name inside it is still open
+func (s *regionStack) close(tag string) {
+	for i := len(*s) - 1; i >= 0; i-- {
+		//Check if the landmark has the end tag's name
+		if (*s)[i].tag != tag {
+			continue
+		}
+		//Check if the end tag closes an element nested inside the landmark
+		if (*s)[i].nested > 0 {
+			(*s)[i].nested--
+			return
+		}
+		*s = (*s)[:i]
+		return
+	}
+}
+
+// position returns the position of links at the current point, "" outside of landmarks
+func (s regionStack) position() string {
+	//Check if no landmark is open
+	if len(s) == 0 {
+		return ""
+	}
+	return s[len(s)-1].position
+}
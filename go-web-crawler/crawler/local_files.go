@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localSeed converts a seed naming a local directory or file into a file:// URL and the directory
+// it is served from. A directory becomes the site root, so root-relative links such as "/docs/"
+// resolve the way they will once the site is deployed. Seeds that already are file:// URL's are
+// served from the filesystem root. It returns false for seeds that are not local.
+func localSeed(seed string) (fileURL, root string, ok bool) {
+	//Check if the seed is a file:// URL
+	if strings.HasPrefix(seed, "file://") {
+		return seed, "/", true
+	}
+	//Check if the seed is a URL with another scheme
+	if strings.Contains(seed, "://") {
+		return "", "", false
+	}
+	info, err := os.Stat(seed)
+	//Check if the seed does not name a local path
+	if err != nil {
+		return "", "", false
+	}
+	abs, err := filepath.Abs(seed)
+	//Check if the absolute path cannot be determined
+	if err != nil {
+		return "", "", false
+	}
+	//Check if the seed is a single file rather than a site directory
+	if !info.IsDir() {
+		return (&url.URL{Scheme: "file", Path: "/" + filepath.Base(abs)}).String(), filepath.Dir(abs), true
+	}
+	return "file:///", abs, true
+}
+
+// serveLocalFiles lets the crawler fetch file:// URL's from the directory tree at root. Missing
+// files are reported as 404, and directories serve their index.html or a listing of their entries.
+func (c *Crawler) serveLocalFiles(root string) {
+	c.client.Transport.(*http.Transport).RegisterProtocol("file", http.NewFileTransport(http.Dir(root)))
+}
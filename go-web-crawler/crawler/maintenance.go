@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maintenanceThreshold  = 3 // 503 responses in a row after which a host is paused
+	maintenanceMaxRetries = 3 // Times a URL answered with 503 is queued again before its failure is reported
+)
+
+// maintenanceHost is the maintenance state of one host
+type maintenanceHost struct {
+	consecutive int       //503 responses since the last other response
+	until       time.Time //End of the host's current pause, zero if it was never paused
+	pauses      int       //Pauses since the host last answered with another status
+}
+
+// maintenance pauses hosts that keep answering 503 Service Unavailable, as servers in maintenance
+// mode do, and queues the pages that got a 503 again, so they are fetched once the host is back
+// instead of being reported as failures. Each pause lasts backoff times the number of pauses in a
+// row, and a URL is retried at most maintenanceMaxRetries times.
+type maintenance struct {
+	mutex   sync.Mutex                  //Protects hosts and retries
+	backoff time.Duration               //Length of a host's first pause
+	hosts   map[string]*maintenanceHost //Maintenance state by lower-case host
+	retries map[string]int              //Times each URL was queued again
+}
+
+// newMaintenance creates a maintenance tracker pausing hosts for backoff
+func newMaintenance(backoff time.Duration) *maintenance {
+	return &maintenance{backoff: backoff, hosts: make(map[string]*maintenanceHost), retries: make(map[string]int)}
+}
+
+// wait blocks until the pause of the link's host, if any, is over
+func (m *maintenance) wait(ctx context.Context, link string) error {
+	m.mutex.Lock()
+	h, ok := m.hosts[urlHost(link)]
+	var until time.Time
+	//Check if the host was paused
+	if ok {
+		until = h.until
+	}
+	m.mutex.Unlock()
+	delay := time.Until(until)
+	//Check if the host is not paused
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// record counts a response of the host and reports whether the URL should be queued again. A
+// pause that starts with this response is returned, 0 if none does.
+func (m *maintenance) record(host, link string, status int) (bool, time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	h, ok := m.hosts[host]
+	//Check if this is the host's first response
+	if !ok {
+		h = &maintenanceHost{}
+		m.hosts[host] = h
+	}
+	//Check if the host answered normally, which ends its maintenance
+	if status != http.StatusServiceUnavailable {
+		h.consecutive, h.pauses = 0, 0
+		return false, 0
+	}
+	h.consecutive++
+	//Check if the URL was retried often enough, whose failure is reported now
+	if m.retries[link] >= maintenanceMaxRetries {
+		return false, 0
+	}
+	m.retries[link]++
+	//Check if the host has not answered 503 often enough in a row, or is paused already
+	if h.consecutive < maintenanceThreshold || time.Now().Before(h.until) {
+		return true, 0
+	}
+	h.consecutive = 0
+	h.pauses++
+	pause := m.backoff * time.Duration(h.pauses)
+	h.until = time.Now().Add(pause)
+	return true, pause
+}
+
+// retryMaintenance queues a page that got a 503 again if its host may be in maintenance, pausing
+// the host after several in a row, and reports whether it did; the page is then not reported
+func (c *Crawler) retryMaintenance(pageURL string, depth int, meta LinkMeta, result Result) bool {
+	host := urlHost(pageURL)
+	//Check if hosts in maintenance are not waited for, or the response was a bot challenge
+	if c.maintenance == nil || host == "" || result.Blocked != "" {
+		return false
+	}
+	retry, pause := c.maintenance.record(host, pageURL, result.Status)
+	//Check if the page is reported as it is
+	if !retry {
+		return false
+	}
+	//Check if the host is paused from now on
+	if pause > 0 {
+		c.logf(0, "%s answers 503 Service Unavailable, pausing it for %s", host, pause)
+	}
+	c.logf(1, "[%s] %s answered 503, queued again", result.RequestID, pageURL)
+	c.queue(c.newFrontierItem(pageURL, depth, meta, false))
+	return true
+}
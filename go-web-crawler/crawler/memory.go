@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	memorySpillThreshold = 0.8                    // Fraction of -max-memory at which data is moved to disk
+	memoryCheckInterval  = 500 * time.Millisecond // How often heap usage is compared to the ceiling
+	frontierMemoryItems  = 1000                   // Best-ranked queued URL's kept in memory when the frontier spills
+	resultSpillMin       = 1000                   // Fewest buffered results worth a spill run
+)
+
+// byteUnits maps size suffixes to their multipliers, using binary units
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}, {"b", 1},
+}
+
+// ParseByteSize parses a size such as "1GB", "512mb", "64k" or a plain byte count
+func ParseByteSize(size string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		//Check if the size ends with the unit
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	//Check if the number is malformed or not positive
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. \"512MB\" or \"1GB\")", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// heapBytes returns the memory occupied by heap objects, without stopping the world
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// startMemoryGuard enforces -max-memory until ctx is cancelled. When the heap nears the ceiling,
+// queued URL's and buffered results are spilled to disk and a garbage collection is forced, so the
+// crawl slows down instead of being killed. The runtime's soft memory limit is process-wide, so it
+// is left to the program embedding the crawler.
+func (c *Crawler) startMemoryGuard(ctx context.Context) {
+	//Check if no memory ceiling was set
+	if c.maxMemory <= 0 {
+		return
+	}
+	threshold := uint64(float64(c.maxMemory) * memorySpillThreshold)
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			used := heapBytes()
+			//Check if the heap is still comfortably below the ceiling
+			if used < threshold {
+				warned = false
+				continue
+			}
+			queued, err := c.frontier.spill(frontierMemoryItems)
+			//Check if the frontier could not be spilled
+			if err != nil {
+				c.logf(-1, "%v", err)
+			}
+			results := 0
+			//Check if buffered results can be spilled too
+			if c.spillResults != nil {
+				//Check if the results could not be spilled
+				if results, err = c.spillResults(); err != nil {
+					c.logf(-1, "%v", err)
+				}
+			}
+			//Check if nothing could be moved to disk
+			if queued == 0 && results == 0 {
+				//Check if the warning was already logged for this episode
+				if !warned {
+					c.logf(0, "memory use %d MB is near -max-memory %d MB with nothing left to spill", used>>20, c.maxMemory>>20)
+					warned = true
+				}
+				continue
+			}
+			debug.FreeOSMemory() // Collects garbage and returns the freed pages to the OS
+			c.logf(0, "memory use %d MB neared -max-memory %d MB: spilled %d queued URL's and %d results to disk, now %d MB",
+				used>>20, c.maxMemory>>20, queued, results, heapBytes()>>20)
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultUserAgent      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36" // User-Agent header of a desktop browser
+	DefaultAccept         = "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"                                                // Accept header of a desktop browser
+	DefaultAcceptLanguage = "en-US,en;q=0.5"                                                                                                            // Accept-Language header of a US English browser
+)
+
+// Negotiation overrides the content negotiation headers sent to one host
+type Negotiation struct {
+	Accept         string `json:"accept"`          //Accept header, empty keeps the crawl's
+	AcceptLanguage string `json:"accept_language"` //Accept-Language header, empty keeps the crawl's
+}
+
+// mergeNegotiation combines negotiation maps keyed by host, later maps taking precedence, and lower-cases the hosts
+func mergeNegotiation(layers ...map[string]*Negotiation) map[string]*Negotiation {
+	merged := make(map[string]*Negotiation)
+	for _, layer := range layers {
+		for host, negotiation := range layer {
+			merged[strings.ToLower(host)] = negotiation
+		}
+	}
+	return merged
+}
+
+// setNegotiationHeaders sets the Accept and Accept-Language headers for the request's host: the
+// host's overrides from the config, or else -accept and -accept-language. Empty values are not sent.
+func (c *Crawler) setNegotiationHeaders(req *http.Request) {
+	accept, language := c.accept, c.acceptLanguage
+	negotiation, ok := c.negotiation[strings.ToLower(req.URL.Host)]
+	//Check if there are no overrides for the host and port
+	if !ok {
+		negotiation = c.negotiation[strings.ToLower(req.URL.Hostname())]
+	}
+	//Check if the host has overrides
+	if negotiation != nil {
+		//Check if the host overrides the Accept header
+		if negotiation.Accept != "" {
+			accept = negotiation.Accept
+		}
+		//Check if the host overrides the Accept-Language header
+		if negotiation.AcceptLanguage != "" {
+			language = negotiation.AcceptLanguage
+		}
+	}
+	for name, value := range map[string]string{"Accept": accept, "Accept-Language": language} {
+		//Check if the header is left out
+		if value == "" {
+			req.Header.Del(name)
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,36 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauth2Token returns a valid access token for an "oauth2" credential, fetching a new one from the
+// token URL when none is cached, the cached one expired, or refresh is set after a rejected token
+func (cred *Credential) oauth2Token(refresh bool) (string, error) {
+	cred.mutex.Lock()
+	defer cred.mutex.Unlock()
+	//Check if a token source has to be created, dropping any cached token
+	if cred.tokens == nil || refresh {
+		config := &clientcredentials.Config{
+			ClientID:     cred.ClientID,
+			ClientSecret: cred.ClientSecret,
+			TokenURL:     cred.TokenURL,
+			Scopes:       cred.Scopes,
+		}
+		// Token requests get their own client so they are not subject to crawl redirects or auth retries
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: 10 * time.Second})
+		cred.tokens = config.TokenSource(ctx)
+	}
+	token, err := cred.tokens.Token()
+	//Check if the token endpoint refused or failed the request
+	if err != nil {
+		return "", fmt.Errorf("error fetching OAuth2 token from %s: %w", cred.TokenURL, err)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,322 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// offlineMirror answers the crawler's requests from a saved copy of a site instead of the network.
+// The copy is either a directory with one subdirectory per host, as wget --mirror saves it, or a
+// WARC file. URL's missing from the mirror get a 404 response, so broken links and missing assets
+// show up like on the live site without a single request reaching it.
+type offlineMirror struct {
+	dir     string           //Root of a directory mirror, empty for a WARC file
+	warc    *os.File         //WARC file the responses are read from, nil for a directory mirror
+	gzipped bool             //Whether the WARC file is a series of gzip members, one per record
+	records map[string]int64 //Offset of the last response record by mirror key, for WARC files
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader //Reader counted
+	n int64     //Bytes read so far
+}
+
+// Read reads from the underlying reader and counts the bytes
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// openOfflineMirror opens a directory mirror or indexes the response records of a WARC file,
+// which may be gzip-compressed
+func openOfflineMirror(source string) (*offlineMirror, error) {
+	info, err := os.Stat(source)
+	//Check if the mirror does not exist
+	if err != nil {
+		return nil, fmt.Errorf("error opening offline mirror: %w", err)
+	}
+	//Check if the mirror is a directory
+	if info.IsDir() {
+		return &offlineMirror{dir: source}, nil
+	}
+	file, err := os.Open(source)
+	//Check if the WARC file could not be opened
+	if err != nil {
+		return nil, fmt.Errorf("error opening offline mirror: %w", err)
+	}
+	m := &offlineMirror{warc: file, records: make(map[string]int64)}
+	//Check if the WARC file could not be indexed
+	if err := m.indexWARC(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading WARC file %s: %w", source, err)
+	}
+	return m, nil
+}
+
+// indexWARC records the offset of every response record. Compressed files are expected to hold
+// one record per gzip member, as WARC writers produce them; further records in a member are skipped.
+func (m *offlineMirror) indexWARC() error {
+	counter := &countingReader{r: m.warc}
+	reader := bufio.NewReader(counter)
+	magic, _ := reader.Peek(2)
+	m.gzipped = len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+	var member *gzip.Reader
+	for {
+		offset := counter.n - int64(reader.Buffered())
+		record := reader
+		//Check if the record is compressed in a gzip member of its own
+		if m.gzipped {
+			//Check if the last member was read
+			if _, err := reader.Peek(1); err == io.EOF {
+				return nil
+			}
+			var err error
+			//Check if this is the first member, whose reader the others reuse
+			if member == nil {
+				member, err = gzip.NewReader(reader)
+			} else {
+				err = member.Reset(reader)
+			}
+			//Check if the member header is invalid
+			if err != nil {
+				return fmt.Errorf("at offset %d: %w", offset, err)
+			}
+			member.Multistream(false)
+			record = bufio.NewReader(member)
+		}
+		header, length, err := readWARCHeader(record)
+		//Check if the last record was read
+		if err == io.EOF {
+			return nil
+		}
+		//Check if the record is malformed
+		if err != nil {
+			return fmt.Errorf("at offset %d: %w", offset, err)
+		}
+		//Check if the record is a response with the URL it was fetched from
+		if header["warc-type"] == "response" && header["warc-target-uri"] != "" {
+			m.records[mirrorKey(header["warc-target-uri"])] = offset
+		}
+		//Check if the rest of the member is skipped, which also skips the record's block
+		if m.gzipped {
+			_, err = io.Copy(io.Discard, member)
+		} else {
+			_, err = io.CopyN(io.Discard, reader, length)
+		}
+		//Check if the record's block is truncated
+		if err != nil {
+			return fmt.Errorf("at offset %d: %w", offset, err)
+		}
+	}
+}
+
+// readWARCHeader reads a record's version line and named fields, which are returned by lower-case
+// name, along with the length of the record's block. Blank lines ending the previous record are
+// skipped; io.EOF is returned if no record follows.
+func readWARCHeader(r *bufio.Reader) (map[string]string, int64, error) {
+	var line string
+	var err error
+	for line == "" {
+		line, err = r.ReadString('\n')
+		//Check if the file ends before another record
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return nil, 0, io.EOF
+		}
+		//Check if the line could not be read
+		if err != nil {
+			return nil, 0, err
+		}
+		line = strings.TrimSpace(line)
+	}
+	//Check if the record does not start with a WARC version line
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, 0, fmt.Errorf("missing WARC version line, found %q", line)
+	}
+	header := make(map[string]string)
+	for {
+		line, err = r.ReadString('\n')
+		//Check if the header ends before the blank line that separates it from the block
+		if err != nil {
+			return nil, 0, fmt.Errorf("truncated record header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		//Check if the header is complete
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		header[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	length, err := strconv.ParseInt(header["content-length"], 10, 64)
+	//Check if the block length is missing or invalid
+	if err != nil || length < 0 {
+		return nil, 0, fmt.Errorf("invalid Content-Length %q", header["content-length"])
+	}
+	return header, length, nil
+}
+
+// mirrorKey returns the form of a URL that mirror entries are stored and looked up by
+func mirrorKey(link string) string {
+	u, err := url.Parse(strings.Trim(link, "<>"))
+	//Check if the link is not a URL, which can only match itself
+	if err != nil {
+		return link
+	}
+	u.Scheme, u.Host, u.Fragment = strings.ToLower(u.Scheme), strings.ToLower(u.Host), ""
+	//Check if the URL has an empty path, which is the same as the root
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	canonicalizeURL(u)
+	return u.String()
+}
+
+// RoundTrip answers a request from the mirror
+func (m *offlineMirror) RoundTrip(req *http.Request) (*http.Response, error) {
+	//Check if the mirror is a directory
+	if m.dir != "" {
+		return m.serveFile(req)
+	}
+	offset, ok := m.records[mirrorKey(req.URL.String())]
+	//Check if the WARC file has no response for the URL
+	if !ok {
+		return notInMirror(req), nil
+	}
+	var record io.Reader = io.NewSectionReader(m.warc, offset, 1<<62)
+	//Check if the record has to be decompressed
+	if m.gzipped {
+		member, err := gzip.NewReader(record)
+		//Check if the member could not be opened
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from WARC file: %w", req.URL, err)
+		}
+		member.Multistream(false)
+		record = member
+	}
+	reader := bufio.NewReader(record)
+	_, length, err := readWARCHeader(reader)
+	//Check if the record could not be read again
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from WARC file: %w", req.URL, err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(io.LimitReader(reader, length)), req)
+	//Check if the record does not hold an HTTP response
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from WARC file: %w", req.URL, err)
+	}
+	//Check if the body was stored compressed as it came over the wire
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") && resp.Body != http.NoBody {
+		body, err := gzip.NewReader(resp.Body)
+		//Check if the body is not valid gzip
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error decompressing %s from WARC file: %w", req.URL, err)
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength, resp.Uncompressed = -1, true
+	}
+	return resp, nil
+}
+
+// serveFile answers a request from the directory of the URL's host. The query is part of the file
+// name, as wget saves it, and directories serve their index.html.
+func (m *offlineMirror) serveFile(req *http.Request) (*http.Response, error) {
+	name := req.URL.Path
+	//Check if the query is part of the saved file's name
+	if req.URL.RawQuery != "" {
+		name += "?" + req.URL.RawQuery
+	}
+	files := mirrorDir(filepath.Join(m.dir, strings.ToLower(req.URL.Host)))
+	file, err := files.Open(name)
+	//Check if the host was not saved or the file is missing, which the file server would answer with a listing or a redirect
+	if err != nil {
+		return notInMirror(req), nil
+	}
+	file.Close()
+	fileReq := req.Clone(req.Context())
+	fileReq.URL.Path, fileReq.URL.RawPath, fileReq.URL.RawQuery = name, "", ""
+	resp, err := http.NewFileTransport(files).RoundTrip(fileReq)
+	//Check if the file could not be served
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// mirrorDir is the directory of one host in a directory mirror. Unlike http.Dir, it does not
+// list directories without an index.html, since such listings were never part of the site, and
+// it finds pages saved with an added .html extension, as wget --adjust-extension saves them.
+type mirrorDir string
+
+// Open opens the named file of the host's directory
+func (d mirrorDir) Open(name string) (http.File, error) {
+	file, err := http.Dir(d).Open(name)
+	//Check if the file may have been saved with an added extension
+	if errors.Is(err, fs.ErrNotExist) && path.Ext(name) != ".html" {
+		return http.Dir(d).Open(name + ".html")
+	}
+	//Check if the file could not be opened
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	//Check if the file is a directory that is served by its index.html
+	if err == nil && info.IsDir() {
+		index, err := http.Dir(d).Open(path.Join(name, "index.html"))
+		//Check if the directory has no index.html
+		if err != nil {
+			file.Close()
+			return nil, fs.ErrNotExist
+		}
+		index.Close()
+	}
+	return file, nil
+}
+
+// notInMirror returns the 404 response for a URL the mirror has no copy of
+func notInMirror(req *http.Request) *http.Response {
+	body := "not in the offline mirror\n"
+	return &http.Response{
+		Status:        "404 Not Found",
+		StatusCode:    http.StatusNotFound,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// useOfflineMirror answers all of the crawler's requests from a saved mirror; nothing is sent
+// over the network
+func (c *Crawler) useOfflineMirror(source string) error {
+	mirror, err := openOfflineMirror(source)
+	//Check if the mirror could not be opened
+	if err != nil {
+		return err
+	}
+	c.offline = mirror
+	c.client.Transport = mirror
+	return nil
+}
@@ -0,0 +1,549 @@
+package crawler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Options configures a crawler. Zero values keep the defaults of the web_crawler command, which
+// builds its crawler from the same options.
+type Options struct {
+	MaxDepth       int            //Maximum crawl depth, the seed being at depth 1; 0 for 2
+	MaxPages       int            //Maximum number of unique URL's to visit; 0 for 100
+	Workers        int            //Number of concurrent crawl workers; 0 for 10
+	Rate           float64        //Requests per second across all hosts; 0 for 5
+	HostRate       float64        //Requests per second per host, each host getting a limiter of its own instead of sharing Rate; 0 to share
+	HostRates      []string       //Requests per second of single hosts as host=rate, with "*.domain" for a domain and its subdomains
+	Timeout        time.Duration  //Time a request may take, including reading the body; 0 for 10s
+	AllowedDomains []string       //Hosts that may be crawled besides the seed host, each optionally as host=maxDepth, "*.domain" for a domain and its subdomains, "*" for any
+	PathPrefixes   []string       //Path prefixes that pages other than the seed must start with, e.g. "/docs/"; nil for any path
+	Include        *regexp.Regexp //Pattern the URL's of pages other than the seed must match; nil for any URL
+	Exclude        *regexp.Regexp //Pattern of URL's that are not crawled, except the seed; nil for none
+	MaxPerHost     int            //Most pages visited per host, 0 for no limit
+	HostDelay      time.Duration  //Least time between two requests to the same host, 0 for none
+	CheckExternal  bool           //Whether links to other hosts are requested once to report their status
+	Verbosity      int            //Logging level on stderr: -1 quiet, 0 normal, 1 verbose, 2 very verbose
+	Prioritizer    Prioritizer    //Scores discovered URL's, higher first; nil to crawl in discovery order, or by change frequency with ChangeHistory
+	Config         *Config        //Seeds, path rules, query rules, credentials, negotiation, blackouts and status classes of a config file; nil for none
+
+	// Requests
+	UserAgent        string        //User-Agent header, whose product token also selects the robots.txt rules; "" for DefaultUserAgent
+	Accept           string        //Accept header; "" for DefaultAccept, "-" to leave it out
+	AcceptLanguage   string        //Accept-Language header; "" for DefaultAcceptLanguage, "-" to leave it out
+	RequestIDHeader  string        //Request header carrying each fetch's request ID, e.g. "X-Request-ID"; "" to not send it
+	RecordHeaders    []string      //Response headers recorded in each result, "*" for all
+	IgnoreRobots     bool          //Whether pages that robots.txt disallows are crawled and its Crawl-delay ignored
+	MaxCrawlDelay    time.Duration //Longest robots.txt Crawl-delay honored; 0 for 30s, negative for no limit
+	IgnoreHSTS       bool          //Whether Strict-Transport-Security is ignored instead of requesting the http:// URL's of its hosts over HTTPS
+	PreferHTTPS      bool          //Whether every http:// URL on the default port is crawled as https://
+	MaxRedirects     int           //Redirects followed per URL before it fails; 0 for 20, negative to report 3xx responses as results and queue their targets
+	PlainRedirects   bool          //Whether redirect hops are plain GET requests instead of repeating the original method and headers
+	OffHostRedirects string        //What to do when an in-scope URL redirects to another host: "follow", "record" or "error"; "" for "follow"
+	Watchdog         float64       //Request timeouts after which a running fetch is reported as stalled; 0 for 3, negative to disable
+	WatchdogAbort    bool          //Whether fetches reported by the watchdog are aborted
+	MaxSize          int64         //Largest body downloaded in bytes, larger ones being reported as truncated; 0 for any size
+	SampleSize       int64         //Bytes of bodies over MaxSize fetched with a Range request and parsed; 0 to skip them
+	MaxMemory        int64         //Heap ceiling in bytes, near which queued URL's and buffered results are spilled to disk; 0 for none. The runtime's memory limit is not changed
+
+	// Scope and checks
+	Discover         bool     //Whether /sitemap.xml, /sitemap_index.xml, /feed and /archive are probed on every crawled host
+	CheckAssets      bool     //Whether the images, scripts, stylesheets and other resources of pages are requested once for their status
+	CheckFragments   bool     //Whether links to a fragment whose target has no such id or anchor name are reported
+	FollowAlternates bool     //Whether AMP versions and alternate formats of pages are crawled at their page's depth
+	CheckAMP         bool     //Whether AMP pages and their canonical pages are checked for links to each other
+	Keywords         []string //Keywords a page must be relevant to for its links to be followed; nil to follow links from all pages
+	MinRelevance     float64  //Fraction of Keywords a page must contain for its links to be followed; 0 for 0.5
+
+	// Hosts
+	HostConcurrency  int           //Most pages of one host fetched at once, 0 for no limit
+	HostMaxFailures  int           //Failed requests, 5xx responses or bot challenges in a row after which a host is abandoned, 0 to never abandon it
+	RampUp           float64       //Requests per second each new host starts at, growing while it answers without failures; 0 to start at full speed
+	MaintenancePause time.Duration //Pause of a host after 3 responses in a row with 503, whose pages are fetched again; 0 to report 503s as failures
+	BotSlowdown      time.Duration //Least time between requests to a host that served a bot challenge, doubling with each further one; 0 to keep its pace
+	Retries          int           //Times a URL is fetched again after a transient failure, 0 to report it at once
+	RetryBackoff     time.Duration //Wait before the first retry, doubled for each further one; 0 for 1s
+	RetryMaxWait     time.Duration //Longest wait before a retry, also when Retry-After asks for more; 0 for 1m
+
+	// Connections
+	MapHosts          []string      //Hosts whose requests go to a substitute, as host=substitute
+	MapHostHeader     bool          //Whether requests rerouted by MapHosts keep the original host in the Host header
+	ConnectTo         []string      //curl-style HOST1:PORT1:HOST2:PORT2 rules rerouting connections while Host and TLS SNI keep the URL's host
+	Bind              string        //Local IP address or network interface connections are made from, "" for any
+	IPVersion         string        //Address family of connections: "4", "6", or "auto" or "" for both
+	DialTimeout       time.Duration //Time each IP address of a host gets to accept a connection, 0 to share 30s between them
+	DialFallbackDelay time.Duration //Time the first address family of a dual-stack host gets before the other one is tried; 0 for 300ms, negative to try it only after a failure
+
+	// Sources
+	HTTPCache     string      //Directory of an on-disk HTTP cache shared across crawls, "" for none
+	Offline       string      //Saved mirror, as a directory of hosts or a WARC file, answering all requests instead of the network; "" to crawl live
+	Simulation    *Simulation //Recorded link graph answering all requests instead of the network, nil to crawl live
+	ChangeHistory string      //JSON file of page content hashes across crawls, "" to not track changes
+	SeedFile      string      //File watched for seed URL's appended while the crawl runs, "" for none
+
+	// Extensions
+	PageHook       string        //Program run for every crawled page with its result as JSON on stdin, "" for none
+	HookTimeout    time.Duration //Time PageHook may run per page before it is killed; 0 for 10s
+	Script         string        //Lua script customizing the crawl, "" for none
+	WASMExtractors []string      //WASM plugins parsing responses of a media type for links, as media-type=plugin.wasm
+	WASMProcessors []string      //WASM plugins processing every parsed page
+
+	// Output
+	DeadLetters   string        //NDJSON file receiving the URL's that failed, "" for none
+	Edges         string        //CSV file receiving the link graph, "" for none
+	WAL           string        //Write-ahead log recording crawl progress for crash recovery, "" for none
+	StateFile     string        //File the queue and visited set are checkpointed to, "" for none
+	StateInterval time.Duration //Time between StateFile checkpoints; 0 for 30s
+	Resume        bool          //Whether the crawl saved in StateFile is continued
+	Report        *Report       //Report the link graph is recorded for, nil for none
+	Archive       *Archive      //Archive the bodies of crawled pages are kept for, nil for none
+	Buffer        *ResultBuffer //Buffer of sorted results spilled to disk near MaxMemory, nil for none
+}
+
+// New creates a crawler that starts at seed, a URL or a local directory or file, which may be ""
+// if Config has seeds. Start it with Run and read what it finds with Results; Close it once the
+// crawl has ended.
+func New(seed string, opts Options) (*Crawler, error) {
+	maxDepth, maxPages := 2, 100
+	//Check if the depth is overridden
+	if opts.MaxDepth > 0 {
+		maxDepth = opts.MaxDepth
+	}
+	//Check if the page budget is overridden
+	if opts.MaxPages > 0 {
+		maxPages = opts.MaxPages
+	}
+	fileURL, fileRoot, local := localSeed(seed)
+	//Check if the seed is a local directory or file:// URL
+	if local {
+		seed = fileURL
+	}
+	c, err := NewCrawler(seed, maxDepth, maxPages)
+	//Check if the seed is invalid
+	if err != nil {
+		return nil, err
+	}
+	//Check if local files have to be served to the crawler
+	if local {
+		c.serveLocalFiles(fileRoot)
+	}
+	//Check if a negative count, delay or rate was given
+	if opts.Workers < 0 || opts.MaxPerHost < 0 || opts.HostDelay < 0 || opts.HostRate < 0 || opts.HostConcurrency < 0 ||
+		opts.HostMaxFailures < 0 || opts.RampUp < 0 || opts.Retries < 0 || opts.RetryBackoff < 0 || opts.RetryMaxWait < 0 ||
+		opts.DialTimeout < 0 || opts.StateInterval < 0 || opts.MaxSize < 0 || opts.SampleSize < 0 || opts.MaxMemory < 0 {
+		return nil, fmt.Errorf("counts, delays, rates and sizes in Options must not be negative")
+	}
+	//Check if requests are answered from a saved mirror
+	if opts.Offline != "" {
+		//Check if the seed is local, which is read from disk already
+		if local {
+			return nil, fmt.Errorf("an offline mirror needs the URL of the mirrored site, not a local path")
+		}
+		//Check if the mirror could not be opened
+		if err := c.useOfflineMirror(opts.Offline); err != nil {
+			return nil, err
+		}
+	}
+	//Check if pages are replayed from a recorded link graph
+	if opts.Simulation != nil {
+		//Check if the seed is local or requests are answered from a mirror, which the graph would replace
+		if local || opts.Offline != "" {
+			return nil, fmt.Errorf("a simulation needs the URL of the recorded site and cannot be combined with an offline mirror")
+		}
+		c.useSimulation(opts.Simulation)
+	}
+	//Check if the connection overrides are malformed
+	if c.dialer.connectTo, err = parseConnectTo(strings.Join(opts.ConnectTo, ",")); err != nil {
+		return nil, err
+	}
+	//Check if the address family is unknown
+	if err := c.dialer.setIPVersion(cmp.Or(opts.IPVersion, "auto")); err != nil {
+		return nil, err
+	}
+	c.dialer.addressTimeout = opts.DialTimeout
+	c.dialer.dialer.FallbackDelay = opts.DialFallbackDelay
+	//Check if connections originate from a chosen local address
+	if opts.Bind != "" {
+		//Check if the address cannot be used
+		if err := c.dialer.bind(opts.Bind); err != nil {
+			return nil, err
+		}
+	}
+	hostMap, err := parseHostMap(strings.Join(opts.MapHosts, ","))
+	//Check if the host mapping is malformed
+	if err != nil {
+		return nil, err
+	}
+	//Check if requests for some hosts are rerouted
+	if len(hostMap) > 0 {
+		c.mapHosts(hostMap, opts.MapHostHeader)
+	}
+	//Check if responses are cached on disk, in front of any rerouting so entries keep the logical URL's
+	if opts.HTTPCache != "" {
+		//Check if the cache could not be opened
+		if err := c.useHTTPCache(opts.HTTPCache); err != nil {
+			return nil, err
+		}
+	}
+	//Check if a config file provides seeds and rules
+	if cfg := opts.Config; cfg != nil {
+		c.pathRules = cfg.PathRules
+		c.addSeeds(cfg.Seeds)
+		c.queryPolicies = newQueryPolicies(cfg.QueryRules)
+		c.credentials = cfg.Credentials
+		c.negotiation = cfg.Negotiation
+		c.blackouts = cfg.Blackouts
+		//Check if the status classes are invalid
+		if c.statuses, err = newStatusClassifier(cfg.StatusClasses); err != nil {
+			return nil, err
+		}
+	}
+
+	c.userAgent = cmp.Or(opts.UserAgent, DefaultUserAgent)
+	c.accept, c.acceptLanguage = headerOption(opts.Accept, DefaultAccept), headerOption(opts.AcceptLanguage, DefaultAcceptLanguage)
+	c.requestIDHeader = opts.RequestIDHeader
+	c.headerNames = parseHeaderNames(strings.Join(opts.RecordHeaders, ","))
+	//Check if robots.txt is ignored
+	if opts.IgnoreRobots {
+		c.robots = nil
+	} else {
+		c.robots.maxDelay = cmp.Or(max(opts.MaxCrawlDelay, 0), 30*time.Second)
+		//Check if every Crawl-delay is honored
+		if opts.MaxCrawlDelay < 0 {
+			c.robots.maxDelay = 0
+		}
+	}
+	//Check if Strict-Transport-Security is ignored
+	if opts.IgnoreHSTS {
+		c.hsts = nil
+	}
+	c.preferHTTPS = opts.PreferHTTPS
+	//Check if the redirect limit is overridden
+	if opts.MaxRedirects != 0 {
+		c.maxRedirects = max(opts.MaxRedirects, 0)
+	}
+	c.preserveRedirects = !opts.PlainRedirects
+	//Check if the off-host redirect policy is supported
+	switch opts.OffHostRedirects {
+	case "", "follow", "record", "error":
+		c.offHostRedirects = cmp.Or(opts.OffHostRedirects, "follow")
+	default:
+		return nil, fmt.Errorf("invalid off-host redirect policy %q (expected \"follow\", \"record\" or \"error\")", opts.OffHostRedirects)
+	}
+	//Check if the request timeout is overridden
+	if opts.Timeout > 0 {
+		c.client.Timeout = opts.Timeout
+	}
+	watchdog := cmp.Or(opts.Watchdog, 3)
+	//Check if stalled fetches are not reported
+	if watchdog < 0 {
+		watchdog = 0
+	}
+	c.watchdog = newWatchdog(time.Duration(watchdog*float64(c.client.Timeout)), opts.WatchdogAbort)
+	//Check if a sample is fetched without a size limit to sample bodies above
+	if opts.SampleSize > 0 && opts.MaxSize == 0 {
+		return nil, fmt.Errorf("SampleSize needs MaxSize")
+	}
+	c.maxSize, c.sampleSize, c.maxMemory = opts.MaxSize, opts.SampleSize, opts.MaxMemory
+
+	//Check if the worker count is overridden
+	if opts.Workers > 0 {
+		c.workers = opts.Workers
+	}
+	//Check if the request rate is overridden
+	if opts.Rate > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+	//Check if hosts get rate limiters of their own
+	if opts.HostRate > 0 || len(opts.HostRates) > 0 {
+		overrides, err := parseHostRates(strings.Join(opts.HostRates, ","))
+		//Check if the host rates are malformed
+		if err != nil {
+			return nil, err
+		}
+		c.hostLimits = newHostLimiters(opts.HostRate, overrides)
+		//Check if hosts without an override run at the per-host rate, which status and control commands report
+		if opts.HostRate > 0 {
+			c.limiter.SetLimit(rate.Limit(opts.HostRate))
+		}
+	}
+	//Check if new hosts start slowly
+	if opts.RampUp > 0 {
+		c.rampUp = newRampUp(opts.RampUp, c.hostRate)
+	}
+	c.frontier.hostLimit = opts.HostConcurrency
+	c.sites = newSiteTracker(opts.HostMaxFailures)
+	c.maxPerHost = opts.MaxPerHost
+	c.hostDelay = newHostDelay(opts.HostDelay)
+	//Check if hosts that serve bot challenges are slowed down
+	if opts.BotSlowdown > 0 {
+		c.botThrottle = newHostThrottle(opts.BotSlowdown)
+	}
+	//Check if hosts in maintenance are paused
+	if opts.MaintenancePause > 0 {
+		c.maintenance = newMaintenance(opts.MaintenancePause)
+	}
+	//Check if transient failures are retried
+	if opts.Retries > 0 {
+		c.retry = &retryPolicy{retries: opts.Retries, backoff: cmp.Or(opts.RetryBackoff, time.Second), maxWait: cmp.Or(opts.RetryMaxWait, time.Minute)}
+	}
+
+	//Check if the allowed domains are malformed
+	if c.allowedHosts, err = parseAllowedHosts(strings.Join(opts.AllowedDomains, ",")); err != nil {
+		return nil, err
+	}
+	for _, prefix := range opts.PathPrefixes {
+		//Check if the prefix is not an absolute path
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix %q must start with \"/\"", prefix)
+		}
+	}
+	c.pathPrefixes = opts.PathPrefixes
+	c.include, c.exclude = opts.Include, opts.Exclude
+	c.checkExternalLinks = opts.CheckExternal
+	c.checkAssets = opts.CheckAssets || opts.Offline != ""
+	//Check if common paths are probed on every host
+	if opts.Discover {
+		c.discovery = newPathDiscovery()
+	}
+	//Check if links to fragments are verified
+	if opts.CheckFragments {
+		c.fragments = newFragmentCheck()
+	}
+	c.followAlternates = opts.FollowAlternates
+	//Check if AMP pages are verified
+	if opts.CheckAMP {
+		c.amp = newAMPCheck()
+	}
+	//Check if a focused crawl was requested
+	if c.keywords = parseKeywords(strings.Join(opts.Keywords, ",")); len(c.keywords) > 0 {
+		c.minRelevance = cmp.Or(opts.MinRelevance, 0.5)
+	}
+	c.verbosity = opts.Verbosity
+	c.Prioritizer = opts.Prioritizer
+	//Check if page changes are tracked across crawls
+	if opts.ChangeHistory != "" {
+		//Check if the history could not be loaded
+		if c.changes, err = loadChangeHistory(opts.ChangeHistory); err != nil {
+			return nil, err
+		}
+		//Check if no other order was asked for, which leaves frequently changing pages first
+		if c.Prioritizer == nil {
+			c.Prioritizer = c.changes.prioritizer()
+		}
+	}
+	c.seedFile = opts.SeedFile
+
+	//Check if a hook runs for every page
+	if opts.PageHook != "" {
+		//Check if the hook command is unusable
+		if c.pageHook, err = parseHookCommand(opts.PageHook); err != nil {
+			return nil, err
+		}
+	}
+	c.hookTimeout = cmp.Or(opts.HookTimeout, 10*time.Second)
+	//Check if a script customizes the crawl
+	if opts.Script != "" {
+		//Check if the script could not be loaded
+		if c.script, err = loadCrawlScript(opts.Script); err != nil {
+			return nil, err
+		}
+	}
+	extractors, err := parseWASMExtractors(strings.Join(opts.WASMExtractors, ","))
+	//Check if the WASM extractor list is invalid
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	for mediaType, path := range extractors {
+		plugin, err := loadWASMPlugin(context.Background(), path, "extract")
+		//Check if the plugin could not be loaded
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.plugins = append(c.plugins, plugin)
+		c.HandleContentType(mediaType, plugin.contentHandler())
+	}
+	for _, path := range opts.WASMProcessors {
+		plugin, err := loadWASMPlugin(context.Background(), path, "process")
+		//Check if the plugin could not be loaded
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.plugins = append(c.plugins, plugin)
+		c.processors = append(c.processors, plugin)
+	}
+
+	//Check if the link graph is recorded for a report
+	if opts.Report != nil {
+		c.graph = opts.Report.graph
+	}
+	c.keepBodies = opts.Archive != nil
+	//Check if buffered results are spilled to disk near the memory ceiling
+	if opts.Buffer != nil {
+		c.spillResults = opts.Buffer.spill
+	}
+	//Check if crawl progress is both logged and checkpointed, which would recover it twice
+	if opts.WAL != "" && opts.StateFile != "" {
+		c.Close()
+		return nil, fmt.Errorf("a write-ahead log cannot be combined with a state file")
+	}
+	//Check if crawl progress is logged for crash recovery
+	if opts.WAL != "" {
+		//Check if the log could not be opened or read
+		if c.wal, err = openWAL(opts.WAL); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	//Check if crawl progress is checkpointed
+	if opts.StateFile != "" {
+		//Check if the state to resume could not be read
+		if c.state, err = openCrawlState(opts.StateFile, cmp.Or(opts.StateInterval, 30*time.Second), opts.Resume); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	//Check if failed URL's are dead-lettered
+	if opts.DeadLetters != "" {
+		//Check if the dead-letter file could not be created
+		if c.deadLetters, err = createDeadLetterFile(opts.DeadLetters); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	//Check if the link graph is exported
+	if opts.Edges != "" {
+		//Check if the edge list could not be created
+		if c.edges, err = createEdgeFile(opts.Edges); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Close releases the script, WASM plugins and output files New opened, once the crawl has ended
+func (c *Crawler) Close() error {
+	//Check if a script was loaded
+	if c.script != nil {
+		c.script.Close()
+	}
+	for _, plugin := range c.plugins {
+		plugin.Close()
+	}
+	var errs []error
+	//Check if failed URL's were dead-lettered
+	if c.deadLetters != nil {
+		errs = append(errs, c.deadLetters.Close())
+	}
+	//Check if the link graph was exported
+	if c.edges != nil {
+		errs = append(errs, c.edges.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Seeds returns the crawl's seed URL's: the one New was given, if any, then those of the config
+func (c *Crawler) Seeds() []string {
+	var seeds []string
+	//Check if New was given a seed
+	if seed := c.baseURL.String(); seed != "" {
+		seeds = append(seeds, seed)
+	}
+	return append(seeds, c.seedOrder...)
+}
+
+// RunID returns the random prefix of the crawl's request ID's, which identifies the run
+func (c *Crawler) RunID() string {
+	return c.runID
+}
+
+// HTTPCacheStats returns how many requests the HTTP cache answered without contacting the server,
+// answered after revalidating with the server, and passed on to download a new response
+func (c *Crawler) HTTPCacheStats() (fresh, revalidated, downloaded int64) {
+	//Check if responses are not cached
+	if c.httpCache == nil {
+		return 0, 0, 0
+	}
+	return c.httpCache.hits.Load(), c.httpCache.revalidated.Load(), c.httpCache.misses.Load()
+}
+
+// headerOption returns the header value an Options field asks for: fallback for "", and "" to
+// leave the header out for "-"
+func headerOption(value, fallback string) string {
+	switch value {
+	case "":
+		return fallback
+	case "-":
+		return ""
+	}
+	return value
+}
+
+// Results yields the crawl's results and errors as they arrive, a result with a nil error or a
+// zero result with an error, until Run has returned. Errors are not fatal to the crawl; Run's
+// return value is. Results must be read while Run is running, since workers block once the
+// buffers are full. Stopping the iteration early stops the crawl.
+func (c *Crawler) Results() iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		results, errs := c.results, c.errors
+		for results != nil || errs != nil {
+			select {
+			case result, ok := <-results:
+				//Check if the last result was read
+				if !ok {
+					results = nil
+					continue
+				}
+				//Check if the caller stopped reading
+				if !yield(result, nil) {
+					c.drain(results, errs)
+					return
+				}
+			case err, ok := <-errs:
+				//Check if the last error was read
+				if !ok {
+					errs = nil
+					continue
+				}
+				//Check if the caller stopped reading
+				if !yield(Result{}, err) {
+					c.drain(results, errs)
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain stops the crawl and discards what the workers still send, so Run can return after the
+// caller of Results stopped reading
+func (c *Crawler) drain(results <-chan Result, errs <-chan error) {
+	c.Stop()
+	go func() {
+		for results != nil || errs != nil {
+			select {
+			case _, ok := <-results:
+				//Check if the last result was discarded
+				if !ok {
+					results = nil
+				}
+			case _, ok := <-errs:
+				//Check if the last error was discarded
+				if !ok {
+					errs = nil
+				}
+			}
+		}
+	}()
+}
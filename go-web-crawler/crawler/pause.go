@@ -0,0 +1,73 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate blocks requests while the crawl is paused, manually or by a blackout window
+type pauseGate struct {
+	mutex    sync.Mutex //Protects the flags below
+	cond     *sync.Cond //Signals waiters when the gate opens
+	paused   bool       //Set by Pause until Resume
+	blackout bool       //Set while a blackout window is active
+	released bool       //Set once the crawl is stopped, after which the gate never blocks
+}
+
+// newPauseGate creates an open gate
+func newPauseGate() *pauseGate {
+	g := &pauseGate{}
+	g.cond = sync.NewCond(&g.mutex)
+	return g
+}
+
+// update changes the gate's flags under its lock and wakes all waiters to re-check them
+func (g *pauseGate) update(change func()) {
+	g.mutex.Lock()
+	change()
+	g.mutex.Unlock()
+	g.cond.Broadcast()
+}
+
+// set pauses or resumes the crawl
+func (g *pauseGate) set(paused bool) {
+	g.update(func() { g.paused = paused })
+}
+
+// setBlackout starts or ends a blackout window
+func (g *pauseGate) setBlackout(active bool) {
+	g.update(func() { g.blackout = active })
+}
+
+// release opens the gate for good, so a stopped crawl can finish its held work
+func (g *pauseGate) release() {
+	g.update(func() { g.released = true })
+}
+
+// closed reports whether requests must wait; the caller must hold the mutex
+func (g *pauseGate) closed() bool {
+	return (g.paused || g.blackout) && !g.released
+}
+
+// isPaused reports whether the crawl is paused
+func (g *pauseGate) isPaused() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.closed()
+}
+
+// wait blocks while the crawl is paused, returning early with ctx's error if it is cancelled
+func (g *pauseGate) wait(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		g.mutex.Lock()
+		g.cond.Broadcast()
+		g.mutex.Unlock()
+	})
+	defer stop()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for g.closed() && ctx.Err() == nil {
+		g.cond.Wait()
+	}
+	return ctx.Err()
+}
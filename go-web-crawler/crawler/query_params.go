@@ -0,0 +1,201 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Query parameter policies
+const (
+	queryAll    = "all"    // Every value of the parameter is crawled, the default
+	queryFirst  = "first"  // Only the first value seen for otherwise identical URL's is crawled
+	queryIgnore = "ignore" // The parameter is removed before deduplication and fetching
+)
+
+// QueryRule sets how query parameters are crawled for URL's on a host and under a path prefix,
+// to tame faceted navigation without excluding its pages altogether. When several rules apply to
+// a URL, each parameter gets its policy from the most specific rule that names it.
+type QueryRule struct {
+	Host   string            `json:"host"`   //Host the rule applies to, with or without a port; empty for all hosts
+	Prefix string            `json:"prefix"` //Path prefix the rule applies to; empty for all paths
+	Params map[string]string `json:"params"` //Policy by parameter name: "all", "first" or "ignore"; a trailing * matches names by prefix
+}
+
+// validate checks the rule's prefix and policies
+func (r *QueryRule) validate() error {
+	//Check if the prefix is not a path
+	if r.Prefix != "" && !strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("prefix %q must start with \"/\"", r.Prefix)
+	}
+	for name, policy := range r.Params {
+		//Check if the policy is unknown
+		if policy != queryAll && policy != queryFirst && policy != queryIgnore {
+			return fmt.Errorf("parameter %q: invalid policy %q (expected \"all\", \"first\" or \"ignore\")", name, policy)
+		}
+	}
+	return nil
+}
+
+// policy returns the rule's policy for a parameter: an exact name match, or else the longest matching
+// pattern ending in *. It returns false if the rule doesn't cover the parameter.
+func (r *QueryRule) policy(name string) (string, bool) {
+	//Check if the parameter is named explicitly
+	if policy, ok := r.Params[name]; ok {
+		return policy, true
+	}
+	policy, longest := "", -1
+	for pattern, p := range r.Params {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		//Check if the pattern matches the name and is more specific than the current match
+		if ok && strings.HasPrefix(name, prefix) && len(prefix) > longest {
+			policy, longest = p, len(prefix)
+		}
+	}
+	return policy, longest >= 0
+}
+
+// specificity orders rules that apply to the same URL: host-specific rules come before rules for
+// all hosts, then longer prefixes before shorter ones
+func (r *QueryRule) specificity() int {
+	//Check if the rule is for a single host
+	if r.Host != "" {
+		return 1<<20 + len(r.Prefix)
+	}
+	return len(r.Prefix)
+}
+
+// queryPolicies applies query parameter rules to discovered URL's
+type queryPolicies struct {
+	rules []QueryRule       //Rules from the config file
+	mutex sync.Mutex        //Protects first
+	first map[string]string //First value of each "first" parameter, keyed by the URL without it and the parameter name
+}
+
+// newQueryPolicies returns the policies for the given rules, or nil if there are none
+func newQueryPolicies(rules []QueryRule) *queryPolicies {
+	//Check if no rules are configured
+	if len(rules) == 0 {
+		return nil
+	}
+	return &queryPolicies{rules: rules, first: make(map[string]string)}
+}
+
+// matching returns the rules that apply to a URL, most specific first
+func (p *queryPolicies) matching(u *url.URL) []*QueryRule {
+	var rules []*QueryRule
+	for i := range p.rules {
+		rule := &p.rules[i]
+		//Check if the rule is for another host
+		if rule.Host != "" && !strings.EqualFold(rule.Host, u.Host) && !strings.EqualFold(rule.Host, u.Hostname()) {
+			continue
+		}
+		//Check if the rule covers the path
+		if strings.HasPrefix(u.Path, rule.Prefix) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].specificity() > rules[j].specificity() })
+	return rules
+}
+
+// queryPolicy returns the policy of the most specific rule covering a parameter, or "all"
+func queryPolicy(rules []*QueryRule, name string) string {
+	for _, rule := range rules {
+		//Check if the rule covers the parameter
+		if policy, ok := rule.policy(name); ok {
+			return policy
+		}
+	}
+	return queryAll
+}
+
+// queryParam is one name=value pair of a query, with its original escaping
+type queryParam struct {
+	name string //Decoded parameter name
+	raw  string //Pair as it appears in the query
+}
+
+// splitQuery splits a raw query into its pairs, keeping their order and escaping
+func splitQuery(rawQuery string) []queryParam {
+	var params []queryParam
+	for _, pair := range strings.Split(rawQuery, "&") {
+		//Check if the pair is empty, e.g. from "a=1&&b=2"
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		//Check if the name is escaped
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		params = append(params, queryParam{name: name, raw: pair})
+	}
+	return params
+}
+
+// joinQuery joins pairs into a raw query, leaving out those named skip
+func joinQuery(params []queryParam, skip string) string {
+	var pairs []string
+	for _, param := range params {
+		//Check if the pair is kept
+		if param.name != skip {
+			pairs = append(pairs, param.raw)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// strip removes the parameters whose policy is "ignore" from a URL
+func (p *queryPolicies) strip(u *url.URL) {
+	//Check if the URL has no query
+	if u.RawQuery == "" {
+		return
+	}
+	rules := p.matching(u)
+	var pairs []string
+	for _, param := range splitQuery(u.RawQuery) {
+		//Check if the parameter is kept
+		if queryPolicy(rules, param.name) != queryIgnore {
+			pairs = append(pairs, param.raw)
+		}
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+	u.ForceQuery = false
+}
+
+// admit records the values of a URL's "first" parameters and returns why the URL is skipped if
+// an otherwise identical URL was admitted with another value, or "" if it may be crawled
+func (p *queryPolicies) admit(u *url.URL) string {
+	//Check if the URL has no query
+	if u.RawQuery == "" {
+		return ""
+	}
+	rules := p.matching(u)
+	params := splitQuery(u.RawQuery)
+	values := make(map[string]string)
+	for _, param := range params {
+		//Check if only the parameter's first value is crawled
+		if queryPolicy(rules, param.name) != queryFirst {
+			continue
+		}
+		others := *u
+		others.RawQuery = joinQuery(params, param.name)
+		values[others.String()+"\x00"+param.name] += param.raw + "&"
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, value := range values {
+		//Check if another value was admitted first
+		if first, ok := p.first[key]; ok && first != value {
+			name := key[strings.IndexByte(key, 0)+1:]
+			return fmt.Sprintf("query-param %s (first value only)", name)
+		}
+	}
+	for key, value := range values {
+		p.first[key] = value
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// rampUpWindow is the number of responses in a row without a host failure after which a host's
+// rate doubles
+const rampUpWindow = 5
+
+// hostRamp is the ramp-up state of one host
+type hostRamp struct {
+	limiter   *rate.Limiter //Limits the host's requests, rate.Inf once the crawl's rate is reached
+	successes int           //Responses without a host failure since the rate last changed
+}
+
+// rampUp starts every host at a low rate and doubles it while the host answers without failures,
+// until the crawl's own rate takes over, so an unknown server is not hit at full speed from the
+// first second. A host failure halves the host's rate again, down to the start rate.
+type rampUp struct {
+	mutex   sync.Mutex              //Protects hosts
+	start   rate.Limit              //Requests per second a new host starts at
+	ceiling func(string) rate.Limit //Rate of the crawl for a host, above which the host is no longer limited
+	hosts   map[string]*hostRamp    //Ramp-up state by lower-case host
+}
+
+// newRampUp creates a ramp-up starting hosts at start requests per second, up to the rate
+// ceiling returns for the host
+func newRampUp(start float64, ceiling func(string) rate.Limit) *rampUp {
+	return &rampUp{start: rate.Limit(start), ceiling: ceiling, hosts: make(map[string]*hostRamp)}
+}
+
+// host returns the state of a host, starting it at the start rate if it is new; the caller must
+// hold the mutex
+func (r *rampUp) host(host string) *hostRamp {
+	h, ok := r.hosts[host]
+	//Check if this is the host's first request
+	if !ok {
+		h = &hostRamp{limiter: rate.NewLimiter(r.start, 1)}
+		r.hosts[host] = h
+	}
+	return h
+}
+
+// wait blocks until the link's host may be requested at its current rate
+func (r *rampUp) wait(ctx context.Context, link string) error {
+	host := urlHost(link)
+	//Check if the link has no host to ramp up
+	if host == "" {
+		return nil
+	}
+	r.mutex.Lock()
+	limiter := r.host(host).limiter
+	r.mutex.Unlock()
+	return limiter.Wait(ctx)
+}
+
+// record counts a response of the host and changes its rate if needed: doubled after
+// rampUpWindow responses in a row without a host failure, halved after a failure. It returns the
+// new rate and whether it changed.
+func (r *rampUp) record(host string, failed bool) (rate.Limit, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	h := r.host(host)
+	current, ceiling := h.limiter.Limit(), r.ceiling(host)
+	//Check if the host failed, which slows it down again
+	if failed {
+		h.successes = 0
+		//Check if the host is at full speed, from where it falls back to half the crawl's rate
+		if current == rate.Inf {
+			current = ceiling
+		}
+		slower := max(current/2, r.start)
+		//Check if the host already runs at the start rate
+		if slower == h.limiter.Limit() {
+			return slower, false
+		}
+		h.limiter.SetLimit(slower)
+		return slower, true
+	}
+	h.successes++
+	//Check if the host has not answered enough requests in a row, or already runs at full speed
+	if h.successes < rampUpWindow || current == rate.Inf {
+		return current, false
+	}
+	h.successes = 0
+	faster := current * 2
+	//Check if the host reached the crawl's rate, which limits it from now on
+	if faster >= ceiling {
+		faster = rate.Inf
+	}
+	h.limiter.SetLimit(faster)
+	return faster, true
+}
+
+// recordRampUp adjusts the rate of a result's host after its response
+func (c *Crawler) recordRampUp(result Result) {
+	host := urlHost(result.URL)
+	//Check if hosts are not ramped up, or the result has no host or was not fetched
+	if c.rampUp == nil || host == "" || (result.Status == 0 && result.Error == "") {
+		return
+	}
+	limit, changed := c.rampUp.record(host, hostFailure(result))
+	//Check if the host's rate did not change
+	if !changed {
+		return
+	}
+	//Check if the host reached full speed
+	if limit == rate.Inf {
+		c.logf(1, "%s ramped up to the crawl's rate", host)
+		return
+	}
+	c.logf(1, "%s ramped to %.3g requests per second", host, float64(limit))
+}
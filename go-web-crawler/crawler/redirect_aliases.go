@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// redirectOwnerKey is the context key under which the queued URL of a crawled page's fetch is stored
+type redirectOwnerKey struct{}
+
+// withRedirectOwner returns a context carrying the queued URL a fetch is made for, so the
+// redirects it follows can be claimed for the URL's result
+func withRedirectOwner(ctx context.Context, pageURL string) context.Context {
+	return context.WithValue(ctx, redirectOwnerKey{}, pageURL)
+}
+
+// redirectOwner returns the queued URL stored in the context, or "" for fetches outside the crawl
+func redirectOwner(ctx context.Context) string {
+	pageURL, _ := ctx.Value(redirectOwnerKey{}).(string)
+	return pageURL
+}
+
+// coalescedRedirect stops a fetch at a redirect to a page another result reports, so the page is
+// requested only once however many URL's redirect to it
+type coalescedRedirect struct {
+	target string //URL the redirect pointed to
+	owner  string //URL of the result reporting the page
+}
+
+func (e *coalescedRedirect) Error() string {
+	return fmt.Sprintf("redirect to %s, which the result of %s reports", e.target, e.owner)
+}
+
+// claimRedirect records that the fetch of pageURL is about to follow a redirect to target and
+// returns the URL of the result that reports target already, or "" if the fetch is the first to
+// go there. Redirects are claimed before they are followed, so two URL's redirecting to the same
+// page at the same time don't both fetch it. A target that was queued itself is reported by its
+// own result, so it is never crawled again.
+func (c *Crawler) claimRedirect(pageURL string, target *url.URL) string {
+	// Normalize the target like a queued link, so both spellings of the page match
+	final := *target
+	final.Fragment = ""
+	canonicalizeURL(&final)
+	//Check if query parameters are subject to policies
+	if c.queryPolicies != nil {
+		c.queryPolicies.strip(&final)
+	}
+	key := final.String()
+	//Check if the chain leads back to the requested URL, e.g. after setting a cookie
+	if key == pageURL {
+		return ""
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	//Check if a redirect arrived at the page first, unless it was this fetch's own
+	if owner, ok := c.finalURLs[key]; ok {
+		//Check if this fetch claimed the page earlier in its chain
+		if owner == pageURL {
+			return ""
+		}
+		return owner
+	}
+	//Check if the target was queued or crawled itself, so its own result reports the page
+	if !c.visited.add(key) {
+		c.finalURLs[key] = key
+		return key
+	}
+	c.finalURLs[key] = pageURL
+	return ""
+}
@@ -0,0 +1,33 @@
+package crawler
+
+import "strings"
+
+// parseKeywords splits a comma-separated keyword list into trimmed, lower-case keywords
+func parseKeywords(list string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(list, ",") {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		//Check if the keyword is non-empty
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// relevance scores page text as the fraction of keywords it contains, from 0 to 1
+func relevance(text string, keywords []string) float64 {
+	//Check if there is nothing to score against
+	if len(keywords) == 0 {
+		return 1
+	}
+	text = strings.ToLower(text)
+	found := 0
+	for _, keyword := range keywords {
+		//Check if the keyword occurs in the text
+		if strings.Contains(text, keyword) {
+			found++
+		}
+	}
+	return float64(found) / float64(len(keywords))
+}
@@ -0,0 +1,346 @@
+package crawler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkGraph collects which crawled pages link to which URL's
+type linkGraph struct {
+	mutex   sync.Mutex                     //Protects inlinks
+	inlinks map[string]map[string]struct{} //Linking pages by canonical target URL
+}
+
+// newLinkGraph creates an empty link graph
+func newLinkGraph() *linkGraph {
+	return &linkGraph{inlinks: make(map[string]map[string]struct{})}
+}
+
+// record adds the links found on a page as edges from source
+func (g *linkGraph) record(source string, links []Link) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, link := range links {
+		target := canonicalLink(link.URL)
+		sources, ok := g.inlinks[target]
+		//Check if this is the first link to the target
+		if !ok {
+			sources = make(map[string]struct{})
+			g.inlinks[target] = sources
+		}
+		sources[source] = struct{}{}
+	}
+}
+
+// referrers returns the sorted pages linking to target
+func (g *linkGraph) referrers(target string) []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	sources := make([]string, 0, len(g.inlinks[target]))
+	for source := range g.inlinks[target] {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Report collects the results of a crawl for an HTML or Markdown report. Pass it to New in
+// Options, so the crawler records the links between pages, and add each result read.
+type Report struct {
+	graph   *linkGraph //Links between pages, recorded by the crawler
+	results []Result   //Reported results in arrival order
+	started time.Time  //When the crawl started
+}
+
+// NewReport creates an empty report of a crawl started at the given time
+func NewReport(started time.Time) *Report {
+	return &Report{graph: newLinkGraph(), started: started}
+}
+
+// Add records a crawled page
+func (r *Report) Add(result Result) {
+	r.results = append(r.results, result)
+}
+
+// Writer returns the function writing the report given by spec and the path to write it to, "-"
+// for stdout. A bare format name, "html" or "md", writes that format to stdout; otherwise the
+// file extension selects the format.
+func (r *Report) Writer(spec string) (func(io.Writer) error, string, error) {
+	format, path := strings.ToLower(filepath.Ext(spec)), spec
+	//Check if only a format was given
+	if spec == "html" || spec == "md" {
+		format, path = "."+spec, "-"
+	}
+	switch format {
+	case ".html", ".htm":
+		return r.writeHTML, path, nil
+	case ".md", ".markdown":
+		return r.writeMarkdown, path, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported report %q (expected a .html or .md file, or \"html\" or \"md\" for stdout)", spec)
+	}
+}
+
+//go:embed report.html
+var reportHTML string
+
+// reportTemplate renders the HTML report; the graph data is embedded as JSON for the script
+var reportTemplate = template.Must(template.New("report").Parse(reportHTML))
+
+// graphNode is a crawled page in the report's graph data
+type graphNode struct {
+	URL     string `json:"url"`     //Page URL
+	Title   string `json:"title"`   //Page title
+	Status  int    `json:"status"`  //HTTP status, 0 if no response was received
+	Class   string `json:"class"`   //Status classification
+	Inlinks int    `json:"inlinks"` //Number of crawled pages linking to the page
+}
+
+// graphData is the link graph between crawled pages, as used by the report's script
+type graphData struct {
+	Nodes []graphNode `json:"nodes"` //Crawled pages
+	Links [][2]int    `json:"links"` //Edges as source and target indexes into Nodes
+}
+
+// graphData builds the link graph between the crawled pages
+func (r *Report) graphData() graphData {
+	index := make(map[string]int, len(r.results))
+	data := graphData{Nodes: make([]graphNode, 0, len(r.results)), Links: [][2]int{}}
+	for _, result := range r.results {
+		//Check if the page was already added
+		if _, ok := index[result.URL]; ok {
+			continue
+		}
+		index[result.URL] = len(data.Nodes)
+		data.Nodes = append(data.Nodes, graphNode{URL: result.URL, Title: result.Title, Status: result.Status, Class: result.Class})
+	}
+	for target, t := range index {
+		for _, source := range r.graph.referrers(target) {
+			s, ok := index[source]
+			//Check if the linking page is part of the graph and not the page itself
+			if !ok || s == t {
+				continue
+			}
+			data.Links = append(data.Links, [2]int{s, t})
+			data.Nodes[t].Inlinks++
+		}
+	}
+	sort.Slice(data.Links, func(i, j int) bool {
+		//Check if the edges share a source
+		if data.Links[i][0] == data.Links[j][0] {
+			return data.Links[i][1] < data.Links[j][1]
+		}
+		return data.Links[i][0] < data.Links[j][0]
+	})
+	return data
+}
+
+// reportSlowest is how many of the slowest pages the report lists
+const reportSlowest = 20
+
+// countRow is a label with a count in the report's breakdown tables
+type countRow struct {
+	Label string //Status code or class
+	Count int    //Number of pages
+}
+
+// brokenLink is a failed page with the crawled pages linking to it
+type brokenLink struct {
+	Result    Result   //Failed result
+	Referrers []string //Pages linking to the URL, sorted
+}
+
+// titleGroup is a title shared by several pages
+type titleGroup struct {
+	Title string   //Shared title
+	URLs  []string //Pages with the title, sorted
+}
+
+// reportSummary holds the sections of the HTML report
+type reportSummary struct {
+	Started   time.Time     //When the crawl started
+	Duration  time.Duration //How long the crawl ran
+	Pages     int           //Number of results
+	Classes   []countRow    //Pages per status class
+	Statuses  []countRow    //Pages per status code
+	Broken    []brokenLink  //Failed pages with their referrers
+	Redirects []Result      //Pages that were redirected, with their chains
+	Slowest   []Result      //Slowest responses, slowest first
+	Titles    []titleGroup  //Titles used by more than one page
+	Graph     template.JS   //Link graph data for the script
+}
+
+// summary computes the report sections from the collected results, except for the link graph
+func (r *Report) summary() reportSummary {
+	sum := reportSummary{Started: r.started, Duration: time.Since(r.started).Round(time.Second), Pages: len(r.results)}
+
+	classes := make(map[string]int)
+	statuses := make(map[int]int)
+	titles := make(map[string][]string)
+	for _, result := range r.results {
+		classes[result.Class]++
+		statuses[result.Status]++
+		//Check if the page failed
+		if result.Class == ClassFailure {
+			sum.Broken = append(sum.Broken, brokenLink{Result: result, Referrers: r.graph.referrers(result.URL)})
+		}
+		//Check if the page was redirected
+		if len(result.Redirects) > 0 {
+			sum.Redirects = append(sum.Redirects, result)
+		}
+		//Check if the page has a title that can be compared
+		if result.Title != "" {
+			titles[result.Title] = append(titles[result.Title], result.URL)
+		}
+	}
+	for _, class := range []string{ClassSuccess, ClassWarning, ClassFailure} {
+		//Check if any page has the class
+		if classes[class] > 0 {
+			sum.Classes = append(sum.Classes, countRow{class, classes[class]})
+		}
+	}
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		label := strconv.Itoa(code)
+		//Check if no response was received
+		if code == 0 {
+			label = "no response"
+		}
+		sum.Statuses = append(sum.Statuses, countRow{label, statuses[code]})
+	}
+	sort.Slice(sum.Broken, func(i, j int) bool { return sum.Broken[i].Result.URL < sum.Broken[j].Result.URL })
+	sort.Slice(sum.Redirects, func(i, j int) bool { return sum.Redirects[i].URL < sum.Redirects[j].URL })
+
+	for _, result := range r.results {
+		//Check if a response was received to be timed
+		if result.Status != 0 {
+			sum.Slowest = append(sum.Slowest, result)
+		}
+	}
+	sort.SliceStable(sum.Slowest, func(i, j int) bool { return sum.Slowest[i].Elapsed > sum.Slowest[j].Elapsed })
+	sum.Slowest = sum.Slowest[:min(len(sum.Slowest), reportSlowest)]
+
+	for title, urls := range titles {
+		//Check if the title is shared
+		if len(urls) > 1 {
+			sort.Strings(urls)
+			sum.Titles = append(sum.Titles, titleGroup{title, urls})
+		}
+	}
+	sort.Slice(sum.Titles, func(i, j int) bool {
+		//Check if the titles are shared by the same number of pages
+		if len(sum.Titles[i].URLs) == len(sum.Titles[j].URLs) {
+			return sum.Titles[i].Title < sum.Titles[j].Title
+		}
+		return len(sum.Titles[i].URLs) > len(sum.Titles[j].URLs)
+	})
+	return sum
+}
+
+// writeHTML writes a self-contained HTML report: status breakdown, broken links with their
+// referrers, redirect chains, slowest pages, duplicate titles and a force-directed link graph
+func (r *Report) writeHTML(w io.Writer) error {
+	sum := r.summary()
+	graph, err := json.Marshal(r.graphData())
+	//Check if the graph could not be encoded
+	if err != nil {
+		return err
+	}
+	sum.Graph = template.JS(graph)
+	return reportTemplate.Execute(w, sum)
+}
+
+// reportMarkdownRows caps the broken links listed in the Markdown report, which is meant for
+// comments with a size limit
+const reportMarkdownRows = 100
+
+// writeMarkdown writes a GitHub-flavored Markdown summary for PR comments and issues: crawl
+// stats, the status and error class breakdowns, and broken links with their referrers
+func (r *Report) writeMarkdown(w io.Writer) error {
+	sum := r.summary()
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Crawl report\n\nCrawled **%d pages** in %s, started %s.\n\n",
+		sum.Pages, sum.Duration, sum.Started.Format("2006-01-02 15:04:05 MST"))
+
+	b.WriteString("| Class | Pages |\n| --- | ---: |\n")
+	for _, row := range sum.Classes {
+		fmt.Fprintf(&b, "| %s | %d |\n", row.Label, row.Count)
+	}
+	b.WriteString("\n| Status | Pages |\n| --- | ---: |\n")
+	for _, row := range sum.Statuses {
+		fmt.Fprintf(&b, "| %s | %d |\n", row.Label, row.Count)
+	}
+
+	errorClasses := make(map[string]int)
+	for _, broken := range sum.Broken {
+		errorClasses[errorClass(broken.Result)]++
+	}
+	//Check if any page failed
+	if len(errorClasses) > 0 {
+		classes := make([]string, 0, len(errorClasses))
+		for class := range errorClasses {
+			classes = append(classes, class)
+		}
+		sort.Slice(classes, func(i, j int) bool {
+			//Check if the classes have the same count
+			if errorClasses[classes[i]] == errorClasses[classes[j]] {
+				return classes[i] < classes[j]
+			}
+			return errorClasses[classes[i]] > errorClasses[classes[j]]
+		})
+		b.WriteString("\n### Error classes\n\n| Error class | Pages |\n| --- | ---: |\n")
+		for _, class := range classes {
+			fmt.Fprintf(&b, "| %s | %d |\n", class, errorClasses[class])
+		}
+	}
+
+	fmt.Fprintf(&b, "\n### Broken links (%d)\n\n", len(sum.Broken))
+	//Check if there is nothing to list
+	if len(sum.Broken) == 0 {
+		b.WriteString("No broken links.\n")
+	} else {
+		b.WriteString("| URL | Status | Error class | Linked from |\n| --- | ---: | --- | --- |\n")
+		for _, broken := range sum.Broken[:min(len(sum.Broken), reportMarkdownRows)] {
+			status := "none"
+			//Check if a response was received
+			if broken.Result.Status != 0 {
+				status = strconv.Itoa(broken.Result.Status)
+			}
+			referrers := "seed"
+			//Check if crawled pages link to the URL
+			if n := len(broken.Referrers); n > 0 {
+				referrers = strings.Join(broken.Referrers[:min(n, 3)], ", ")
+				//Check if some referrers are left out
+				if n > 3 {
+					referrers += fmt.Sprintf(" and %d more", n-3)
+				}
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCell(broken.Result.URL), status,
+				errorClass(broken.Result), markdownCell(referrers))
+		}
+		//Check if the list was cut off
+		if len(sum.Broken) > reportMarkdownRows {
+			fmt.Fprintf(&b, "\n_Showing %d of %d broken links._\n", reportMarkdownRows, len(sum.Broken))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", " ").Replace(text)
+}
@@ -0,0 +1,24 @@
+package crawler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// newRunID returns a random prefix for the request ID's of one crawl, so ID's from different runs
+// don't collide when their logs are combined
+func newRunID() string {
+	buf := make([]byte, 4)
+	//Check if the system's random source failed, which leaves the ID's unique within the run
+	if _, err := rand.Read(buf); err != nil {
+		return "00000000"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// newRequestID returns the ID of the next fetch, e.g. "5f3a9c1e-42". It prefixes the fetch's log
+// lines, is stored in its Result and dead letter, and is sent to the server with -request-id-header.
+func (c *Crawler) newRequestID() string {
+	return fmt.Sprintf("%s-%d", c.runID, c.requestSeq.Add(1))
+}
@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveMaxBody caps the body bytes kept per page, so a huge download cannot exhaust memory
+const archiveMaxBody = 64 << 20
+
+// archiveEntry is a line of the archive's index.jsonl, locating one result and its body
+type archiveEntry struct {
+	URL           string `json:"url"`                      //URL of the result
+	Status        int    `json:"status"`                   //HTTP status code, 0 if no response was received
+	Result        string `json:"result"`                   //Member holding the result as JSON
+	Body          string `json:"body,omitempty"`           //Member holding the response body, omitted if it was not kept
+	BodyBytes     int    `json:"body_bytes,omitempty"`     //Size of the body member
+	BodyTruncated bool   `json:"body_truncated,omitempty"` //Whether the body was cut at archiveMaxBody
+}
+
+// Archive writes results and the bodies of crawled pages into one zstd-compressed tar file,
+// so a large crawl produces a single file instead of one per page. Result n is stored as
+// pages/<n>.json, following the "result" definition of output.schema.json, and its body as
+// pages/<n>.body. The last member, index.jsonl, lists every URL with its members. Pass it to
+// New in Options, so the crawler keeps the bodies of the pages it parses.
+type Archive struct {
+	file    *os.File      //Archive file
+	zstd    *zstd.Encoder //Compresses the tar stream into the file
+	tar     *tar.Writer   //Writes the members
+	index   *os.File      //Temporary file collecting the index until the archive is closed
+	encoder *json.Encoder //Writes index entries to the temporary file
+	count   int           //Number of results written
+	started time.Time     //Modification time of the members
+}
+
+// CreateArchive creates or truncates the archive at path
+func CreateArchive(path string) (*Archive, error) {
+	file, err := os.Create(path)
+	//Check if the archive could not be created
+	if err != nil {
+		return nil, fmt.Errorf("error creating archive: %w", err)
+	}
+	index, err := os.CreateTemp("", "archive-index-*.jsonl")
+	//Check if the temporary index could not be created
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error creating archive index: %w", err)
+	}
+	encoder, err := zstd.NewWriter(file)
+	//Check if the compressor could not be created
+	if err != nil {
+		file.Close()
+		index.Close()
+		os.Remove(index.Name())
+		return nil, fmt.Errorf("error creating archive: %w", err)
+	}
+	return &Archive{
+		file:    file,
+		zstd:    encoder,
+		tar:     tar.NewWriter(encoder),
+		index:   index,
+		encoder: json.NewEncoder(index),
+		started: time.Now(),
+	}, nil
+}
+
+// Add writes a result, and its body if it was kept, to the archive. The body is released from the
+// result afterwards, so buffered results don't hold on to it.
+func (a *Archive) Add(result *Result) error {
+	a.count++
+	name := fmt.Sprintf("pages/%08d", a.count)
+	entry := archiveEntry{URL: result.URL, Status: result.Status, Result: name + ".json"}
+	data, err := json.Marshal(newResultRecord(*result))
+	//Check if the result could not be encoded
+	if err != nil {
+		return fmt.Errorf("error encoding %s for the archive: %w", result.URL, err)
+	}
+	//Check if the result could not be written
+	if err := a.write(entry.Result, data); err != nil {
+		return err
+	}
+	//Check if the page's body was kept
+	if result.body != nil {
+		entry.Body, entry.BodyBytes, entry.BodyTruncated = name+".body", len(result.body), result.bodyTruncated
+		//Check if the body could not be written
+		if err := a.write(entry.Body, result.body); err != nil {
+			return err
+		}
+		result.body = nil
+	}
+	//Check if the index entry could not be written
+	if err := a.encoder.Encode(entry); err != nil {
+		return fmt.Errorf("error writing archive index: %w", err)
+	}
+	return nil
+}
+
+// write adds a member to the archive
+func (a *Archive) write(name string, data []byte) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: a.started}
+	//Check if the member header could not be written
+	if err := a.tar.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	//Check if the member could not be written
+	if _, err := a.tar.Write(data); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	return nil
+}
+
+// Close appends the index and completes the archive; the archive is unreadable until it is closed
+func (a *Archive) Close() error {
+	defer os.Remove(a.index.Name())
+	defer a.index.Close()
+	size, err := a.index.Seek(0, io.SeekCurrent)
+	//Check if the index could be rewound for copying
+	if err == nil {
+		_, err = a.index.Seek(0, io.SeekStart)
+	}
+	//Check if the index member can be written
+	if err == nil {
+		err = a.tar.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "index.jsonl", Mode: 0o644, Size: size, ModTime: a.started})
+	}
+	//Check if the index can be copied into the archive
+	if err == nil {
+		_, err = io.Copy(a.tar, a.index)
+	}
+	//Check if the tar stream can be completed
+	if err == nil {
+		err = a.tar.Close()
+	}
+	//Check if the compressed stream can be completed
+	if err == nil {
+		err = a.zstd.Close()
+	}
+	//Check if the file could be closed, which must happen even if writing failed
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	//Check if the archive is incomplete
+	if err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	return nil
+}
+
+// cappedBuffer keeps the first archiveMaxBody bytes written to it and drops the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	truncated bool //Whether bytes were dropped
+}
+
+// Write keeps as much of p as fits, always reporting all of it as written
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	//Check if p does not fit completely
+	if room := archiveMaxBody - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// keptBody returns a reader of a response body that keeps a copy of what is read, and a function
+// that reads the rest of the body, if any, and stores the copy in the result for the archive.
+// Without an archive the body is returned as is.
+func (c *Crawler) keptBody(body io.Reader) (io.Reader, func(*Result)) {
+	//Check if bodies are not archived
+	if !c.keepBodies {
+		return body, func(*Result) {}
+	}
+	buf := &cappedBuffer{}
+	tee := io.TeeReader(body, buf)
+	return tee, func(result *Result) {
+		//Check if the rest of the body could not be read, which leaves the copy incomplete
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return
+		}
+		result.body, result.bodyTruncated = buf.Bytes(), buf.truncated
+	}
+}
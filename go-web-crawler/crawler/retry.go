@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryPolicy fetches a URL again after a transient failure: a timeout, a dropped connection, or
+// a 429, 502, 503 or 504 response. The wait before the nth retry is backoff·2^(n-1), of which a
+// random half is taken off so retries of many URL's don't hit the host at the same moment. A
+// Retry-After header on a 429 or 503 response replaces the computed wait. No wait exceeds maxWait.
+type retryPolicy struct {
+	retries int           //Fetches after the first one at most
+	backoff time.Duration //Wait before the first retry, doubled for each further one
+	maxWait time.Duration //Longest wait before a retry, also for Retry-After
+}
+
+// transientFailure reports whether a fetch failed in a way that another attempt may not
+func transientFailure(result Result, err error) bool {
+	//Check if bot protection answered, which a retry only provokes again
+	if result.Blocked != "" {
+		return false
+	}
+	switch result.Status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case 0:
+		//Check if the fetch did not fail
+		if err == nil {
+			return false
+		}
+		result.Error = err.Error()
+		class := errorClass(result)
+		return class == "timeout" || class == "stalled" || class == "connection"
+	}
+	return false
+}
+
+// wait returns how long to wait before retry number attempt of a failed fetch, and false if the
+// fetch is not retried
+func (p *retryPolicy) wait(attempt int, result Result, err error) (time.Duration, bool) {
+	//Check if the failure is permanent or the URL was retried often enough
+	if attempt > p.retries || !transientFailure(result, err) {
+		return 0, false
+	}
+	wait := p.backoff << (attempt - 1)
+	//Check if the doubling overflowed or went past the longest wait
+	if wait <= 0 || wait > p.maxWait {
+		wait = p.maxWait
+	}
+	wait -= rand.N(wait/2 + 1)
+	//Check if the server said when to come back
+	if result.retryAfter > 0 {
+		wait = min(result.retryAfter, p.maxWait)
+	}
+	return wait, true
+}
+
+// parseRetryAfter returns the wait a 429 or 503 response asks for with Retry-After, given in
+// seconds or as an HTTP date; 0 if there is none
+func parseRetryAfter(resp *http.Response) time.Duration {
+	//Check if the status does not carry Retry-After
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	//Check if the wait is given in seconds
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	//Check if the wait is given as the time to come back
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// fetchWithRetries fetches a page, fetching it again after transient failures as the retry policy
+// allows. The result of the last attempt is returned.
+func (c *Crawler) fetchWithRetries(ctx context.Context, pageURL string, depth int) (Result, *Page, error) {
+	result, page, err := c.fetchPage(withRedirectOwner(ctx, pageURL), pageURL, depth)
+	//Check if failed fetches are not retried
+	if c.retry == nil {
+		return result, page, err
+	}
+	for attempt := 1; ; attempt++ {
+		wait, ok := c.retry.wait(attempt, result, err)
+		//Check if the fetch is final
+		if !ok {
+			return result, page, err
+		}
+		reason := http.StatusText(result.Status)
+		//Check if the fetch failed without a response
+		if result.Status == 0 {
+			reason = err.Error()
+		}
+		c.logf(1, "[%s] %s: %s, retrying in %s (retry %d of %d)", result.RequestID, pageURL, reason, wait.Round(time.Millisecond), attempt, c.retry.retries)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, page, err
+		}
+		result, page, err = c.fetchPage(withRedirectOwner(ctx, pageURL), pageURL, depth)
+	}
+}
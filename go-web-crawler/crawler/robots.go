@@ -0,0 +1,238 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsMaxSize is the most of a robots.txt file that is parsed, as RFC 9309 allows
+const robotsMaxSize = 500 << 10
+
+// robotsRule is an Allow or Disallow line of a robots.txt group
+type robotsRule struct {
+	pattern string //Path pattern, where "*" matches any characters and a trailing "$" the end of the path
+	allow   bool   //Whether the rule allows rather than disallows matching paths
+}
+
+// robotsRules are the rules of a host's robots.txt that apply to the crawler's user agent
+type robotsRules struct {
+	rules      []robotsRule  //Allow and Disallow rules of the matching groups
+	crawlDelay time.Duration //Crawl-delay of the matching groups, 0 if none is given
+}
+
+// robotsAgent returns the product token of a User-Agent header that robots.txt groups are matched
+// against, e.g. "MyCrawler" for "MyCrawler/1.0 (+https://example.com/bot)"
+func robotsAgent(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(token)
+}
+
+// parseRobots reads the groups of a robots.txt file that apply to agent, a lower-case product
+// token. Groups naming the agent are used if there are any, the "*" groups otherwise.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	var named, wildcard robotsRules
+	namedFound := false        //Set once a group names the agent
+	var current []*robotsRules //Rule sets of the group being read, one per matching user-agent line
+	inRules := false           //Set once the group's rules start, so a user-agent line starts a new group
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsMaxSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		//Check if the line is not a field
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			//Check if the line starts a new group
+			if inRules {
+				current, inRules = nil, false
+			}
+			switch strings.ToLower(value) {
+			case agent:
+				current, namedFound = append(current, &named), true
+			case "*":
+				current = append(current, &wildcard)
+			}
+		case "allow", "disallow":
+			inRules = true
+			//Check if an empty Disallow, which allows everything, or an empty Allow is given
+			if value == "" {
+				continue
+			}
+			for _, rules := range current {
+				rules.rules = append(rules.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			//Check if the delay is not a usable number of seconds
+			if err != nil || seconds <= 0 {
+				continue
+			}
+			for _, rules := range current {
+				rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	//Check if no group names the agent, which leaves the wildcard groups
+	if !namedFound {
+		return &wildcard
+	}
+	return &named
+}
+
+// allowed reports whether a path, with its query, may be crawled: the longest matching rule decides,
+// and Allow wins a tie. Paths no rule matches are allowed.
+func (r *robotsRules) allowed(path string) bool {
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		//Check if the rule matches and is at least as specific as the best so far
+		if len(rule.pattern) < longest || !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		//Check if the rule ties with the best so far, where Allow wins
+		if len(rule.pattern) == longest {
+			allowed = allowed || rule.allow
+			continue
+		}
+		allowed, longest = rule.allow, len(rule.pattern)
+	}
+	return allowed
+}
+
+// robotsMatch reports whether a robots.txt path pattern matches the start of a path, or all of it
+// if the pattern ends with "$"
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	//Check if the path does not start with the part before the first wildcard
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		//Check if this is the last part of an anchored pattern, which must end the path
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		index := strings.Index(rest, part)
+		//Check if the part does not occur in the rest of the path
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// robotsEntry is the robots.txt of one host, fetched once by the first worker needing it
+type robotsEntry struct {
+	ready chan struct{} //Closed once rules is set
+	rules *robotsRules  //Rules for the crawler's user agent
+}
+
+// robotsCache fetches and keeps the robots.txt of every host the crawl visits
+type robotsCache struct {
+	mutex    sync.Mutex              //Protects hosts
+	hosts    map[string]*robotsEntry //robots.txt by lower-case scheme and host
+	maxDelay time.Duration           //Longest Crawl-delay honored, 0 for any
+}
+
+// newRobotsCache creates an empty cache
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsEntry)}
+}
+
+// robotsAllowed reports whether the robots.txt of a page's host allows crawling the page. The
+// file is fetched the first time a host is seen, and its Crawl-delay, up to the cache's ceiling,
+// spaces out the host's requests. Local files and simulated crawls have no robots.txt.
+func (c *Crawler) robotsAllowed(ctx context.Context, page *url.URL) bool {
+	//Check if robots.txt is ignored or the page is not on a web server
+	if c.robots == nil || (page.Scheme != "http" && page.Scheme != "https") || c.simulated {
+		return true
+	}
+	key := strings.ToLower(page.Scheme + "://" + page.Host)
+	c.robots.mutex.Lock()
+	entry, ok := c.robots.hosts[key]
+	//Check if this is the first page of the host, whose robots.txt this worker fetches
+	if !ok {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		c.robots.hosts[key] = entry
+	}
+	c.robots.mutex.Unlock()
+	//Check if the file has to be fetched
+	if !ok {
+		entry.rules = c.fetchRobots(ctx, key)
+		delay := entry.rules.crawlDelay
+		//Check if the host asks for a longer delay than the crawl waits, which would stall it
+		if c.robots.maxDelay > 0 && delay > c.robots.maxDelay {
+			c.logf(0, "%s asks for a crawl delay of %s, waiting only %s between its requests", page.Host, delay, c.robots.maxDelay)
+			delay = c.robots.maxDelay
+		} else if delay > 0 {
+			c.logf(1, "%s asks for a crawl delay of %s", page.Host, delay)
+		}
+		//Check if the host gets a delay, which applies before any of its pages is fetched
+		if delay > 0 {
+			c.hostDelay.setHost(page.Host, delay)
+		}
+		close(entry.ready)
+	}
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return false
+	}
+	path := page.EscapedPath()
+	//Check if the path is empty, which is the root
+	if path == "" {
+		path = "/"
+	}
+	//Check if the query is part of what the rules match
+	if page.RawQuery != "" {
+		path += "?" + page.RawQuery
+	}
+	return entry.rules.allowed(path)
+}
+
+// fetchRobots fetches the robots.txt of a host given as scheme and host. A missing file allows
+// everything; a server error disallows everything, since the site may be in trouble. A failed
+// request allows everything, so the pages' own requests report the failure.
+func (c *Crawler) fetchRobots(ctx context.Context, origin string) *robotsRules {
+	robotsURL := origin + "/robots.txt"
+	//Check if the crawl was cancelled while waiting for the rate limiter
+	if err := c.waitForTurn(ctx, robotsURL); err != nil {
+		return &robotsRules{}
+	}
+	req, err := c.newRequest(ctx, "GET", robotsURL, c.newRequestID())
+	//Check if the request could not be created
+	if err != nil {
+		return &robotsRules{}
+	}
+	resp, err := c.do(req)
+	//Check if the request failed
+	if err != nil {
+		c.logf(1, "cannot fetch %s, allowing all pages: %v", robotsURL, err)
+		return &robotsRules{}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		c.logf(0, "%s answered %s, not crawling the host", robotsURL, resp.Status)
+		return &robotsRules{rules: []robotsRule{{pattern: "/"}}}
+	case resp.StatusCode != http.StatusOK:
+		c.logf(2, "%s answered %s, allowing all pages", robotsURL, resp.Status)
+		return &robotsRules{}
+	}
+	return parseRobots(resp.Body, robotsAgent(c.userAgent))
+}
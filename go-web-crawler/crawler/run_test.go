@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// treeSite serves a site where page /n/i links to /n/10i+1 through /n/10i+10, after waiting delay
+func treeSite(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/n/"))
+		//Check if the path is not a page of the tree, e.g. robots.txt
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>page</title></head><body>")
+		for k := 1; k <= 10; k++ {
+			fmt.Fprintf(w, `<a href="/n/%d">child</a>`, id*10+k)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+// runCrawl runs a crawl of the tree site with many workers and reads its results, stopping after
+// stopAfter results if it is positive. It returns the results read and Run's error.
+func runCrawl(t *testing.T, ctx context.Context, srv *httptest.Server, opts Options, stopAfter int) ([]Result, error) {
+	t.Helper()
+	opts.Workers, opts.Rate, opts.Verbosity = 64, 10000, -1
+	c, err := New(srv.URL+"/n/0", opts)
+	//Check if the crawler could not be created
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- c.Run(ctx) }()
+	var results []Result
+	for result, err := range c.Results() {
+		//Check if the entry is an error rather than a result
+		if err != nil {
+			continue
+		}
+		results = append(results, result)
+		//Check if the caller stops reading early
+		if stopAfter > 0 && len(results) == stopAfter {
+			break
+		}
+	}
+	select {
+	case err := <-runErr:
+		c.client.CloseIdleConnections()
+		return results, err
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return")
+		return nil, nil
+	}
+}
+
+// checkGoroutines fails the test if goroutines started during it are still running after a grace period
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		//Check if the goroutines had enough time to exit
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines running, %d before the crawl:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunConcurrent crawls a tree with many workers and checks that every page is reported once
+func TestRunConcurrent(t *testing.T) {
+	srv := treeSite(0)
+	defer srv.Close()
+	before := runtime.NumGoroutine()
+	results, err := runCrawl(t, context.Background(), srv, Options{MaxDepth: 3, MaxPages: 1000}, 0)
+	//Check if the crawl failed
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, result := range results {
+		//Check if the page was reported twice
+		if seen[result.URL] {
+			t.Errorf("%s reported twice", result.URL)
+		}
+		seen[result.URL] = true
+		//Check if the page failed
+		if result.Status != http.StatusOK {
+			t.Errorf("%s: status %d, error %q", result.URL, result.Status, result.Error)
+		}
+	}
+	// 1 seed, 10 pages at depth 2 and 100 at depth 3
+	if len(seen) != 111 {
+		t.Errorf("crawled %d pages, want 111", len(seen))
+	}
+	srv.Close()
+	checkGoroutines(t, before)
+}
+
+// TestRunMaxPages checks that concurrent workers don't visit more pages than the budget allows
+func TestRunMaxPages(t *testing.T) {
+	srv := treeSite(0)
+	defer srv.Close()
+	results, err := runCrawl(t, context.Background(), srv, Options{MaxDepth: 5, MaxPages: 50}, 0)
+	//Check if the crawl failed
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	//Check if the budget was exceeded
+	if len(results) != 50 {
+		t.Errorf("crawled %d pages, want 50", len(results))
+	}
+}
+
+// TestRunCancel cancels a crawl while pages are being fetched and checks that Run returns
+// promptly, the interrupted pages are not reported, and no goroutine is left behind
+func TestRunCancel(t *testing.T) {
+	srv := treeSite(20 * time.Millisecond)
+	defer srv.Close()
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	results, err := runCrawl(t, ctx, srv, Options{MaxDepth: 5, MaxPages: 10000}, 0)
+	//Check if Run failed for another reason than the cancellation
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+	//Check if the crawl went on long after the cancellation
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run returned %s after the crawl started", elapsed)
+	}
+	//Check if the crawl ended before the cancellation, or ignored it
+	if len(results) == 0 || len(results) >= 10000 {
+		t.Errorf("crawled %d pages before the cancellation", len(results))
+	}
+	for _, result := range results {
+		//Check if an interrupted fetch was reported as a failure
+		if result.Status != http.StatusOK {
+			t.Errorf("%s: status %d, error %q", result.URL, result.Status, result.Error)
+		}
+	}
+	srv.Close()
+	checkGoroutines(t, before)
+}
+
+// TestResultsStopEarly stops reading results after a few and checks that the crawl stops and Run
+// returns instead of blocking on the full results channel
+func TestResultsStopEarly(t *testing.T) {
+	srv := treeSite(0)
+	defer srv.Close()
+	before := runtime.NumGoroutine()
+	results, err := runCrawl(t, context.Background(), srv, Options{MaxDepth: 5, MaxPages: 10000}, 5)
+	//Check if the crawl failed
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	//Check if more results were read than asked for
+	if len(results) != 5 {
+		t.Errorf("read %d results, want 5", len(results))
+	}
+	srv.Close()
+	checkGoroutines(t, before)
+}
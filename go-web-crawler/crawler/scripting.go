@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// crawlScript runs a user's Lua script that customizes the crawl through optional global functions:
+//
+//	shouldFollow(url, page)  returns false to not follow a link found on page
+//	extract(page)            returns a table of values attached to the page's result as annotations
+//	onResult(result)         is called for every reported result, e.g. to write custom output
+//
+// Pages are tables with url, depth, status, title, text and links, where each link has url, text,
+// frame, nofollow and position. Results are tables with the properties of -format json. A single Lua state
+// runs the script, so calls are serialized and the script may keep state in globals.
+type crawlScript struct {
+	mutex        sync.Mutex     //Serializes calls into the Lua state, which is not safe for concurrent use
+	state        *lua.LState    //Lua state that loaded the script
+	path         string         //Script file, for error messages
+	shouldFollow *lua.LFunction //Global shouldFollow, nil if the script doesn't define it
+	extract      *lua.LFunction //Global extract, nil if the script doesn't define it
+	onResult     *lua.LFunction //Global onResult, nil if the script doesn't define it
+}
+
+// loadCrawlScript runs the script at path and looks up the functions it defines
+func loadCrawlScript(path string) (*crawlScript, error) {
+	state := lua.NewState()
+	//Check if the script could not be loaded or failed while running its top level
+	if err := state.DoFile(path); err != nil {
+		state.Close()
+		return nil, fmt.Errorf("error loading script %s: %w", path, err)
+	}
+	s := &crawlScript{state: state, path: path}
+	s.shouldFollow, _ = state.GetGlobal("shouldFollow").(*lua.LFunction)
+	s.extract, _ = state.GetGlobal("extract").(*lua.LFunction)
+	s.onResult, _ = state.GetGlobal("onResult").(*lua.LFunction)
+	//Check if the script defines none of the functions
+	if s.shouldFollow == nil && s.extract == nil && s.onResult == nil {
+		state.Close()
+		return nil, fmt.Errorf("script %s defines none of shouldFollow, extract and onResult", path)
+	}
+	return s, nil
+}
+
+// Close releases the Lua state
+func (s *crawlScript) Close() {
+	s.state.Close()
+}
+
+// call runs a script function with the given arguments, returning its result or an error if it
+// failed; the crawl's context interrupts scripts that run after it was cancelled
+func (s *crawlScript) call(ctx context.Context, fn *lua.LFunction, args ...lua.LValue) (lua.LValue, error) {
+	s.state.SetContext(ctx)
+	defer s.state.RemoveContext()
+	//Check if the function raised an error
+	if err := s.state.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		return lua.LNil, err
+	}
+	ret := s.state.Get(-1)
+	s.state.Pop(1)
+	return ret, nil
+}
+
+// pageTable converts a crawled page to the table passed to the script; the caller must hold the mutex
+func (s *crawlScript) pageTable(result Result, page *Page) *lua.LTable {
+	table := s.state.NewTable()
+	table.RawSetString("url", lua.LString(result.URL))
+	table.RawSetString("depth", lua.LNumber(result.Depth))
+	table.RawSetString("status", lua.LNumber(result.Status))
+	table.RawSetString("title", lua.LString(page.Title))
+	table.RawSetString("text", lua.LString(page.Text))
+	links := s.state.NewTable()
+	for _, link := range page.Links {
+		entry := s.state.NewTable()
+		entry.RawSetString("url", lua.LString(link.URL))
+		entry.RawSetString("text", lua.LString(link.Text))
+		entry.RawSetString("frame", lua.LBool(link.Frame))
+		entry.RawSetString("nofollow", lua.LBool(link.NoFollow))
+		entry.RawSetString("position", lua.LString(link.Position))
+		links.Append(entry)
+	}
+	table.RawSetString("links", links)
+	return table
+}
+
+// luaValue converts a value decoded from JSON to Lua; the caller must hold the mutex
+func (s *crawlScript) luaValue(value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case string:
+		return lua.LString(v)
+	case float64:
+		return lua.LNumber(v)
+	case bool:
+		return lua.LBool(v)
+	case []interface{}:
+		table := s.state.NewTable()
+		for _, item := range v {
+			table.Append(s.luaValue(item))
+		}
+		return table
+	case map[string]interface{}:
+		table := s.state.NewTable()
+		for key, item := range v {
+			table.RawSetString(key, s.luaValue(item))
+		}
+		return table
+	}
+	return lua.LNil
+}
+
+// inspectPage runs extract and shouldFollow for a parsed page. It returns the values extract
+// attached, nil if none, and the links shouldFollow rejected. Script errors are returned along
+// with what was gathered before them.
+func (s *crawlScript) inspectPage(ctx context.Context, result Result, page *Page) (map[string]string, map[string]bool, error) {
+	//Check if the script has nothing to do with pages
+	if s.extract == nil && s.shouldFollow == nil {
+		return nil, nil, nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	table := s.pageTable(result, page)
+	var values map[string]string
+	//Check if the script extracts values from pages
+	if s.extract != nil {
+		ret, err := s.call(ctx, s.extract, table)
+		//Check if extract failed
+		if err != nil {
+			return nil, nil, fmt.Errorf("extract: %w", err)
+		}
+		//Check if extract returned values
+		if extracted, ok := ret.(*lua.LTable); ok {
+			values = make(map[string]string)
+			extracted.ForEach(func(key, value lua.LValue) {
+				values[key.String()] = value.String()
+			})
+		}
+	}
+	var rejected map[string]bool
+	//Check if the script decides which links to follow
+	if s.shouldFollow != nil {
+		rejected = make(map[string]bool)
+		for _, link := range page.Links {
+			ret, err := s.call(ctx, s.shouldFollow, lua.LString(link.URL), table)
+			//Check if shouldFollow failed
+			if err != nil {
+				return values, rejected, fmt.Errorf("shouldFollow: %w", err)
+			}
+			//Check if the link was rejected; only an explicit false counts, so a function without a return follows it
+			if ret == lua.LFalse {
+				rejected[link.URL] = true
+			}
+		}
+	}
+	return values, rejected, nil
+}
+
+// reportResult passes a result to onResult, if the script defines it
+func (s *crawlScript) reportResult(ctx context.Context, result Result) error {
+	//Check if the script doesn't handle results
+	if s.onResult == nil {
+		return nil
+	}
+	data, err := json.Marshal(newResultRecord(result))
+	//Check if the result could not be encoded
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	//Check if the encoded result could not be decoded, which cannot happen for valid JSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	//Check if onResult failed
+	if _, err := s.call(ctx, s.onResult, s.luaValue(decoded)); err != nil {
+		return fmt.Errorf("onResult: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// seedFileCheckInterval is how often the watched seed file is checked for new lines
+const seedFileCheckInterval = time.Second
+
+// startSeedFile queues the URL's in the crawler's seed file, one per line, and then watches it while
+// the crawl runs to queue every URL appended to it. Blank lines and lines starting with # are
+// ignored. The file only adds work to a running crawl and does not keep it alive once the frontier
+// runs dry.
+func (c *Crawler) startSeedFile(ctx context.Context) {
+	//Check if no seed file is watched
+	if c.seedFile == "" {
+		return
+	}
+	offset := c.readSeedFile(0)
+	go func() {
+		ticker := time.NewTicker(seedFileCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				offset = c.readSeedFile(offset)
+			}
+		}
+	}()
+}
+
+// readSeedFile queues the complete lines added to the seed file after offset and returns the offset
+// to continue from. A file that shrank was replaced and is read from its start.
+func (c *Crawler) readSeedFile(offset int64) int64 {
+	file, err := os.Open(c.seedFile)
+	//Check if the file does not exist yet or cannot be read
+	if err != nil {
+		//Check if the file is there but unreadable
+		if !os.IsNotExist(err) {
+			c.logf(-1, "error reading seed file: %v", err)
+		}
+		return offset
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	//Check if the file's size is unknown
+	if err != nil {
+		c.logf(-1, "error reading seed file: %v", err)
+		return offset
+	}
+	//Check if the file was truncated or replaced by a shorter one
+	if info.Size() < offset {
+		offset = 0
+	}
+	//Check if nothing was added
+	if info.Size() == offset {
+		return offset
+	}
+	data, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	//Check if the new lines could not be read
+	if err != nil {
+		c.logf(-1, "error reading seed file: %v", err)
+		return offset
+	}
+	// Leave a line that is still being written for the next check
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	var urls []string
+	for _, line := range strings.Split(string(data[:complete]), "\n") {
+		line = strings.TrimSpace(line)
+		//Check if the line is blank or a comment
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		//Check if the line is not a usable URL, which must not hold up the others
+		if err := checkSeedURL(line); err != nil {
+			c.logf(-1, "%s: %v", c.seedFile, err)
+			continue
+		}
+		urls = append(urls, line)
+	}
+	//Check if there are seeds to queue
+	if len(urls) > 0 {
+		//Check if the seeds could not be queued
+		if _, err := c.AddSeeds(urls); err != nil {
+			c.logf(-1, "error adding seeds from %s: %v", c.seedFile, err)
+		}
+	}
+	return offset + int64(complete)
+}
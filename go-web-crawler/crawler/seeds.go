@@ -0,0 +1,147 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// Seed is a start URL from the config file with its own crawl settings, so unrelated sites can be
+// crawled in one job with the depth, scope and politeness each of them needs
+type Seed struct {
+	URL            string            `json:"url"`             //Start URL
+	MaxDepth       int               `json:"max_depth"`       //Max depth of the seed's pages, 0 keeps the crawl's
+	AllowedDomains []string          `json:"allowed_domains"` //Hosts crawled from this seed besides its own, as host or host=depth
+	PathRules      []PathRule        `json:"path_rules"`      //Path rules for the seed's pages, replacing the crawl's
+	Rate           float64           `json:"rate"`            //Requests per second for the seed's pages, 0 shares the crawl's rate limiter
+	Headers        map[string]string `json:"headers"`         //Extra request headers for the seed's pages
+}
+
+// validate checks the seed's URL and settings
+func (s *Seed) validate() error {
+	u, err := url.Parse(s.URL)
+	//Check if the URL is not an absolute web URL
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", s.URL)
+	}
+	//Check if the limits are negative
+	if s.MaxDepth < 0 || s.Rate < 0 {
+		return fmt.Errorf("max_depth and rate must not be negative")
+	}
+	//Check if an allowed domain is malformed
+	if _, err := parseAllowedHosts(strings.Join(s.AllowedDomains, ",")); err != nil {
+		return err
+	}
+	return validatePathRules(s.PathRules)
+}
+
+// seedScope is a configured seed with its settings prepared for crawling
+type seedScope struct {
+	url          string            //Seed URL, which tags the seed's frontier items and results
+	base         *url.URL          //Parsed seed URL
+	maxDepth     int               //Max depth, 0 for the crawl's
+	allowedHosts map[string]int    //Max depth by lower-case host crawled besides the seed host, 0 for the seed's
+	pathRules    []PathRule        //Path rules, nil for the crawl's
+	limiter      *rate.Limiter     //Rate limiter, nil for the crawl's
+	headers      map[string]string //Extra request headers
+}
+
+// addSeeds sets up the seeds from the config, which are crawled along with the command-line seed
+func (c *Crawler) addSeeds(seeds []Seed) {
+	//Check if there is nothing to add
+	if len(seeds) == 0 {
+		return
+	}
+	c.seeds = make(map[string]*seedScope, len(seeds))
+	for _, seed := range seeds {
+		base, _ := url.Parse(seed.URL) // Checked when the config was loaded, like the allowed domains
+		allowedHosts, _ := parseAllowedHosts(strings.Join(seed.AllowedDomains, ","))
+		scope := &seedScope{
+			url:          seed.URL,
+			base:         base,
+			maxDepth:     seed.MaxDepth,
+			allowedHosts: allowedHosts,
+			pathRules:    seed.PathRules,
+			headers:      seed.Headers,
+		}
+		//Check if the seed has its own rate limit
+		if seed.Rate > 0 {
+			scope.limiter = rate.NewLimiter(rate.Limit(seed.Rate), 1)
+		}
+		c.seeds[seed.URL] = scope
+		c.seedOrder = append(c.seedOrder, seed.URL)
+	}
+}
+
+// hostAllowed reports whether a URL is on the seed's host or one of its allowed hosts
+func (s *seedScope) hostAllowed(link *url.URL) bool {
+	//Check if the URL is on the seed host
+	if strings.EqualFold(link.Host, s.base.Host) {
+		return true
+	}
+	_, ok := allowedDepth(s.allowedHosts, link)
+	return ok
+}
+
+// seedContextKey is the context key under which the seed of a fetch is stored
+type seedContextKey struct{}
+
+// withSeed returns a context carrying the seed whose page is fetched, so the rate limiter and
+// request headers apply the seed's settings, including on credential retries and redirects
+func withSeed(ctx context.Context, seed *seedScope) context.Context {
+	//Check if the page belongs to a seed with its own settings
+	if seed == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, seedContextKey{}, seed)
+}
+
+// seedFrom returns the seed stored in the context, or nil for pages using the crawl's settings
+func seedFrom(ctx context.Context) *seedScope {
+	seed, _ := ctx.Value(seedContextKey{}).(*seedScope)
+	return seed
+}
+
+// setSeedHeaders adds the headers of the request's seed
+func setSeedHeaders(req *http.Request) {
+	//Check if the request belongs to a seed with its own settings
+	if seed := seedFrom(req.Context()); seed != nil {
+		for name, value := range seed.headers {
+			req.Header.Set(name, value)
+		}
+	}
+}
+
+// inScope reports whether a URL is on a host crawled from the given seed, or from the command-line
+// seed if seed is nil
+func (c *Crawler) inScope(link *url.URL, seed *seedScope) bool {
+	//Check if the URL belongs to a seed with its own scope
+	if seed != nil {
+		return seed.hostAllowed(link)
+	}
+	//Check if the URL is on the seed host
+	if strings.EqualFold(link.Host, c.baseURL.Host) {
+		return true
+	}
+	_, ok := allowedDepth(c.allowedHosts, link)
+	return ok
+}
+
+// pathRule returns the path rule for a page of the given seed, and the key its page budget is counted under
+func (c *Crawler) pathRule(seed *seedScope, path string) (*PathRule, string) {
+	rules, key := c.pathRules, ""
+	//Check if the seed has its own path rules
+	if seed != nil && seed.pathRules != nil {
+		rules, key = seed.pathRules, seed.url+" "
+	}
+	rule := matchPathRule(rules, path)
+	//Check if no rule matches
+	if rule == nil {
+		return nil, ""
+	}
+	return rule, key + rule.Prefix
+}
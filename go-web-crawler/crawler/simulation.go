@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// graphLink is an edge of a recorded link graph
+type graphLink struct {
+	target   string //Canonical URL the link points to
+	text     string //Anchor text
+	noFollow bool   //Whether the anchor carried rel="nofollow"
+}
+
+// Simulation replays the link graph of an earlier crawl, written by -edges, instead of fetching
+// pages. Every URL is answered with an HTML page holding the links recorded for it, so the crawl's
+// depth, budget, priority and scope settings decide what is reached exactly as in a real crawl,
+// without a single request. Pass it to New in Options, which answers the crawler's requests from it.
+type Simulation struct {
+	links   map[string][]graphLink //Links recorded on each page by canonical source URL
+	known   map[string]bool        //Every URL of the graph, as source or target
+	reached map[string]bool        //URL's the simulated crawl reported a page for
+	crawler *Crawler               //Crawler replaying the graph, whose scope limits the coverage
+}
+
+// LoadSimulation reads an edge list written by -edges
+func LoadSimulation(path string) (*Simulation, error) {
+	file, err := os.Open(path)
+	//Check if the edge list could not be opened
+	if err != nil {
+		return nil, fmt.Errorf("error opening edge list: %w", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	//Check if the file does not start with the header -edges writes, with or without the position column added later
+	if err != nil || !strings.HasPrefix(strings.Join(header, ",")+",", "source,target,anchor_text,nofollow,") || len(header) > 5 {
+		return nil, fmt.Errorf("%s is not an edge list written by -edges", path)
+	}
+	s := &Simulation{links: make(map[string][]graphLink), known: make(map[string]bool), reached: make(map[string]bool)}
+	for {
+		row, err := reader.Read()
+		//Check if all edges were read
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		//Check if the row is malformed
+		if err != nil {
+			return nil, fmt.Errorf("error reading edge list %s: %w", path, err)
+		}
+		source, target := canonicalLink(row[0]), canonicalLink(row[1])
+		s.links[source] = append(s.links[source], graphLink{target: target, text: row[2], noFollow: row[3] == "true"})
+		s.known[source], s.known[target] = true, true
+	}
+}
+
+// RoundTrip answers a request with a page linking to the URL's recorded links; URL's without
+// recorded links get an empty page
+func (s *Simulation) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body strings.Builder
+	body.WriteString("<!DOCTYPE html>\n<html><body>\n")
+	for _, link := range s.links[canonicalLink(req.URL.String())] {
+		rel := ""
+		//Check if the link was marked as not endorsed
+		if link.noFollow {
+			rel = ` rel="nofollow"`
+		}
+		fmt.Fprintf(&body, "<a href=\"%s\"%s>%s</a>\n", html.EscapeString(link.target), rel, html.EscapeString(link.text))
+	}
+	body.WriteString("</body></html>\n")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body.String())),
+		ContentLength: int64(body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// Add records the URL of a result as reached
+func (s *Simulation) Add(result Result) {
+	s.reached[canonicalLink(result.URL)] = true
+}
+
+// simulationSection groups URL's by host and first path segment, e.g. "example.com/blog/"
+func simulationSection(link string) string {
+	u, err := url.Parse(link)
+	//Check if the URL cannot be parsed
+	if err != nil {
+		return link
+	}
+	first, _, nested := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	//Check if the URL is a page at the top level rather than in a section
+	if !nested {
+		return u.Host + "/"
+	}
+	return u.Host + "/" + first + "/"
+}
+
+// Print writes, per section, how many of the graph's URL's in the crawl's scope the simulated
+// crawl reached, sections with the most known URL's first
+func (s *Simulation) Print(w io.Writer) error {
+	known, reached := make(map[string]int), make(map[string]int)
+	totalKnown, totalReached := 0, 0
+	for link := range s.known {
+		u, err := url.Parse(link)
+		//Check if the URL lies outside the crawl's scope, which the settings could never reach
+		if err != nil || !s.crawler.hostAllowed(u) {
+			continue
+		}
+		section := simulationSection(link)
+		known[section]++
+		totalKnown++
+		//Check if the simulated crawl reached the URL
+		if s.reached[link] {
+			reached[section]++
+			totalReached++
+		}
+	}
+	sections := make([]string, 0, len(known))
+	width := len("SECTION")
+	for section := range known {
+		sections = append(sections, section)
+		width = max(width, len(section))
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		//Check if the sections have as many known URL's
+		if known[sections[i]] == known[sections[j]] {
+			return sections[i] < sections[j]
+		}
+		return known[sections[i]] > known[sections[j]]
+	})
+
+	fmt.Fprintf(w, "Simulated coverage (%d of %d known URL's reached)\n\n", totalReached, totalKnown)
+	fmt.Fprintf(w, "%-*s %7s %7s %8s\n", width, "SECTION", "KNOWN", "REACHED", "COVERAGE")
+	for _, section := range sections {
+		fmt.Fprintf(w, "%-*s %7d %7d %7.1f%%\n", width, section, known[section], reached[section],
+			100*float64(reached[section])/float64(known[section]))
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// useSimulation answers all of the crawler's requests from a recorded link graph
+func (c *Crawler) useSimulation(s *Simulation) {
+	s.crawler = c
+	c.simulated = true
+	c.client.Transport = s
+}
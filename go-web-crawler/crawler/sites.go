@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// urlHost returns the lower-case host of a URL with its port, or "" if it has none
+func urlHost(link string) string {
+	u, err := url.Parse(link)
+	//Check if the URL has no host
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// hostFailure reports whether a result shows its host misbehaving, as opposed to a missing page:
+// the request failed without a response, the server answered with a 5xx status, or a bot
+// protection challenge was served
+func hostFailure(result Result) bool {
+	return (result.Status == 0 && result.Error != "") || result.Status >= 500 || result.Blocked != ""
+}
+
+// site tracks the in-scope pages of one host
+type site struct {
+	pending     int       //Pages queued or being crawled
+	pages       int       //Pages crawled
+	failures    int       //Pages that failed with a host failure
+	consecutive int       //Host failures since the last page that did not fail
+	skipped     int       //Queued pages dropped after the host was abandoned
+	started     time.Time //When the host's first page was queued
+	abandoned   bool      //Set once the host failed too often in a row
+}
+
+// siteTracker isolates the hosts of a multi-site crawl from each other: it counts each host's
+// outstanding pages so its completion can be reported on its own, and abandons hosts that keep
+// failing so they stop taking workers and budget from the others
+type siteTracker struct {
+	mutex       sync.Mutex       //Protects sites
+	maxFailures int              //Host failures in a row after which a host is abandoned, 0 to never abandon
+	sites       map[string]*site //Sites by lower-case host
+}
+
+// newSiteTracker creates a tracker abandoning hosts after maxFailures host failures in a row
+func newSiteTracker(maxFailures int) *siteTracker {
+	return &siteTracker{maxFailures: maxFailures, sites: make(map[string]*site)}
+}
+
+// queued counts a page of the host waiting to be crawled
+func (t *siteTracker) queued(host string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s, ok := t.sites[host]
+	//Check if this is the host's first page
+	if !ok {
+		s = &site{started: time.Now()}
+		t.sites[host] = s
+	}
+	s.pending++
+}
+
+// abandoned reports whether the host was given up on
+func (t *siteTracker) abandoned(host string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s, ok := t.sites[host]
+	return ok && s.abandoned
+}
+
+// skip counts a queued page of an abandoned host that is dropped instead of crawled
+func (t *siteTracker) skip(host string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	//Check if the host is tracked
+	if s, ok := t.sites[host]; ok {
+		s.skipped++
+	}
+}
+
+// record counts a crawled page of the host and reports whether its result made the host be abandoned
+func (t *siteTracker) record(host string, result Result) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s, ok := t.sites[host]
+	//Check if the host is not tracked
+	if !ok {
+		return false
+	}
+	s.pages++
+	//Check if the page did not fail because of its host
+	if !hostFailure(result) {
+		s.consecutive = 0
+		return false
+	}
+	s.failures++
+	s.consecutive++
+	//Check if the host just reached the limit of failures in a row
+	if t.maxFailures > 0 && s.consecutive >= t.maxFailures && !s.abandoned {
+		s.abandoned = true
+		return true
+	}
+	return false
+}
+
+// finished marks a queued page of the host as done. It returns a copy of the host's counters and
+// whether no pages of the host are left, in which case the host's crawl is complete for now;
+// pages linked to it later start it again.
+func (t *siteTracker) finished(host string) (site, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s, ok := t.sites[host]
+	//Check if the host is not tracked
+	if !ok {
+		return site{}, false
+	}
+	s.pending--
+	return *s, s.pending == 0
+}
+
+// count returns the number of hosts seen so far
+func (t *siteTracker) count() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.sites)
+}
+
+// queue adds an item to the frontier, counting it against its host unless it is only checked
+func (c *Crawler) queue(item *frontierItem) {
+	//Check if the item is a page of a crawled site
+	if !item.external {
+		c.sites.queued(item.host)
+	}
+	c.frontier.push(item)
+}
+
+// finishSite marks an item as done for its host and reports the host's completion once nothing of
+// it is left. Completions are logged at normal verbosity when the crawl spans several hosts.
+func (c *Crawler) finishSite(item *frontierItem) {
+	//Check if the item was only checked
+	if item.external {
+		return
+	}
+	s, complete := c.sites.finished(item.host)
+	//Check if the host still has pages left
+	if !complete {
+		return
+	}
+	level := 1
+	//Check if other hosts are crawled too
+	if c.sites.count() > 1 {
+		level = 0
+	}
+	state := "complete"
+	//Check if the host was abandoned
+	if s.abandoned {
+		state = "abandoned"
+	}
+	c.logf(level, "site %s %s: %d pages, %d host failures, %d skipped in %s",
+		item.host, state, s.pages, s.failures, s.skipped, time.Since(s.started).Round(time.Millisecond))
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// spillRun is a sorted run of items moved to a temporary file under memory pressure. It is read
+// back one item at a time, so merging runs keeps only their current heads in memory.
+type spillRun[T any] struct {
+	file      *os.File      //Temporary file holding the run as NDJSON
+	decoder   *json.Decoder //Reads the items back in order
+	head      T             //Next item of the run
+	remaining int           //Items not yet taken from the run, including head
+}
+
+// writeSpillRun writes already sorted, non-empty items to a new temporary file and returns the
+// run positioned at its first item
+func writeSpillRun[T any](items []T) (*spillRun[T], error) {
+	file, err := os.CreateTemp("", "web-crawler-spill-*.ndjson")
+	//Check if the spill file could not be created
+	if err != nil {
+		return nil, fmt.Errorf("error creating spill file: %w", err)
+	}
+	run := &spillRun[T]{file: file, remaining: len(items)}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range items {
+		//Check if the item could not be written
+		if err := encoder.Encode(items[i]); err != nil {
+			run.close()
+			return nil, fmt.Errorf("error writing spill file: %w", err)
+		}
+	}
+	//Check if the run could not be written completely
+	if err := writer.Flush(); err != nil {
+		run.close()
+		return nil, fmt.Errorf("error writing spill file: %w", err)
+	}
+	//Check if the run could not be rewound for reading
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		run.close()
+		return nil, fmt.Errorf("error reading spill file: %w", err)
+	}
+	run.decoder = json.NewDecoder(bufio.NewReader(file))
+	//Check if the first item could not be read back
+	if err := run.decoder.Decode(&run.head); err != nil {
+		run.close()
+		return nil, fmt.Errorf("error reading spill file %s: %w", file.Name(), err)
+	}
+	return run, nil
+}
+
+// advance drops the current head and reads the next item; the run is closed once it is exhausted
+func (r *spillRun[T]) advance() error {
+	r.remaining--
+	//Check if the run is exhausted
+	if r.remaining == 0 {
+		r.close()
+		return nil
+	}
+	var next T
+	//Check if the next item could not be read
+	if err := r.decoder.Decode(&next); err != nil {
+		return fmt.Errorf("error reading spill file %s: %w", r.file.Name(), err)
+	}
+	r.head = next
+	return nil
+}
+
+// close closes and removes the run's file
+func (r *spillRun[T]) close() {
+	r.file.Close()
+	os.Remove(r.file.Name())
+}
+
+// nextSpilled returns the index of the run whose head sorts first, earlier runs winning ties,
+// or -1 if all runs are exhausted
+func nextSpilled[T any](runs []*spillRun[T], less func(a, b T) bool) int {
+	best := -1
+	for i, run := range runs {
+		//Check if the run has items left and sorts before the current best
+		if run.remaining > 0 && (best < 0 || less(run.head, runs[best].head)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// ResultBuffer collects results for sorted output. Passed to New in Options, the buffered results
+// are sorted and spilled to disk under memory pressure, and the runs are merged back by Each.
+type ResultBuffer struct {
+	mutex   sync.Mutex             //Protects results and runs, which are spilled from the memory guard
+	less    func(a, b Result) bool //Sort order of the output
+	results []Result               //Results buffered in memory
+	runs    []*spillRun[Result]    //Sorted runs spilled to disk, oldest first
+}
+
+// NewResultBuffer creates a buffer that sorts results by URL, or by depth and then URL for "depth"
+func NewResultBuffer(mode string) *ResultBuffer {
+	return &ResultBuffer{less: func(a, b Result) bool {
+		//Check if results should be grouped by discovery depth first
+		if mode == "depth" && a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		return a.URL < b.URL
+	}}
+}
+
+// Add buffers a result
+func (b *ResultBuffer) Add(result Result) {
+	b.mutex.Lock()
+	b.results = append(b.results, result)
+	b.mutex.Unlock()
+}
+
+// spill moves the buffered results to disk as one sorted run and returns how many were moved.
+// Fewer than resultSpillMin results stay in memory, so spilling never opens many tiny runs.
+func (b *ResultBuffer) spill() (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	//Check if there is too little to spill
+	if len(b.results) < resultSpillMin {
+		return 0, nil
+	}
+	b.sort()
+	run, err := writeSpillRun(b.results)
+	//Check if the results could not be spilled
+	if err != nil {
+		return 0, err
+	}
+	n := len(b.results)
+	b.runs = append(b.runs, run)
+	b.results = nil
+	return n, nil
+}
+
+// sort orders the in-memory results stably; the caller must hold the mutex
+func (b *ResultBuffer) sort() {
+	sort.SliceStable(b.results, func(i, j int) bool {
+		return b.less(b.results[i], b.results[j])
+	})
+}
+
+// Each calls fn for every buffered result in sorted order, merging spilled runs with the results
+// still in memory, and empties the buffer
+func (b *ResultBuffer) Each(fn func(Result)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sort()
+	// The in-memory results are the newest, so they lose ties against every spilled run
+	memory := b.results
+	for {
+		i := nextSpilled(b.runs, b.less)
+		//Check if the next result comes from memory
+		if i < 0 || (len(memory) > 0 && b.less(memory[0], b.runs[i].head)) {
+			//Check if all results were printed
+			if len(memory) == 0 {
+				break
+			}
+			fn(memory[0])
+			memory = memory[1:]
+			continue
+		}
+		fn(b.runs[i].head)
+		//Check if the run could not be read further
+		if err := b.runs[i].advance(); err != nil {
+			for _, run := range b.runs {
+				//Check if the run still holds a file
+				if run.remaining > 0 {
+					run.close()
+				}
+			}
+			return err
+		}
+	}
+	b.results, b.runs = nil, nil
+	return nil
+}
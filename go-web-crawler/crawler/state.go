@@ -0,0 +1,192 @@
+package crawler
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateSnapshot is the content of a state file
+type stateSnapshot struct {
+	Saved   time.Time   //When the snapshot was taken
+	Added   []walRecord //URL's queued by the crawl, in order
+	Visited []string    //URL's the crawl confirmed as visited
+}
+
+// crawlState keeps the progress of a crawl in memory and writes it to a state file every interval,
+// so an interrupted crawl can be continued with -resume. Unlike the write-ahead log, which is
+// appended to continuously, the file is replaced by a complete snapshot each time.
+type crawlState struct {
+	mutex    sync.Mutex      //Protects added, visited and changed
+	save     sync.Mutex      //Serializes checkpoints, which run outside mutex, and protects closed
+	path     string          //Path of the state file
+	interval time.Duration   //Time between checkpoints
+	added    []walRecord     //URL's queued so far, in order
+	visited  map[string]bool //URL's confirmed as visited
+	changed  bool            //Set when progress was made since the last checkpoint
+	closed   bool            //Set by Close, after which no checkpoint is written
+
+	resumed []walRecord //URL's queued by the resumed run, until recovered
+}
+
+// openCrawlState prepares a state file at path, checkpointed every interval. With resume, the
+// snapshot a previous run left behind is read and continued; without it, the file is replaced by
+// the first checkpoint.
+func openCrawlState(path string, interval time.Duration, resume bool) (*crawlState, error) {
+	s := &crawlState{path: path, interval: interval, visited: make(map[string]bool)}
+	//Check if a new crawl is started
+	if !resume {
+		return s, nil
+	}
+	file, err := os.Open(path)
+	//Check if there is no state to resume
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no crawl state to resume in %s", path)
+	}
+	//Check if the state file could not be opened
+	if err != nil {
+		return nil, fmt.Errorf("error opening state file: %w", err)
+	}
+	defer file.Close()
+	var snapshot stateSnapshot
+	//Check if the file is not a state snapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error reading state file %s: %w", path, err)
+	}
+	s.added, s.resumed = snapshot.Added, snapshot.Added
+	for _, link := range snapshot.Visited {
+		s.visited[link] = true
+	}
+	return s, nil
+}
+
+// record adds a queued URL or a visit to the progress
+func (s *crawlState) record(record walRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch record.Op {
+	case "add":
+		s.added = append(s.added, record)
+	case "visit":
+		s.visited[record.URL] = true
+	}
+	s.changed = true
+}
+
+// checkpoint writes the progress to a temporary file and renames it over the state file, so a
+// crash while writing leaves the previous snapshot intact. It returns how many URL's were queued
+// and visited.
+func (s *crawlState) checkpoint() (int, int, error) {
+	s.save.Lock()
+	defer s.save.Unlock()
+	return s.write()
+}
+
+// write takes a snapshot and writes it; the caller must hold save
+func (s *crawlState) write() (int, int, error) {
+	//Check if the crawl ended, whose final state was already handled
+	if s.closed {
+		return 0, 0, nil
+	}
+	s.mutex.Lock()
+	snapshot := stateSnapshot{Saved: time.Now(), Added: s.added[:len(s.added):len(s.added)], Visited: make([]string, 0, len(s.visited))}
+	for link := range s.visited {
+		snapshot.Visited = append(snapshot.Visited, link)
+	}
+	s.changed = false
+	s.mutex.Unlock()
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	//Check if the temporary file could not be created
+	if err != nil {
+		return 0, 0, fmt.Errorf("error writing state file: %w", err)
+	}
+	err = file.Chmod(0o644)
+	//Check if the permissions were set, so the snapshot can be encoded
+	if err == nil {
+		err = gob.NewEncoder(file).Encode(snapshot)
+	}
+	//Check if the snapshot was encoded, which still has to reach the disk
+	if err == nil {
+		err = file.Sync()
+	}
+	//Check if the file could not be closed
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	//Check if the snapshot is complete, which then replaces the previous one
+	if err == nil {
+		err = os.Rename(file.Name(), s.path)
+	}
+	//Check if the snapshot could not be written
+	if err != nil {
+		os.Remove(file.Name())
+		return 0, 0, fmt.Errorf("error writing state file: %w", err)
+	}
+	return len(snapshot.Added), len(snapshot.Visited), nil
+}
+
+// run checkpoints the progress every interval until ctx is cancelled, skipping intervals in which
+// nothing happened
+func (s *crawlState) run(ctx context.Context, logf func(level int, format string, args ...interface{})) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			changed := s.changed
+			s.mutex.Unlock()
+			//Check if there is no progress to save
+			if !changed {
+				continue
+			}
+			added, visited, err := s.checkpoint()
+			//Check if the checkpoint failed
+			if err != nil {
+				logf(-1, "%v", err)
+				continue
+			}
+			logf(2, "checkpointed crawl state: %d URL's queued, %d visited", added, visited)
+		}
+	}
+}
+
+// Close writes a final checkpoint; a finished crawl removes the state file instead, as there is
+// nothing left to resume
+func (s *crawlState) Close(finished bool) error {
+	s.save.Lock()
+	defer s.save.Unlock()
+	//Check if the crawl still has URL's to visit
+	if !finished {
+		_, _, err := s.write()
+		s.closed = true
+		return err
+	}
+	s.closed = true
+	//Check if the file could not be removed
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// recoverState restores the visited set, page counters and frontier from the state file of a
+// resumed crawl, returning false if there was nothing to resume
+func (c *Crawler) recoverState() bool {
+	//Check if no crawl is resumed
+	if c.state == nil || len(c.state.resumed) == 0 {
+		return false
+	}
+	queued := c.recoverProgress(c.state.resumed, c.state.visited)
+	c.logf(0, "resumed from state file %s: %d URL's visited, %d still queued", c.state.path, c.crawled.Load(), queued)
+	c.state.resumed = nil
+	return true
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hostCounters counts reported results for one host
+type hostCounters struct {
+	pages   int //Results reported for the host
+	success int //Results classified as success
+	warning int //Results classified as warning
+	failure int //Results classified as failure
+}
+
+// hostStats counts reported results per host for status dumps
+type hostStats struct {
+	mutex sync.Mutex               //Protects hosts
+	hosts map[string]*hostCounters //Counters by host name
+}
+
+// newHostStats creates empty per-host counters
+func newHostStats() *hostStats {
+	return &hostStats{hosts: make(map[string]*hostCounters)}
+}
+
+// record counts a result against its host
+func (s *hostStats) record(result Result) {
+	host := result.URL
+	//Check if the URL has a host to group by
+	if u, err := url.Parse(result.URL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	counters, ok := s.hosts[host]
+	//Check if this is the first result for the host
+	if !ok {
+		counters = &hostCounters{}
+		s.hosts[host] = counters
+	}
+	counters.pages++
+	switch result.Class {
+	case ClassSuccess:
+		counters.success++
+	case ClassWarning:
+		counters.warning++
+	default:
+		counters.failure++
+	}
+}
+
+// snapshot returns the host names sorted by page count, most pages first, with a copy of their counters
+func (s *hostStats) snapshot() ([]string, map[string]hostCounters) {
+	s.mutex.Lock()
+	counters := make(map[string]hostCounters, len(s.hosts))
+	hosts := make([]string, 0, len(s.hosts))
+	for host, c := range s.hosts {
+		counters[host] = *c
+		hosts = append(hosts, host)
+	}
+	s.mutex.Unlock()
+	sort.Slice(hosts, func(i, j int) bool {
+		//Check if the hosts have the same page count
+		if counters[hosts[i]].pages == counters[hosts[j]].pages {
+			return hosts[i] < hosts[j]
+		}
+		return counters[hosts[i]].pages > counters[hosts[j]].pages
+	})
+	return hosts, counters
+}
+
+// WriteStatus writes a snapshot of the crawl's progress to w: queue size, visited count, in-flight
+// fetches, per-host counts and memory usage. It can be called at any time while the crawl runs.
+func (c *Crawler) WriteStatus(w io.Writer) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	state := "running"
+	//Check if the crawl is paused
+	if c.gate.isPaused() {
+		state = "paused"
+	}
+	urls, ages := c.watchdog.inFlight()
+	hosts, counters := c.hosts.snapshot()
+
+	fmt.Fprintf(w, "=== crawl status at %s (%s) ===\n", time.Now().Format("15:04:05.000"), state)
+	fmt.Fprintf(w, "queued:    %d\n", c.frontier.len())
+	fmt.Fprintf(w, "visited:   %d\n", c.visited.len())
+	fmt.Fprintf(w, "crawled:   %d\n", c.crawled.Load())
+	fmt.Fprintf(w, "in flight: %d\n", len(urls))
+	for i, u := range urls {
+		fmt.Fprintf(w, "  %s (%s)\n", u, ages[i].Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "hosts:     %d\n", len(hosts))
+	for _, host := range hosts {
+		h := counters[host]
+		fmt.Fprintf(w, "  %s: %d pages (%d success, %d warning, %d failure)\n", host, h.pages, h.success, h.warning, h.failure)
+	}
+	_, err := fmt.Fprintf(w, "memory:    %.1f MiB heap, %.1f MiB from OS, %d goroutines\n",
+		float64(mem.HeapAlloc)/(1<<20), float64(mem.Sys)/(1<<20), runtime.NumGoroutine())
+	return err
+}
@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Result classes assigned from the HTTP status code
+const (
+	ClassSuccess = "success"
+	ClassWarning = "warning"
+	ClassFailure = "failure"
+)
+
+// StatusClasses declares which status codes count as success, warning or failure.
+// Codes are written as "404", ranges as "400-499" or "4xx". A code may be listed in only one
+// class; a path override may reassign it. Codes not listed anywhere fall back to 2xx success,
+// 3xx warning and everything else failure.
+type StatusClasses struct {
+	Success []string         `json:"success"` //Codes treated as success
+	Warning []string         `json:"warning"` //Codes treated as warnings
+	Failure []string         `json:"failure"` //Codes treated as failures
+	Paths   []StatusPathRule `json:"paths"`   //Overrides for URL's under a path prefix
+}
+
+// StatusPathRule overrides status classes for URL's under a path prefix
+type StatusPathRule struct {
+	Prefix  string   `json:"prefix"`  //Path prefix the override applies to
+	Success []string `json:"success"` //Codes treated as success under the prefix
+	Warning []string `json:"warning"` //Codes treated as warnings under the prefix
+	Failure []string `json:"failure"` //Codes treated as failures under the prefix
+}
+
+// statusRange is an inclusive range of status codes
+type statusRange struct {
+	min, max int
+}
+
+// String formats the range like the spec it came from, "404" or "400-499"
+func (r statusRange) String() string {
+	//Check if the range is a single code
+	if r.min == r.max {
+		return strconv.Itoa(r.min)
+	}
+	return fmt.Sprintf("%d-%d", r.min, r.max)
+}
+
+// statusClassSet holds parsed code ranges for each class
+type statusClassSet struct {
+	success, warning, failure []statusRange
+}
+
+// statusClassifier maps a URL path and status code to a result class
+type statusClassifier struct {
+	global statusClassSet            //Classes that apply everywhere
+	paths  []PathRule                //Path prefixes with overrides, reused for longest-prefix matching
+	byPath map[string]statusClassSet //Overrides keyed by path prefix
+}
+
+// parseStatusRanges parses code specs such as "404", "400-499" and "4xx"
+func parseStatusRanges(specs []string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, spec := range specs {
+		spec = strings.ToLower(strings.TrimSpace(spec))
+		var r statusRange
+		var err error
+		switch {
+		case len(spec) == 3 && strings.HasSuffix(spec, "xx"):
+			var hundreds int
+			hundreds, err = strconv.Atoi(spec[:1])
+			r = statusRange{hundreds * 100, hundreds*100 + 99}
+		case strings.Contains(spec, "-"):
+			bounds := strings.SplitN(spec, "-", 2)
+			r.min, err = strconv.Atoi(bounds[0])
+			//Check if the lower bound parsed before reading the upper bound
+			if err == nil {
+				r.max, err = strconv.Atoi(bounds[1])
+			}
+		default:
+			r.min, err = strconv.Atoi(spec)
+			r.max = r.min
+		}
+		//Check if the spec is malformed or the range is empty
+		if err != nil || r.min > r.max || r.min < 0 {
+			return nil, fmt.Errorf("invalid status code spec %q", spec)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// parseStatusClassSet parses the code specs of all three classes and rejects codes listed in more than one
+func parseStatusClassSet(success, warning, failure []string) (statusClassSet, error) {
+	var set statusClassSet
+	var err error
+	//Check if any of the class specs is malformed
+	if set.success, err = parseStatusRanges(success); err != nil {
+		return set, err
+	}
+	if set.warning, err = parseStatusRanges(warning); err != nil {
+		return set, err
+	}
+	if set.failure, err = parseStatusRanges(failure); err != nil {
+		return set, err
+	}
+	classes := []struct {
+		name   string
+		ranges []statusRange
+	}{{ClassSuccess, set.success}, {ClassWarning, set.warning}, {ClassFailure, set.failure}}
+	for i, a := range classes {
+		for _, b := range classes[i+1:] {
+			//Check if a code is listed in both classes, which would leave its class to the lookup order
+			if r, ok := overlappingRange(a.ranges, b.ranges); ok {
+				return set, fmt.Errorf("status %s is listed as both %s and %s", r, a.name, b.name)
+			}
+		}
+	}
+	return set, nil
+}
+
+// overlappingRange returns the codes shared by the first overlapping pair of ranges from a and b
+func overlappingRange(a, b []statusRange) (statusRange, bool) {
+	for _, ra := range a {
+		for _, rb := range b {
+			shared := statusRange{max(ra.min, rb.min), min(ra.max, rb.max)}
+			//Check if the ranges share at least one code
+			if shared.min <= shared.max {
+				return shared, true
+			}
+		}
+	}
+	return statusRange{}, false
+}
+
+// newStatusClassifier compiles status class settings; nil settings use the defaults
+func newStatusClassifier(classes *StatusClasses) (*statusClassifier, error) {
+	classifier := &statusClassifier{byPath: make(map[string]statusClassSet)}
+	//Check if only the defaults apply
+	if classes == nil {
+		return classifier, nil
+	}
+	var err error
+	//Check if the global classes are malformed
+	if classifier.global, err = parseStatusClassSet(classes.Success, classes.Warning, classes.Failure); err != nil {
+		return nil, err
+	}
+	for _, rule := range classes.Paths {
+		//Check if the override has no usable prefix
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return nil, fmt.Errorf("status class path %q must start with \"/\"", rule.Prefix)
+		}
+		//Check if the prefix already has an override, which this one would replace
+		if _, ok := classifier.byPath[rule.Prefix]; ok {
+			return nil, fmt.Errorf("status class path %q is listed more than once", rule.Prefix)
+		}
+		set, err := parseStatusClassSet(rule.Success, rule.Warning, rule.Failure)
+		//Check if the override classes are malformed
+		if err != nil {
+			return nil, fmt.Errorf("status class path %q: %w", rule.Prefix, err)
+		}
+		classifier.paths = append(classifier.paths, PathRule{Prefix: rule.Prefix})
+		classifier.byPath[rule.Prefix] = set
+	}
+	return classifier, nil
+}
+
+// lookup returns the class a set assigns to a status code, or "" if the code is not listed
+func (set statusClassSet) lookup(status int) string {
+	for _, class := range []struct {
+		name   string
+		ranges []statusRange
+	}{{ClassSuccess, set.success}, {ClassWarning, set.warning}, {ClassFailure, set.failure}} {
+		for _, r := range class.ranges {
+			//Check if the status falls into the range
+			if status >= r.min && status <= r.max {
+				return class.name
+			}
+		}
+	}
+	return ""
+}
+
+// classify returns the class of a response with the given status for a URL path; status 0 means no response
+func (sc *statusClassifier) classify(path string, status int) string {
+	//Check if no response was received at all
+	if status == 0 {
+		return ClassFailure
+	}
+	//Check if a path override lists the status
+	if rule := matchPathRule(sc.paths, path); rule != nil {
+		if class := sc.byPath[rule.Prefix].lookup(status); class != "" {
+			return class
+		}
+	}
+	//Check if the global settings list the status
+	if class := sc.global.lookup(status); class != "" {
+		return class
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return ClassSuccess
+	case status >= 300 && status < 400:
+		return ClassWarning
+	default:
+		return ClassFailure
+	}
+}
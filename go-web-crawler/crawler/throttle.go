@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// errWorkerRetired is returned by a worker that stopped because the crawl's concurrency was lowered
+var errWorkerRetired = errors.New("worker retired")
+
+// hostDelay spaces out consecutive requests to the same host by an interval that can change while
+// the crawl runs
+type hostDelay struct {
+	mutex    sync.Mutex               //Protects the fields below
+	interval time.Duration            //Least time between two requests to a host, 0 for none
+	hosts    map[string]time.Duration //Longer spacing single hosts asked for, e.g. with a robots.txt Crawl-delay, by lower-case host
+	next     map[string]time.Time     //Earliest time of the next request by lower-case host
+}
+
+// newHostDelay creates a delay spacing each host's requests by interval
+func newHostDelay(interval time.Duration) *hostDelay {
+	return &hostDelay{interval: interval, hosts: make(map[string]time.Duration), next: make(map[string]time.Time)}
+}
+
+// setHost spaces one host's requests by at least interval, even if the crawl's spacing is shorter
+func (d *hostDelay) setHost(host string, interval time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.hosts[strings.ToLower(host)] = interval
+}
+
+// get returns the current spacing
+func (d *hostDelay) get() time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.interval
+}
+
+// set changes the spacing of requests not yet scheduled
+func (d *hostDelay) set(interval time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.interval = interval
+}
+
+// wait blocks until the link's host may be requested again and reserves the following slot
+func (d *hostDelay) wait(ctx context.Context, link string) error {
+	u, err := url.Parse(link)
+	//Check if the link has no host to space out
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	host := strings.ToLower(u.Host)
+	d.mutex.Lock()
+	interval := max(d.interval, d.hosts[host])
+	//Check if the host's requests are not spaced out
+	if interval <= 0 {
+		d.mutex.Unlock()
+		return nil
+	}
+	now := time.Now()
+	at := now
+	//Check if the host's next slot is still ahead
+	if next := d.next[host]; next.After(now) {
+		at = next
+	}
+	d.next[host] = at.Add(interval)
+	d.mutex.Unlock()
+	//Check if the host may be requested right away
+	if !at.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetRate changes the number of requests per second the crawl makes, taking effect for the next
+// request; with -host-rate, it is the rate of each host. Seeds and hosts with a rate of their own
+// keep it.
+func (c *Crawler) SetRate(perSecond float64) error {
+	//Check if the rate is not positive
+	if perSecond <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	c.limiter.SetLimit(rate.Limit(perSecond))
+	//Check if hosts run at a rate of their own, which the new rate replaces
+	if c.hostLimits != nil && c.hostLimits.setDefault(rate.Limit(perSecond)) {
+		c.logf(0, "rate set to %g requests per second per host", perSecond)
+		return nil
+	}
+	c.logf(0, "rate set to %g requests per second", perSecond)
+	return nil
+}
+
+// SetHostDelay changes the least time between two requests to the same host, 0 for none
+func (c *Crawler) SetHostDelay(interval time.Duration) error {
+	//Check if the delay is negative
+	if interval < 0 {
+		return fmt.Errorf("host delay must not be negative")
+	}
+	c.hostDelay.set(interval)
+	c.logf(0, "host delay set to %s", interval)
+	return nil
+}
+
+// SetWorkers changes the number of concurrent crawl workers. Further workers start right away;
+// when lowering it, surplus workers stop after the page they are crawling.
+func (c *Crawler) SetWorkers(n int) error {
+	//Check if the crawl would have no workers left
+	if n < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	c.workerMutex.Lock()
+	defer c.workerMutex.Unlock()
+	c.workers = n
+	//Check if the crawl is running, so workers have to be started now
+	if c.workerGroup != nil {
+		//Check if all workers already stopped, after which the group must not be joined
+		if c.running == 0 {
+			return errors.New("crawl has finished")
+		}
+		for c.running < c.workers {
+			c.startWorker()
+		}
+	}
+	c.logf(0, "workers set to %d", n)
+	return nil
+}
+
+// startWorker runs another worker in the crawl's group; the caller must hold the worker mutex.
+// A worker counts as running until it returns, so while any does the group cannot have finished.
+func (c *Crawler) startWorker() {
+	c.running++
+	ctx := c.workerCtx
+	c.workerGroup.Go(func() error {
+		err := c.worker(ctx)
+		//Check if the worker retired, which already removed it from the running ones
+		if err == errWorkerRetired {
+			return nil
+		}
+		c.workerMutex.Lock()
+		c.running--
+		c.workerMutex.Unlock()
+		return err
+	})
+}
+
+// retireWorker reports whether more workers run than wanted, removing the caller from the running ones if so
+func (c *Crawler) retireWorker() bool {
+	c.workerMutex.Lock()
+	defer c.workerMutex.Unlock()
+	//Check if the concurrency was lowered below the running workers
+	if c.running > c.workers {
+		c.running--
+		return true
+	}
+	return false
+}
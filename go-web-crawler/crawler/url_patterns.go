@@ -0,0 +1,309 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// urlPattern is a configured URL template such as "/products/:id". A segment starting with ":"
+// matches any single path segment, and a trailing "*" matches the rest of the path.
+type urlPattern struct {
+	template string   //Template as configured
+	segments []string //Path segments of the template
+}
+
+// parseURLPatterns parses the configured URL templates
+func parseURLPatterns(templates []string) ([]urlPattern, error) {
+	patterns := make([]urlPattern, 0, len(templates))
+	for _, template := range templates {
+		//Check if the template is not a path
+		if !strings.HasPrefix(template, "/") {
+			return nil, fmt.Errorf("url pattern %q must start with \"/\"", template)
+		}
+		segments := strings.Split(strings.TrimPrefix(template, "/"), "/")
+		for i, segment := range segments {
+			//Check if a wildcard is followed by further segments
+			if segment == "*" && i < len(segments)-1 {
+				return nil, fmt.Errorf("url pattern %q may only end with \"*\"", template)
+			}
+		}
+		patterns = append(patterns, urlPattern{template: template, segments: segments})
+	}
+	return patterns, nil
+}
+
+// match reports whether the template matches the segments of a path
+func (p urlPattern) match(segments []string) bool {
+	for i, segment := range p.segments {
+		//Check if the rest of the path matches the wildcard
+		if segment == "*" {
+			return true
+		}
+		//Check if the path has fewer segments than the template
+		if i >= len(segments) {
+			return false
+		}
+		//Check if the segment neither is a parameter nor equals the path's
+		if !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(p.segments)
+}
+
+// templateSegments are the kinds of path segments that are collapsed into parameters when
+// templates are inferred, in the order they are tried
+var templateSegments = []struct {
+	name    string         //Parameter the segment is replaced with
+	pattern *regexp.Regexp //Matches the segment
+}{
+	{":id", regexp.MustCompile(`^\d+$`)},
+	{":uuid", regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)},
+	{":date", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)},
+	{":hash", regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)},
+}
+
+// urlTemplate returns the template of a URL: its host followed by the first configured template
+// matching its path, or by its path with identifier segments replaced by parameters. It reports
+// whether the template was configured, which keeps it out of learning.
+func urlTemplate(rawURL string, patterns []urlPattern) (string, bool) {
+	u, err := url.Parse(rawURL)
+	//Check if the URL cannot be parsed
+	if err != nil {
+		return rawURL, true
+	}
+	segments := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	for _, pattern := range patterns {
+		//Check if the configured template matches
+		if pattern.match(segments) {
+			return u.Host + pattern.template, true
+		}
+	}
+	for i, segment := range segments {
+		for _, kind := range templateSegments {
+			//Check if the segment is an identifier of this kind
+			if kind.pattern.MatchString(segment) {
+				segments[i] = kind.name
+				break
+			}
+		}
+	}
+	return u.Host + "/" + strings.Join(segments, "/"), false
+}
+
+// templateNode is a path segment in the tree of inferred templates that learning works on
+type templateNode struct {
+	children  map[string]*templateNode //Next segments by name
+	templates []string                 //Inferred templates ending at this segment
+}
+
+// add inserts the remaining segments of a template into the subtree
+func (n *templateNode) add(segments []string, template string) {
+	//Check if the template ends here
+	if len(segments) == 0 {
+		n.templates = append(n.templates, template)
+		return
+	}
+	child, ok := n.children[segments[0]]
+	//Check if the segment is new at this position
+	if !ok {
+		child = &templateNode{children: make(map[string]*templateNode)}
+		n.children[segments[0]] = child
+	}
+	child.add(segments[1:], template)
+}
+
+// merge moves the templates and children of another subtree into this one
+func (n *templateNode) merge(other *templateNode) {
+	n.templates = append(n.templates, other.templates...)
+	for name, child := range other.children {
+		//Check if the segment exists on both sides and has to be merged in turn
+		if mine, ok := n.children[name]; ok {
+			mine.merge(child)
+		} else {
+			n.children[name] = child
+		}
+	}
+}
+
+// learn collapses the literal segments under each node into a :slug parameter when there are at
+// least variants of them, since many siblings that share a parent are values rather than site
+// sections. It maps every template below the node to the learned one, given the path so far.
+func (n *templateNode) learn(prefix string, variants int, learned map[string]string) {
+	var literals []string
+	for name := range n.children {
+		//Check if the segment is a literal rather than a parameter or the empty name of a directory's index
+		if name != "" && !strings.HasPrefix(name, ":") {
+			literals = append(literals, name)
+		}
+	}
+	//Check if the literals are numerous enough to be values of a parameter
+	if variants > 0 && len(literals) >= variants {
+		slug, ok := n.children[":slug"]
+		//Check if no earlier merge created the parameter
+		if !ok {
+			slug = &templateNode{children: make(map[string]*templateNode)}
+			n.children[":slug"] = slug
+		}
+		for _, name := range literals {
+			slug.merge(n.children[name])
+			delete(n.children, name)
+		}
+	}
+	for _, template := range n.templates {
+		learned[template] = prefix
+	}
+	for name, child := range n.children {
+		child.learn(prefix+"/"+name, variants, learned)
+	}
+}
+
+// learnTemplates maps inferred templates to templates in which the path segments taking at least
+// variants values under the same parent are collapsed into :slug, per host
+func learnTemplates(templates []string, variants int) map[string]string {
+	roots := make(map[string]*templateNode)
+	for _, template := range templates {
+		host, path, _ := strings.Cut(template, "/")
+		root, ok := roots[host]
+		//Check if this is the host's first template
+		if !ok {
+			root = &templateNode{children: make(map[string]*templateNode)}
+			roots[host] = root
+		}
+		root.add(strings.Split(path, "/"), template)
+	}
+	learned := make(map[string]string, len(templates))
+	for host, root := range roots {
+		root.learn(host, variants, learned)
+	}
+	return learned
+}
+
+// patternGroup collects the results of the URL's sharing one template
+type patternGroup struct {
+	pages    int           //Results of the template
+	failures int           //Results classified as failures
+	elapsed  time.Duration //Sum of the response times of the results that got a response
+	timed    int           //Results that got a response
+}
+
+// PatternReport aggregates results by URL template, with the page count, error rate and average
+// latency of each
+type PatternReport struct {
+	patterns   []urlPattern             //Configured templates, tried before inferring one
+	variants   int                      //Values a segment needs under one parent to be learned as :slug, 0 to not learn
+	groups     map[string]*patternGroup //Groups by template before learning
+	configured map[string]bool          //Templates that came from the configuration and are not learned from
+	pages      int                      //Results added
+}
+
+// NewPatternReport creates an empty report that groups URL's by the given templates first, e.g.
+// "/products/:id", and learns :slug segments taking at least variants values; 0 does not learn
+func NewPatternReport(templates []string, variants int) (*PatternReport, error) {
+	patterns, err := parseURLPatterns(templates)
+	//Check if a template is malformed
+	if err != nil {
+		return nil, err
+	}
+	return &PatternReport{patterns: patterns, variants: variants, groups: make(map[string]*patternGroup), configured: make(map[string]bool)}, nil
+}
+
+// Add counts a result under its template; aliases of other results are not counted again
+func (r *PatternReport) Add(result Result) {
+	//Check if the result only repeats another one
+	if result.AliasOf != "" {
+		return
+	}
+	template, configured := urlTemplate(result.URL, r.patterns)
+	group, ok := r.groups[template]
+	//Check if this is the first result of the template
+	if !ok {
+		group = &patternGroup{}
+		r.groups[template] = group
+		r.configured[template] = configured
+	}
+	r.pages++
+	group.pages++
+	//Check if the result is a failure
+	if result.Class == ClassFailure {
+		group.failures++
+	}
+	//Check if a response arrived, whose time counts towards the latency
+	if result.Status != 0 {
+		group.elapsed += result.Elapsed
+		group.timed++
+	}
+}
+
+// learned returns the groups by template after learning :slug segments from the inferred ones
+func (r *PatternReport) learned() map[string]*patternGroup {
+	var inferred []string
+	for template := range r.groups {
+		//Check if the template was inferred and may be generalized
+		if !r.configured[template] {
+			inferred = append(inferred, template)
+		}
+	}
+	mapping := learnTemplates(inferred, r.variants)
+	groups := make(map[string]*patternGroup, len(r.groups))
+	for template, group := range r.groups {
+		//Check if learning generalized the template
+		if learned, ok := mapping[template]; ok {
+			template = learned
+		}
+		merged, ok := groups[template]
+		//Check if this is the first group merged into the template
+		if !ok {
+			merged = &patternGroup{}
+			groups[template] = merged
+		}
+		merged.pages += group.pages
+		merged.failures += group.failures
+		merged.elapsed += group.elapsed
+		merged.timed += group.timed
+	}
+	return groups
+}
+
+// Print writes one line per template, templates with the most pages first, with the share of
+// the crawl budget they took and the running total of those shares
+func (r *PatternReport) Print(w io.Writer) error {
+	groups := r.learned()
+	templates := make([]string, 0, len(groups))
+	width := len("PATTERN")
+	for template := range groups {
+		templates = append(templates, template)
+		width = max(width, len(template))
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		a, b := groups[templates[i]], groups[templates[j]]
+		//Check if the templates have as many pages
+		if a.pages == b.pages {
+			return templates[i] < templates[j]
+		}
+		return a.pages > b.pages
+	})
+
+	fmt.Fprintf(w, "URL pattern report (%d patterns, %d pages)\n\n", len(templates), r.pages)
+	fmt.Fprintf(w, "%-*s %7s %7s %7s %7s %7s %12s\n", width, "PATTERN", "PAGES", "SHARE", "CUMUL", "ERRORS", "RATE", "AVG LATENCY")
+	cumulative := 0
+	for _, template := range templates {
+		group := groups[template]
+		cumulative += group.pages
+		latency := "-"
+		//Check if any page of the template got a response
+		if group.timed > 0 {
+			latency = (group.elapsed / time.Duration(group.timed)).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%-*s %7d %6.1f%% %6.1f%% %7d %6.1f%% %12s\n", width, template, group.pages,
+			100*float64(group.pages)/float64(r.pages), 100*float64(cumulative)/float64(r.pages),
+			group.failures, 100*float64(group.failures)/float64(group.pages), latency)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
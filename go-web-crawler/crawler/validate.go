@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Validate checks each URL with a HEAD request, falling back to GET when HEAD is not allowed,
+// without downloading or parsing bodies. The results and errors channels are closed when done.
+func (c *Crawler) Validate(ctx context.Context, urls []string) error {
+	return c.checkAll(ctx, urls, c.checkURL)
+}
+
+// checkAll runs check once for each distinct URL on the crawler's workers and reports the results,
+// without following links. The results and errors channels are closed when done.
+func (c *Crawler) checkAll(ctx context.Context, urls []string, check func(context.Context, string) (Result, error)) error {
+	defer close(c.errors)
+	defer close(c.results)
+
+	group, ctx := errgroup.WithContext(ctx)
+	watchCtx, stopWatchdog := context.WithCancel(ctx)
+	defer stopWatchdog()
+	go c.watchdog.run(watchCtx, c.logf)
+	c.startBlackouts(watchCtx)
+	c.startMemoryGuard(watchCtx)
+	jobs := make(chan string)
+	group.Go(func() error {
+		defer close(jobs)
+		seen := make(map[string]bool)
+		for _, link := range urls {
+			//Check if the URL was already queued
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			select {
+			case jobs <- link:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	for i := 0; i < c.workers; i++ {
+		group.Go(func() error {
+			for link := range jobs {
+				result, err := check(ctx, link)
+				//Check if the URL could not be validated
+				if err != nil {
+					result.Error = err.Error()
+				}
+				c.report(ctx, result)
+				//Check if the failure should be reported as an error
+				if err != nil {
+					c.errors <- err
+				}
+			}
+			return nil
+		})
+	}
+	return group.Wait()
+}
+
+// checkURL requests a URL with HEAD, retrying with GET on 405 Method Not Allowed, and classifies the status
+func (c *Crawler) checkURL(ctx context.Context, link string) (Result, error) {
+	result := Result{URL: link, FinalURL: link, Class: ClassFailure, RequestID: c.newRequestID()}
+	var authErr error //Describes a final 401 response
+	blockedHost := "" //Host of a final bot challenge
+	for _, method := range []string{"HEAD", "GET"} {
+		//Wait for rate limiter to allow the request
+		if err := c.waitForTurn(ctx, link); err != nil {
+			return result, fmt.Errorf("rate limit error for %s: %v", link, err)
+		}
+		fetchCtx, release := c.watchdog.track(ctx, link)
+		req, err := c.newRequest(fetchCtx, method, link, result.RequestID)
+		//Check if request creation failed
+		if err != nil {
+			release()
+			return result, err
+		}
+		req, trace := traceConnection(req)
+		start := time.Now()
+		resp, err := c.do(req)
+		trace.apply(&result)
+		release()
+		//Check if HTTP request failed
+		if err != nil {
+			err = abortCause(fetchCtx, err)
+			c.logf(1, "[%s] %s %s failed after %s: %v", result.RequestID, method, link, time.Since(start).Round(time.Millisecond), err)
+			return result, fmt.Errorf("error fetching %s: %v", link, err)
+		}
+		result.Blocked = detectBotChallenge(resp)
+		blockedHost = resp.Request.URL.Host
+		resp.Body.Close() // The body is only needed to recognize bot challenges
+		authErr = nil
+		//Check if the URL still asks for authentication
+		if resp.StatusCode == http.StatusUnauthorized {
+			authErr = c.authError(link, resp)
+		}
+		result.Elapsed = time.Since(start)
+		c.logf(1, "[%s] %s %s -> %s (%s)", result.RequestID, method, link, resp.Status, result.Elapsed.Round(time.Millisecond))
+		result.Status = resp.StatusCode
+		result.Class = c.statuses.classify(req.URL.Path, resp.StatusCode)
+		result.Headers = c.captureHeaders(resp.Header)
+		result.Redirect = c.offHostRedirect(req.URL, resp)
+		result.Redirects = redirectChain(resp)
+		result.FinalURL = resp.Request.URL.String()
+		recordContent(&result, resp)
+		recordServer(&result, resp)
+		//Check if the server rejected HEAD and GET should be tried instead
+		if method == "HEAD" && resp.StatusCode == http.StatusMethodNotAllowed {
+			continue
+		}
+		break
+	}
+	//Check if bot protection answered instead of the site
+	if result.Blocked != "" {
+		c.reportBotChallenge(&result, result.Blocked, blockedHost)
+		return result, fmt.Errorf("blocked by bot protection (%s) for %s: %d %s", result.Blocked, link, result.Status, http.StatusText(result.Status))
+	}
+	//Check if the status is classified as a failure
+	if result.Class == ClassFailure {
+		//Check if the failure is a missing or rejected login
+		if authErr != nil {
+			return result, authErr
+		}
+		return result, fmt.Errorf("non-OK status for %s: %d %s", link, result.Status, http.StatusText(result.Status))
+	}
+	return result, nil
+}
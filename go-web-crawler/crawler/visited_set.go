@@ -0,0 +1,61 @@
+package crawler
+
+import "sync"
+
+// visitedShards is the number of independently locked shards in a visitedSet
+const visitedShards = 64
+
+// visitedShard is one lock-protected partition of a visitedSet
+type visitedShard struct {
+	mutex sync.Mutex          //Protects urls
+	urls  map[string]struct{} //URL's hashed into this shard
+}
+
+// visitedSet is a set of URL's sharded by hash so concurrent workers rarely contend on the same lock
+type visitedSet struct {
+	shards [visitedShards]visitedShard
+}
+
+// newVisitedSet creates an empty visitedSet
+func newVisitedSet() *visitedSet {
+	s := &visitedSet{}
+	for i := range s.shards {
+		s.shards[i].urls = make(map[string]struct{})
+	}
+	return s
+}
+
+// shard returns the shard responsible for a URL, using FNV-1a so hashing doesn't allocate
+func (s *visitedSet) shard(url string) *visitedShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(url); i++ {
+		hash ^= uint32(url[i])
+		hash *= 16777619
+	}
+	return &s.shards[hash%visitedShards]
+}
+
+// add inserts a URL and reports whether it was not already present
+func (s *visitedSet) add(url string) bool {
+	shard := s.shard(url)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	//Check if the URL is already known
+	if _, ok := shard.urls[url]; ok {
+		return false
+	}
+	shard.urls[url] = struct{}{}
+	return true
+}
+
+// len returns the number of URL's in the set
+func (s *visitedSet) len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		n += len(shard.urls)
+		shard.mutex.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// visitedWorkers is the least number of goroutines adding URL's at once in BenchmarkVisitedSet
+const visitedWorkers = 256
+
+// lockedSet is a set of URL's behind a single mutex, the baseline the sharded visitedSet replaced
+type lockedSet struct {
+	mutex sync.Mutex
+	urls  map[string]struct{}
+}
+
+// add inserts a URL and reports whether it was not already present
+func (s *lockedSet) add(url string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	//Check if the URL is already known
+	if _, ok := s.urls[url]; ok {
+		return false
+	}
+	s.urls[url] = struct{}{}
+	return true
+}
+
+// benchmarkAdd adds URL's from a shared pool with at least visitedWorkers goroutines. Every
+// goroutine walks the same pool from its own offset, so most additions hit URL's other workers
+// added already, as when many pages link to the same ones.
+func benchmarkAdd(b *testing.B, add func(string) bool) {
+	urls := make([]string, 1<<14)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://example.com/section/%d/page-%d.html", i%97, i)
+	}
+	var next atomic.Int64
+	b.SetParallelism((visitedWorkers + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1)) * 61
+		for pb.Next() {
+			add(urls[i%len(urls)])
+			i++
+		}
+	})
+}
+
+// BenchmarkVisitedSet compares the sharded visited set with a single-lock set under contention
+func BenchmarkVisitedSet(b *testing.B) {
+	b.Run("sharded", func(b *testing.B) {
+		benchmarkAdd(b, newVisitedSet().add)
+	})
+	b.Run("single-lock", func(b *testing.B) {
+		benchmarkAdd(b, (&lockedSet{urls: make(map[string]struct{})}).add)
+	})
+}
+
+// TestVisitedSetConcurrentAdd checks that each URL is reported as new exactly once when many
+// workers add the same URL's at the same time
+func TestVisitedSetConcurrentAdd(t *testing.T) {
+	set := newVisitedSet()
+	const urls = 1000
+	var added atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < visitedWorkers; w++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := 0; i < urls; i++ {
+				//Check if this worker was the first to add the URL
+				if set.add(fmt.Sprintf("https://example.com/%d", (i+offset)%urls)) {
+					added.Add(1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	//Check if some URL was reported as new more than once, or not at all
+	if added.Load() != urls || set.len() != urls {
+		t.Fatalf("added %d URL's as new and the set holds %d, want %d", added.Load(), set.len(), urls)
+	}
+}
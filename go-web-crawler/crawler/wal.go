@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// walFlushInterval bounds how much progress a killed crawl can lose
+const walFlushInterval = time.Second
+
+// walRecord is one line of the write-ahead log: a URL added to the frontier or a confirmed visit
+type walRecord struct {
+	Op       string `json:"op"`                 //"add" when queued, "visit" once fetched and reported
+	URL      string `json:"url"`                //Normalized URL
+	Depth    int    `json:"depth,omitempty"`    //Depth the URL was queued at, for "add"
+	Parent   string `json:"parent,omitempty"`   //Page the link was found on, for "add"
+	Anchor   string `json:"anchor,omitempty"`   //Anchor text of the link, for "add"
+	Frame    bool   `json:"frame,omitempty"`    //Whether the URL is a frame source, for "add"
+	Seed     string `json:"seed,omitempty"`     //Start URL the link was discovered from, for "add"
+	External bool   `json:"external,omitempty"` //Whether the URL is only checked for its status, for "add"
+}
+
+// writeAheadLog appends frontier additions and visit confirmations to a file so a crawl that is
+// killed, even without a chance to clean up, can be recovered on the next run
+type writeAheadLog struct {
+	mutex  sync.Mutex    //Serializes writes from concurrent workers
+	path   string        //Path of the log file
+	file   *os.File      //Log file opened for appending
+	writer *bufio.Writer //Buffers records between flushes
+	err    error         //First write error, reported once
+
+	added   []walRecord     //URL's queued by the previous run, in order, until recovered
+	visited map[string]bool //URL's the previous run confirmed as visited, until recovered
+}
+
+// openWAL opens the write-ahead log at path, reading what a previous run left behind. A line cut
+// off by a crash is dropped from the file before new records are appended.
+func openWAL(path string) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	//Check if the log could not be opened
+	if err != nil {
+		return nil, fmt.Errorf("error opening write-ahead log: %w", err)
+	}
+	w := &writeAheadLog{path: path, file: file, visited: make(map[string]bool)}
+	reader := bufio.NewReader(file)
+	var valid int64 // Offset just past the last complete record
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		//Check if the line is incomplete, which only happens to the last one written before a crash
+		if err == io.EOF {
+			break
+		}
+		//Check if reading the log failed
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error reading write-ahead log: %w", err)
+		}
+		valid += int64(len(data))
+		//Check if the line is blank
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var record walRecord
+		//Check if the line is not a valid record
+		if err := json.Unmarshal(data, &record); err != nil || record.URL == "" {
+			file.Close()
+			return nil, fmt.Errorf("invalid write-ahead log record on line %d of %s", line, path)
+		}
+		switch record.Op {
+		case "add":
+			w.added = append(w.added, record)
+		case "visit":
+			w.visited[record.URL] = true
+		default:
+			file.Close()
+			return nil, fmt.Errorf("unknown write-ahead log operation %q on line %d of %s", record.Op, line, path)
+		}
+	}
+	//Check if the torn record could not be cut off
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error truncating write-ahead log: %w", err)
+	}
+	//Check if the end of the log could not be found
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error seeking write-ahead log: %w", err)
+	}
+	w.writer = bufio.NewWriter(file)
+	return w, nil
+}
+
+// append buffers a record; it reaches the file with the next flush
+func (w *writeAheadLog) append(record walRecord) error {
+	data, err := json.Marshal(record)
+	//Check if the record could not be encoded
+	if err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	//Check if an earlier write already failed
+	if w.err != nil {
+		return nil
+	}
+	w.writer.Write(data)
+	//Check if the record could not be written
+	if err := w.writer.WriteByte('\n'); err != nil {
+		w.err = err
+		return fmt.Errorf("error writing write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// flush writes buffered records to the file and syncs it to disk
+func (w *writeAheadLog) flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	//Check if the buffered records could not be written
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("error writing write-ahead log: %w", err)
+	}
+	return w.file.Sync()
+}
+
+// run flushes the log every walFlushInterval until ctx is cancelled
+func (w *writeAheadLog) run(ctx context.Context, logf func(level int, format string, args ...interface{})) {
+	ticker := time.NewTicker(walFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			//Check if the log could not be flushed
+			if err := w.flush(); err != nil {
+				logf(-1, "%v", err)
+			}
+		}
+	}
+}
+
+// Close flushes and closes the log; a finished crawl removes it instead, so the next run starts fresh
+func (w *writeAheadLog) Close(finished bool) error {
+	err := w.flush()
+	//Check if the log could not be closed
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	//Check if nothing is left to recover
+	if finished && err == nil {
+		err = os.Remove(w.path)
+	}
+	return err
+}
+
+// logProgress appends a record to the write-ahead log and the crawl state, if they are kept
+func (c *Crawler) logProgress(record walRecord) {
+	//Check if the crawl state is checkpointed
+	if c.state != nil {
+		c.state.record(record)
+	}
+	//Check if the crawl keeps no write-ahead log
+	if c.wal == nil {
+		return
+	}
+	//Check if the record could not be written
+	if err := c.wal.append(record); err != nil {
+		c.logf(-1, "%v", err)
+	}
+}
+
+// recoverWAL restores the visited set, page counters and frontier from the write-ahead log of an
+// interrupted run, returning false if there was nothing to recover
+func (c *Crawler) recoverWAL() bool {
+	//Check if the previous run left no records
+	if c.wal == nil || len(c.wal.added) == 0 {
+		return false
+	}
+	queued := c.recoverProgress(c.wal.added, c.wal.visited)
+	c.logf(0, "recovered from write-ahead log %s: %d URL's visited, %d still queued", c.wal.path, c.crawled.Load(), queued)
+	c.wal.added, c.wal.visited = nil, nil
+	return true
+}
+
+// recoverProgress marks the URL's a previous run queued as visited, counts the pages it crawled
+// and queues the rest again. It returns how many URL's were queued.
+func (c *Crawler) recoverProgress(added []walRecord, visited map[string]bool) int {
+	queued := 0
+	for _, record := range added {
+		//Check if the URL was recorded twice
+		if !c.visited.add(record.URL) {
+			continue
+		}
+		//Check if the URL still has to be crawled
+		if !visited[record.URL] {
+			meta := LinkMeta{Parent: record.Parent, AnchorText: record.Anchor, Frame: record.Frame, Seed: record.Seed}
+			c.queue(c.newFrontierItem(record.URL, record.Depth, meta, record.External))
+			queued++
+			continue
+		}
+		//Check if the visit was an external check, which does not count as crawled
+		if record.External {
+			continue
+		}
+		c.crawled.Add(1)
+		//Check if the visit counts against the host's and a path rule's budget
+		if parsed, err := url.Parse(record.URL); err == nil {
+			c.hostPages[strings.ToLower(parsed.Host)]++
+			//Check if a path rule matches the page
+			if rule, key := c.pathRule(c.seeds[record.Seed], parsed.Path); rule != nil {
+				c.pathPages[key]++
+			}
+		}
+	}
+	return queued
+}
@@ -0,0 +1,253 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmCallTimeout bounds a single call into a plugin, so a looping plugin cannot hang a worker
+const wasmCallTimeout = 10 * time.Second
+
+// wasmPlugin is a sandboxed WebAssembly module implementing the crawler's plugin ABI. A plugin
+// gets WASI without any files, environment or network, and communicates only through its memory:
+//
+//	alloc(size i32) i32                 returns a buffer of size bytes for the crawler to write input into
+//	extract(url_ptr, url_len, body_ptr, body_len i32) i64
+//	                                    link extractor: parses a response body and returns a JSON
+//	                                    {"title": "", "text": "", "links": [{"url": "", "text": "", "nofollow": false}]}
+//	process(ptr, len i32) i64           content processor: receives the parsed page as JSON
+//	                                    {"url", "depth", "status", "title", "text", "links": [...]} and returns
+//	                                    a JSON object of string values attached to the result as annotations
+//
+// Results are returned as (ptr << 32 | len) of a buffer in the plugin's memory, or 0 for none.
+// A plugin only needs the exports of the roles it is used for. Instances are reused across calls
+// but never shared between concurrent ones.
+type wasmPlugin struct {
+	path      string                //Module file, for error messages
+	runtime   wazero.Runtime        //Runtime the module was compiled for
+	compiled  wazero.CompiledModule //Compiled module, instantiated on demand
+	instances chan api.Module       //Idle instances
+	config    wazero.ModuleConfig   //Configuration of new instances
+}
+
+// loadWASMPlugin compiles the module at path and checks that it exports the given functions
+func loadWASMPlugin(ctx context.Context, path string, exports ...string) (*wasmPlugin, error) {
+	code, err := os.ReadFile(path)
+	//Check if the module could not be read
+	if err != nil {
+		return nil, fmt.Errorf("error reading plugin: %w", err)
+	}
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	//Check if WASI could not be provided to the module
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error preparing plugin %s: %w", path, err)
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	//Check if the module is not valid WebAssembly
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error compiling plugin %s: %w", path, err)
+	}
+	defined := compiled.ExportedFunctions()
+	for _, name := range append([]string{"alloc"}, exports...) {
+		//Check if the module lacks a function of the ABI
+		if _, ok := defined[name]; !ok {
+			runtime.Close(ctx)
+			return nil, fmt.Errorf("plugin %s does not export %s", path, name)
+		}
+	}
+	return &wasmPlugin{
+		path:      path,
+		runtime:   runtime,
+		compiled:  compiled,
+		instances: make(chan api.Module, 64),
+		// Reactor modules initialize in _initialize; an empty name allows several instances
+		config: wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize").WithStderr(os.Stderr),
+	}, nil
+}
+
+// Close releases the plugin's instances and runtime
+func (p *wasmPlugin) Close() {
+	p.runtime.Close(context.Background())
+}
+
+// call runs an exported function with the given byte inputs copied into the plugin's memory and
+// returns the bytes of its result
+func (p *wasmPlugin) call(ctx context.Context, name string, inputs ...[]byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, wasmCallTimeout)
+	defer cancel()
+	var module api.Module
+	select {
+	case module = <-p.instances:
+	default:
+		var err error
+		//Check if a new instance could not be created
+		if module, err = p.runtime.InstantiateModule(ctx, p.compiled, p.config); err != nil {
+			return nil, fmt.Errorf("error instantiating plugin %s: %w", p.path, err)
+		}
+	}
+	out, err := p.invoke(ctx, module, name, inputs)
+	//Check if the instance may be in a broken state and has to be discarded
+	if err != nil {
+		module.Close(context.Background())
+		return nil, fmt.Errorf("plugin %s: %s: %w", p.path, name, err)
+	}
+	select {
+	case p.instances <- module:
+	default:
+		module.Close(context.Background())
+	}
+	return out, nil
+}
+
+// invoke copies the inputs into an instance's memory, calls the function and reads its result
+func (p *wasmPlugin) invoke(ctx context.Context, module api.Module, name string, inputs [][]byte) ([]byte, error) {
+	alloc, fn, memory := module.ExportedFunction("alloc"), module.ExportedFunction(name), module.Memory()
+	//Check if the instance lacks memory to exchange data through
+	if memory == nil {
+		return nil, fmt.Errorf("plugin exports no memory")
+	}
+	var params []uint64
+	for _, input := range inputs {
+		ret, err := alloc.Call(ctx, uint64(len(input)))
+		//Check if the plugin could not allocate the input buffer
+		if err != nil {
+			return nil, fmt.Errorf("alloc: %w", err)
+		}
+		ptr := uint32(ret[0])
+		//Check if the buffer lies outside the plugin's memory
+		if !memory.Write(ptr, input) {
+			return nil, fmt.Errorf("alloc returned an invalid buffer")
+		}
+		params = append(params, uint64(ptr), uint64(len(input)))
+	}
+	ret, err := fn.Call(ctx, params...)
+	//Check if the function trapped or ran out of time
+	if err != nil {
+		return nil, err
+	}
+	//Check if the function returned nothing
+	if len(ret) == 0 || ret[0] == 0 {
+		return nil, nil
+	}
+	out, ok := memory.Read(uint32(ret[0]>>32), uint32(ret[0]))
+	//Check if the result lies outside the plugin's memory
+	if !ok {
+		return nil, fmt.Errorf("returned an invalid buffer")
+	}
+	return bytes.Clone(out), nil
+}
+
+// wasmPage is the page a link extractor returns
+type wasmPage struct {
+	Title string `json:"title"` //Document title
+	Text  string `json:"text"`  //Visible text
+	Links []struct {
+		URL      string `json:"url"`      //Link target, absolute or relative to the page
+		Text     string `json:"text"`     //Anchor text
+		NoFollow bool   `json:"nofollow"` //Whether the link is marked nofollow
+	} `json:"links"` //Links found in the document
+}
+
+// contentHandler returns a ContentHandler that runs the plugin's extract function
+func (p *wasmPlugin) contentHandler() ContentHandler {
+	return func(body io.Reader, pageURL *url.URL) (*Page, error) {
+		data, truncated, err := readBufferedBody(body)
+		//Check if the body could not be read
+		if err != nil {
+			return nil, err
+		}
+		out, err := p.call(context.Background(), "extract", []byte(pageURL.String()), data)
+		//Check if the plugin failed
+		if err != nil {
+			return nil, err
+		}
+		var extracted wasmPage
+		//Check if the plugin returned a page
+		if len(out) > 0 {
+			//Check if the page is not valid JSON
+			if err := json.Unmarshal(out, &extracted); err != nil {
+				return nil, fmt.Errorf("plugin %s returned an invalid page: %w", p.path, err)
+			}
+		}
+		page := &Page{Title: extracted.Title, Text: extracted.Text, Truncated: truncated}
+		for _, link := range extracted.Links {
+			absolute, err := NormalizeURL(link.URL, pageURL)
+			//Check if the link cannot be crawled
+			if err != nil {
+				continue
+			}
+			page.Links = append(page.Links, Link{URL: absolute, Text: link.Text, NoFollow: link.NoFollow})
+		}
+		return page, nil
+	}
+}
+
+// wasmLink is a link in the page passed to a content processor
+type wasmLink struct {
+	URL      string `json:"url"`      //Absolute URL of the link target
+	Text     string `json:"text"`     //Anchor text
+	Frame    bool   `json:"frame"`    //Whether the link is a frame source
+	NoFollow bool   `json:"nofollow"` //Whether the link is marked nofollow
+}
+
+// process runs the plugin's process function for a parsed page and returns the annotations it made
+func (p *wasmPlugin) process(ctx context.Context, result Result, page *Page) (map[string]string, error) {
+	input := struct {
+		URL    string     `json:"url"`
+		Depth  int        `json:"depth"`
+		Status int        `json:"status"`
+		Title  string     `json:"title"`
+		Text   string     `json:"text"`
+		Links  []wasmLink `json:"links"`
+	}{URL: result.URL, Depth: result.Depth, Status: result.Status, Title: page.Title, Text: page.Text, Links: []wasmLink{}}
+	for _, link := range page.Links {
+		input.Links = append(input.Links, wasmLink{URL: link.URL, Text: link.Text, Frame: link.Frame, NoFollow: link.NoFollow})
+	}
+	data, err := json.Marshal(input)
+	//Check if the page could not be encoded
+	if err != nil {
+		return nil, err
+	}
+	out, err := p.call(ctx, "process", data)
+	//Check if the plugin failed or returned nothing
+	if err != nil || len(out) == 0 {
+		return nil, err
+	}
+	var annotations map[string]string
+	//Check if the plugin returned annotations that are not a JSON object of strings
+	if err := json.Unmarshal(out, &annotations); err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid annotations: %w", p.path, err)
+	}
+	return annotations, nil
+}
+
+// parseWASMExtractors parses a comma-separated list of media-type=module.wasm pairs
+func parseWASMExtractors(list string) (map[string]string, error) {
+	extractors := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		//Check if the entry is empty, e.g. from a trailing comma
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		mediaType, path, ok := strings.Cut(pair, "=")
+		//Check if the entry is not a pair
+		if !ok || strings.TrimSpace(mediaType) == "" || strings.TrimSpace(path) == "" {
+			return nil, fmt.Errorf("invalid WASM extractor %q (expected media-type=plugin.wasm)", pair)
+		}
+		extractors[strings.ToLower(strings.TrimSpace(mediaType))] = strings.TrimSpace(path)
+	}
+	return extractors, nil
+}
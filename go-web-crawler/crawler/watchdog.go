@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errWatchdogAbort is the cancellation cause of fetches aborted by the watchdog
+var errWatchdogAbort = errors.New("aborted by watchdog: request stalled")
+
+// inFlightFetch is a fetch currently being processed by a worker
+type inFlightFetch struct {
+	url       string                  //URL being fetched
+	started   time.Time               //When the fetch started
+	goroutine []byte                  //Stack header of the worker goroutine, e.g. "goroutine 42 "
+	cancel    context.CancelCauseFunc //Aborts the fetch
+	reported  bool                    //Set once the watchdog has logged the fetch
+}
+
+// watchdog tracks in-flight fetches and reports those running far longer than the request timeout
+type watchdog struct {
+	mutex   sync.Mutex                //Protects fetches and nextID
+	fetches map[uint64]*inFlightFetch //In-flight fetches by ID
+	nextID  uint64                    //ID for the next registered fetch
+	limit   time.Duration             //Age after which a fetch counts as stuck, 0 disables the watchdog
+	abort   bool                      //Whether stuck fetches are cancelled
+}
+
+// newWatchdog creates a watchdog flagging fetches older than limit; a zero limit disables it
+func newWatchdog(limit time.Duration, abort bool) *watchdog {
+	return &watchdog{fetches: make(map[uint64]*inFlightFetch), limit: limit, abort: abort}
+}
+
+// track registers a fetch and returns a context the fetch must use plus a function to call when it ends.
+// Fetches are tracked even when the watchdog is disabled so status dumps can list them.
+func (w *watchdog) track(ctx context.Context, url string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	fetch := &inFlightFetch{url: url, started: time.Now(), goroutine: goroutineHeader(), cancel: cancel}
+	w.mutex.Lock()
+	id := w.nextID
+	w.nextID++
+	w.fetches[id] = fetch
+	w.mutex.Unlock()
+	return ctx, func() {
+		w.mutex.Lock()
+		delete(w.fetches, id)
+		w.mutex.Unlock()
+		cancel(nil)
+	}
+}
+
+// inFlight returns the URL's currently being fetched and how long each has been running, oldest first
+func (w *watchdog) inFlight() ([]string, []time.Duration) {
+	w.mutex.Lock()
+	fetches := make([]*inFlightFetch, 0, len(w.fetches))
+	for _, fetch := range w.fetches {
+		fetches = append(fetches, fetch)
+	}
+	w.mutex.Unlock()
+	sort.Slice(fetches, func(i, j int) bool { return fetches[i].started.Before(fetches[j].started) })
+	urls := make([]string, len(fetches))
+	ages := make([]time.Duration, len(fetches))
+	for i, fetch := range fetches {
+		urls[i] = fetch.url
+		ages[i] = time.Since(fetch.started)
+	}
+	return urls, ages
+}
+
+// run checks for stuck fetches until ctx is cancelled, logging each one once through logf
+func (w *watchdog) run(ctx context.Context, logf func(level int, format string, args ...interface{})) {
+	//Check if the watchdog is disabled
+	if w.limit <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.limit / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var stuck []*inFlightFetch
+		w.mutex.Lock()
+		for _, fetch := range w.fetches {
+			//Check if the fetch exceeded the limit and was not reported yet
+			if !fetch.reported && time.Since(fetch.started) > w.limit {
+				fetch.reported = true
+				stuck = append(stuck, fetch)
+			}
+		}
+		w.mutex.Unlock()
+		//Check if there is anything to report
+		if len(stuck) == 0 {
+			continue
+		}
+		stacks := allStacks()
+		for _, fetch := range stuck {
+			logf(-1, "watchdog: fetch of %s running for %s (limit %s)\n%s", fetch.url,
+				time.Since(fetch.started).Round(time.Second), w.limit, goroutineStack(stacks, fetch.goroutine))
+			//Check if stuck fetches should be aborted
+			if w.abort {
+				fetch.cancel(errWatchdogAbort)
+			}
+		}
+	}
+}
+
+// abortCause returns errWatchdogAbort if the watchdog cancelled ctx, otherwise err unchanged
+func abortCause(ctx context.Context, err error) error {
+	//Check if the watchdog aborted the fetch
+	if errors.Is(context.Cause(ctx), errWatchdogAbort) {
+		return errWatchdogAbort
+	}
+	return err
+}
+
+// goroutineHeader returns the "goroutine N " prefix identifying the calling goroutine in stack dumps
+func goroutineHeader() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	//Check if the header is followed by the goroutine state
+	if i := bytes.IndexByte(buf, '['); i > 0 {
+		return buf[:i]
+	}
+	return buf
+}
+
+// allStacks returns the stack traces of all goroutines
+func allStacks() []byte {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		//Check if the dump fit into the buffer
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineStack extracts the trace of the goroutine with the given header from a full stack dump
+func goroutineStack(stacks, header []byte) string {
+	for _, trace := range bytes.Split(stacks, []byte("\n\n")) {
+		//Check if the trace belongs to the goroutine
+		if bytes.HasPrefix(trace, header) {
+			return string(trace)
+		}
+	}
+	return fmt.Sprintf("%s(stack not found)", header)
+}
@@ -0,0 +1,1585 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+)
+
+// Result describes a single crawled page
+type Result struct {
+	URL      string            //Normalized URL of the crawled page, as requested
+	FinalURL string            //URL the final response came from after following redirects; the requested URL if there were none
+	Depth    int               //Depth at which the page was discovered
+	Status   int               //HTTP status code of the response, 0 if no response was received
+	Class    string            //Classification of the status: success, warning or failure
+	Title    string            //Contents of the page's <title> element
+	Error    string            //Error that prevented fetching or parsing the page, if any
+	Headers  map[string]string //Captured response headers by lower-case name, nil unless -headers is set
+	Redirect string            //Off-host URL the page redirected to, if any; followed unless -offhost-redirects says otherwise
+
+	Redirects []RedirectHop //Redirects followed before the final response, in order; empty if there were none
+	Elapsed   time.Duration //Time from sending the request until the final response headers arrived
+	RequestID string        //ID of the fetch in log lines and the request ID header, empty if no fetch was started
+	Seed      string        //Start URL the page was discovered from
+	Blocked   string        //Bot protection that answered with a challenge instead of the page, e.g. "cloudflare"; empty if none
+
+	Annotations map[string]string //Values attached by the -hook-on-page hook, nil if none
+	AliasOf     string            //URL of the result reporting the page this URL redirected to, if another result does; the page is not reported twice
+	ContentHash string            //SHA-256 of the body, only with -change-history
+	RemoteIP    string            //IP address of the server that sent the final response, empty if no connection was made
+	TLSVersion  string            //TLS version of the final response's connection, e.g. "TLS 1.3"; empty for plain HTTP
+	ALPN        string            //Protocol negotiated through TLS ALPN, e.g. "h2"; empty if none was
+	CDN         string            //CDN or edge network whose headers the final response carries, e.g. "cloudflare"; empty if none
+	ChangeEvery time.Duration     //Mean time between content changes seen across crawls, 0 if none was seen; only with -change-history
+
+	Parent        string //Page the URL was found on, empty for seeds
+	ContentType   string //Media type of the final response, e.g. "text/html"; empty if the server declared none
+	ContentLength int64  //Size of the final response's body in bytes, from Content-Length or counted while parsing; 0 if unknown
+	Truncated     bool   //Set when the body was over -max-size, so only its first -sample-size bytes were parsed, or none
+
+	body          []byte //Response body kept for -archive, nil if it is not archived
+	bodyTruncated bool   //Whether body was cut at archiveMaxBody
+
+	retryAfter time.Duration //Wait a 429 or 503 response asked for with Retry-After, 0 if none
+}
+
+// RedirectHop is one redirect response followed while fetching a URL
+type RedirectHop struct {
+	URL    string //URL that answered with the redirect
+	Status int    //Redirect status code, e.g. 301
+	To     string //URL the redirect pointed to
+}
+
+// Crawler manages the state of the web crawl
+type Crawler struct {
+	visited    *visitedSet    //Tracks queued or visited URL's to avoid duplicates
+	mutex      sync.Mutex     //Protects the per-path and per-host page counters and the final URL's of redirects for concurrent access
+	maxDepth   int            //Maximum crawl depth
+	maxVisited int            //Maximum number of unique URL's to visit
+	crawled    atomic.Int64   //Number of URL's taken from the frontier for fetching
+	baseURL    *url.URL       //Base URL to restrict crawling to same host
+	results    chan Result    //Channel for collecting crawled pages
+	errors     chan error     //Channel for collecting errors
+	limiter    *rate.Limiter  //Rate limiter for HTTP requests
+	hostLimits *hostLimiters  //Rate limiters of hosts with rates of their own, nil if all hosts share limiter
+	client     *http.Client   //HTTP client for fetching URL's
+	verbosity  int            //Logging level: -1 quiet, 0 normal, 1 verbose, 2 very verbose
+	pathRules  []PathRule     //Per-path depth and budget overrides
+	pathPages  map[string]int //Pages visited per path rule, by prefix or by seed and prefix for a seed's own rules
+	frontier   *frontier      //Priority queue of URL's waiting to be crawled
+	workers    int            //Number of concurrent crawl workers, adjustable while the crawl runs
+	watchdog   *watchdog      //Reports and optionally aborts stalled fetches
+	gate       *pauseGate     //Holds back requests while the crawl is paused
+
+	// Worker pool
+	workerMutex sync.Mutex      //Protects workers and the pool below
+	workerGroup *errgroup.Group //Group of the running crawl's workers, nil outside Run
+	workerCtx   context.Context //Context the workers run with
+	running     int             //Workers started and not yet stopped
+	hostDelay   *hostDelay      //Spaces out consecutive requests to the same host
+
+	// Connections
+	dialer *crawlDialer //Opens the transport's connections, applying -connect-to, -bind, -ip-version and the dial timeouts
+
+	// Result classification
+	statuses *statusClassifier //Classifies response status codes, optionally per path
+
+	// Status reporting
+	hosts *hostStats //Per-host result counts
+
+	// Host scope
+	allowedHosts       map[string]int //Max depth by lower-case host crawled besides the seed host, with or without a port, "*.domain" for a domain and its subdomains or "*" for any; 0 for the crawl's
+	checkExternalLinks bool           //Whether links to other hosts are requested once for their status
+	offHostRedirects   string         //What to do when an in-scope URL redirects off-host: follow, record or error
+	pathPrefixes       []string       //Path prefixes pages other than seeds must start with, nil for any path
+	include            *regexp.Regexp //Pattern the URL's of pages other than seeds must match, nil for any URL
+	exclude            *regexp.Regexp //Pattern of URL's that are not crawled, except seeds; nil for none
+
+	// Redirect policy
+	maxRedirects      int               //Redirects followed per fetch before it fails, 0 to report 3xx responses as results
+	preserveRedirects bool              //Whether redirect hops repeat the original method and headers instead of being plain GET requests
+	finalURLs         map[string]string //URL of the result reporting each page that redirects arrived at, by final URL
+
+	// Offline verification
+	offline     *offlineMirror //Saved mirror answering all requests instead of the network, nil to crawl live
+	checkAssets bool           //Whether images, scripts, stylesheets and other resources pages load are requested once for their status
+	simulated   bool           //Whether pages are replayed from a recorded link graph by -simulate
+
+	// Fragment validation
+	fragments *fragmentCheck //Collects anchors and links to fragments for -check-fragments, nil if disabled
+
+	// Robots exclusion
+	robots *robotsCache //robots.txt rules of each host, nil to ignore robots.txt
+
+	// Path discovery
+	discovery *pathDiscovery //Hosts whose common paths were probed, nil unless -discover is set
+
+	// Alternate versions
+	followAlternates bool      //Whether rel=amphtml and alternate format links are crawled at their page's depth
+	amp              *ampCheck //Collects AMP pages and their canonical pages for -check-amp, nil if disabled
+
+	// HTTPS upgrades
+	hsts        *hstsStore //Hosts that announced Strict-Transport-Security, nil to ignore the header
+	preferHTTPS bool       //Whether every http:// URL on the default port is crawled as https://, deduplicating both spellings
+
+	// Seeds from the config file
+	seeds     map[string]*seedScope //Seeds with their own settings by URL, nil if there are none
+	seedOrder []string              //Seed URL's in the order they are queued
+	seedFile  string                //File watched for seed URL's added while the crawl runs, empty for none
+
+	// Site isolation
+	sites      *siteTracker   //Outstanding pages and failures per host, for completion reports and abandoning failing hosts
+	maxPerHost int            //Most pages visited per host, 0 for no limit
+	hostPages  map[string]int //Pages visited per lower-case host
+
+	// Scheduling
+	blackouts []BlackoutWindow //Recurring windows during which the crawl pauses
+
+	// Authentication
+	credentials map[string]*Credential //Credentials by lower-case host, with or without a port
+
+	// Content negotiation
+	userAgent      string                  //User-Agent header sent with requests, whose product token also selects the robots.txt groups
+	accept         string                  //Accept header sent with requests, empty to leave it out
+	acceptLanguage string                  //Accept-Language header sent with requests, empty to leave it out
+	negotiation    map[string]*Negotiation //Per-host header overrides by lower-case host, with or without a port
+
+	// Response header capture
+	headerNames []string //Lower-case names of response headers to record, or "*" for all
+
+	// Content handling
+	handlers   map[string]ContentHandler //Parsers by lower-case media type; other types are not parsed
+	maxSize    int64                     //Largest body downloaded in full, 0 for any size
+	sampleSize int64                     //Bytes of larger bodies fetched with a Range request and parsed, 0 to skip them
+
+	// Memory ceiling
+	maxMemory    int64               //Soft limit for the heap in bytes, 0 for none
+	spillResults func() (int, error) //Moves buffered results to disk under memory pressure, nil if none are buffered
+
+	// Bot protection
+	botThrottle *hostThrottle //Spaces out requests to hosts that served bot challenges, nil to keep their pace
+
+	// Ramp-up
+	rampUp *rampUp //Per-host rates that start low and grow while hosts answer without failures, nil to start at full speed
+
+	// Maintenance
+	maintenance *maintenance //Pauses hosts that keep answering 503 and retries their pages, nil to report 503s as failures
+
+	// Retries
+	retry *retryPolicy //Fetches URL's again after transient failures, nil to report them at once
+
+	// HTTP caching
+	httpCache *httpCache //On-disk cache in front of the transport, nil if disabled
+
+	// Change tracking
+	changes *changeHistory //Content hashes of pages across crawls, nil if not tracked
+
+	// Crash recovery
+	wal   *writeAheadLog //Records frontier additions and visits, nil if disabled
+	state *crawlState    //Checkpoints the frontier and visited set for -resume, nil if disabled
+
+	// Request correlation
+	runID           string        //Random prefix of this crawl's request ID's
+	requestSeq      atomic.Uint64 //Number of request ID's handed out
+	requestIDHeader string        //Request header carrying the request ID, empty to not send it
+
+	// Dead-letter output
+	deadLetters *deadLetterFile //Receives permanently failed URL's, nil if disabled
+
+	// Result archive
+	keepBodies bool //Whether the bodies of crawled pages are kept in their results for -archive
+
+	// Link graph export
+	edges *edgeFile  //Receives one CSV row per link found on a crawled page, nil if disabled
+	graph *linkGraph //Collects the links between pages for -report, nil if disabled
+
+	// Query parameter policies
+	queryPolicies *queryPolicies //Per-parameter dedup and crawl policies by host and path, nil if none are configured
+
+	// Page hooks
+	pageHook    []string      //Program and arguments run for every crawled page, nil for none
+	hookTimeout time.Duration //Time a page hook may run before it is killed
+	script      *crawlScript  //Lua script deciding which links to follow and what to extract, nil for none
+	processors  []*wasmPlugin //WASM content processors run for every parsed page
+	plugins     []*wasmPlugin //WASM plugins New loaded, extractors and processors, released by Close
+
+	// Focused crawling settings
+	keywords     []string //Lower-case keywords, empty to follow links from all pages
+	minRelevance float64  //Minimum keyword relevance for a page's links to be followed
+
+	// Prioritizer optionally scores discovered URL's; higher scores are crawled first.
+	// When nil, URL's are crawled in discovery order.
+	Prioritizer Prioritizer
+}
+
+// NewCrawler initializes a new Crawler with the given base URL, max depth, and max visited URL's.
+func NewCrawler(baseURL string, maxDepth int, maxVisited int) (*Crawler, error) {
+	parsedURL, err := url.Parse(baseURL) //Parse base URL
+	if err != nil {                      //Check if the URL is invalid
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	//Create HTTP client for fetching URL's
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	client := &http.Client{
+		Timeout:   10 * time.Second, //Timeout after 10 seconds
+		Transport: transport,
+	}
+	c := &Crawler{
+		visited:    newVisitedSet(),
+		pathPages:  make(map[string]int),
+		hostPages:  make(map[string]int),
+		finalURLs:  make(map[string]string),
+		hsts:       newHSTSStore(),
+		robots:     newRobotsCache(),
+		maxDepth:   maxDepth,
+		maxVisited: maxVisited,
+		baseURL:    parsedURL,
+		results:    make(chan Result, 1000),                       //Channel for collecting crawled pages
+		errors:     make(chan error, 1000),                        //Channel for collecting errors
+		limiter:    rate.NewLimiter(rate.Every(time.Second/5), 1), // 5 requests per second
+		client:     client,
+		frontier:   newFrontier(),
+		gate:       newPauseGate(),
+		hostDelay:  newHostDelay(0),
+		hosts:      newHostStats(),
+		sites:      newSiteTracker(0),
+		handlers:   defaultContentHandlers(),
+		workers:    10,
+		watchdog:   newWatchdog(3*client.Timeout, false), //Flag fetches stalled for 3x the request timeout
+		statuses:   &statusClassifier{},
+		runID:      newRunID(),
+
+		userAgent:      DefaultUserAgent,
+		accept:         DefaultAccept,
+		acceptLanguage: DefaultAcceptLanguage,
+
+		offHostRedirects:  "follow",
+		maxRedirects:      20,
+		preserveRedirects: true,
+	}
+	client.CheckRedirect = c.checkRedirect
+	c.dialer = newCrawlDialer(c.logf)
+	transport.DialContext = c.dialer.DialContext
+	return c, nil
+}
+
+// logf writes a diagnostic line to stderr when the crawler verbosity is at least level
+func (c *Crawler) logf(level int, format string, args ...interface{}) {
+	//Check if the message is too detailed for the configured verbosity
+	if c.verbosity < level {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s "+format+"\n", append([]interface{}{time.Now().Format("15:04:05.000")}, args...)...)
+}
+
+// filterReason reports which scope rule excludes a URL discovered at the given depth from the
+// given seed, or from the command-line seed if seed is nil. It returns "" if the URL is in scope.
+func (c *Crawler) filterReason(link *url.URL, depth int, seed *seedScope) string {
+	maxDepth, depthRule := c.maxDepth, "max-depth"
+	//Check if the seed has its own max depth
+	if seed != nil && seed.maxDepth > 0 {
+		maxDepth = seed.maxDepth
+	}
+	//Check if the URL's allowed host has a max depth of its own
+	if hostDepth := c.hostMaxDepth(link, seed); hostDepth > 0 {
+		maxDepth, depthRule = hostDepth, fmt.Sprintf("max-depth (host %s)", link.Host)
+	}
+	//Check if a path rule overrides the max depth for this URL
+	if rule, _ := c.pathRule(seed, link.Path); rule != nil && rule.MaxDepth > 0 {
+		maxDepth, depthRule = rule.MaxDepth, fmt.Sprintf("max-depth (path rule %s)", rule.Prefix)
+	}
+	//Check if max depth is reached
+	if depth > maxDepth {
+		return depthRule
+	}
+	//Check if the URL is on a host outside the seed's scope
+	if !c.inScope(link, seed) {
+		return "external-host"
+	}
+	//Check if pages are restricted to path prefixes, which the seeds themselves need not match
+	if len(c.pathPrefixes) > 0 && depth > 1 && !slices.ContainsFunc(c.pathPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(link.Path, prefix)
+	}) {
+		return "outside-path-prefix"
+	}
+	//Check if pages must match a pattern, which the seeds themselves need not
+	if c.include != nil && depth > 1 && !c.include.MatchString(link.String()) {
+		return "not-included"
+	}
+	//Check if the URL matches the pattern of URL's to skip, e.g. a calendar that links to every day
+	if c.exclude != nil && depth > 1 && c.exclude.MatchString(link.String()) {
+		return "excluded"
+	}
+	//Check if a local file lies outside the directory of the seed
+	if link.Scheme == "file" && c.baseURL.Scheme == "file" {
+		seedDir := c.baseURL.Path[:strings.LastIndex(c.baseURL.Path, "/")+1]
+		//Check if the file is not below the seed's directory
+		if !strings.HasPrefix(link.Path, seedDir) {
+			return "outside-directory"
+		}
+	}
+	return ""
+}
+
+// checkRedirect applies the redirect policy: it limits redirect chains to -max-redirects hops,
+// turns hops into plain GET requests unless -redirect-preserve is set, and applies the off-host
+// redirect policy when a request for an in-scope URL is redirected to a host outside the crawl's scope
+func (c *Crawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	//Check if redirects are reported as results instead of followed
+	if c.maxRedirects == 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > c.maxRedirects { //Check if redirect limit is reached
+		return fmt.Errorf("stopped after %d redirects", c.maxRedirects)
+	}
+	// Redirects to plain HTTP are upgraded like links
+	c.upgradeHTTPS(req.URL)
+	//Check if the hop should be a plain GET, like a browser following a link, instead of a copy of the original request
+	if !c.preserveRedirects {
+		header := http.Header{"User-Agent": via[0].Header.Values("User-Agent")}
+		for _, name := range []string{"Referer", c.requestIDHeader} {
+			//Check if the header is set on the hop
+			if value := req.Header.Get(name); name != "" && value != "" {
+				header.Set(name, value)
+			}
+		}
+		req.Method, req.Header = http.MethodGet, header
+	} else {
+		c.setNegotiationHeaders(req) // The new host may negotiate differently
+		setSeedHeaders(req)
+	}
+	//Check if an in-scope request is leaving the allowed hosts
+	if c.hostAllowed(via[0].URL) && !c.hostAllowed(req.URL) {
+		switch c.offHostRedirects {
+		case "record":
+			c.logf(1, "not following off-host redirect of %s to %s", via[0].URL, req.URL)
+			return http.ErrUseLastResponse
+		case "error":
+			return fmt.Errorf("off-host redirect to %s", req.URL)
+		}
+		c.logf(1, "following off-host redirect of %s to %s", via[0].URL, req.URL)
+	}
+	//Check if a crawled page's fetch is redirected to a page another result reports, which is not fetched again
+	if pageURL := redirectOwner(req.Context()); pageURL != "" {
+		//Check if the target is claimed by another result
+		if owner := c.claimRedirect(pageURL, req.URL); owner != "" {
+			return &coalescedRedirect{target: req.URL.String(), owner: owner}
+		}
+	}
+	return nil
+}
+
+// redirectChain returns the redirects that were followed to arrive at resp
+func redirectChain(resp *http.Response) []RedirectHop {
+	var hops []RedirectHop
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		hops = append(hops, RedirectHop{URL: req.Response.Request.URL.String(), Status: req.Response.StatusCode, To: req.URL.String()})
+	}
+	slices.Reverse(hops)
+	return hops
+}
+
+// offHostRedirect returns the off-host URL a response for an in-scope URL redirected to, or "" if it stayed in scope
+func (c *Crawler) offHostRedirect(original *url.URL, resp *http.Response) string {
+	//Check if the requested URL was in scope
+	if !c.hostAllowed(original) {
+		return ""
+	}
+	//Check if the redirect was recorded instead of followed
+	if location, err := resp.Location(); err == nil && resp.StatusCode >= 300 && resp.StatusCode < 400 && !c.hostAllowed(location) {
+		return location.String()
+	}
+	//Check if the redirect was followed to another host
+	if !c.hostAllowed(resp.Request.URL) {
+		return resp.Request.URL.String()
+	}
+	return ""
+}
+
+// hostAllowed reports whether a URL is on the seed host or one of the allowed hosts, or in the
+// scope of one of the config's seeds. Allowed hosts given without a port match any port.
+func (c *Crawler) hostAllowed(link *url.URL) bool {
+	//Check if the URL is in the command-line seed's scope
+	if c.inScope(link, nil) {
+		return true
+	}
+	for _, seed := range c.seeds {
+		//Check if the URL is in the seed's scope
+		if seed.hostAllowed(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAllowedHosts turns a comma-separated host list into the max depth of each lower-case host,
+// 0 for hosts that keep the crawl's. An entry host=N limits the host's pages to depth N, an entry
+// *.domain allows the domain and all its subdomains, and the entry * allows every host not listed
+// otherwise.
+func parseAllowedHosts(list string) (map[string]int, error) {
+	hosts := make(map[string]int)
+	for _, entry := range strings.Split(list, ",") {
+		host, depth, hasDepth := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		//Check if the entry is blank
+		if host == "" && !hasDepth {
+			continue
+		}
+		maxDepth := 0
+		//Check if the host has a max depth of its own
+		if hasDepth {
+			n, err := strconv.Atoi(strings.TrimSpace(depth))
+			//Check if the depth is not a positive number or the host is missing
+			if err != nil || n < 1 || host == "" {
+				return nil, fmt.Errorf("invalid allowed domain %q (expected host or host=depth with a positive depth)", strings.TrimSpace(entry))
+			}
+			maxDepth = n
+		}
+		//Check if a wildcard is used other than for every host or in front of a domain
+		if host != "*" && strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return nil, fmt.Errorf("invalid allowed domain %q (a wildcard is only allowed as * or *.domain)", strings.TrimSpace(entry))
+		}
+		hosts[host] = maxDepth
+	}
+	return hosts, nil
+}
+
+// hostKeys returns the keys a URL's host is looked up under in host lists, most specific first:
+// with its port, without it, the *.domain entries of its own and its parent domains from the
+// closest, and the wildcard
+func hostKeys(link *url.URL) []string {
+	hostname := strings.ToLower(link.Hostname())
+	keys := []string{strings.ToLower(link.Host), hostname}
+	for domain := hostname; domain != ""; {
+		keys = append(keys, "*."+domain)
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return append(keys, "*")
+}
+
+// allowedDepth looks up a URL's host among allowed hosts, returning its max depth, 0 for the
+// crawl's, and whether the host is allowed at all
+func allowedDepth(hosts map[string]int, link *url.URL) (int, bool) {
+	for _, key := range hostKeys(link) {
+		//Check if the key is listed
+		if depth, ok := hosts[key]; ok {
+			return depth, true
+		}
+	}
+	return 0, false
+}
+
+// hostMaxDepth returns the max depth that the allowed hosts of the URL's seed set for its host, 0
+// if they set none. Pages on the seed's own host always keep the seed's depth.
+func (c *Crawler) hostMaxDepth(link *url.URL, seed *seedScope) int {
+	hosts, base := c.allowedHosts, c.baseURL
+	//Check if the URL belongs to a seed with its own scope
+	if seed != nil {
+		hosts, base = seed.allowedHosts, seed.base
+	}
+	//Check if the URL is on the seed's host
+	if strings.EqualFold(link.Host, base.Host) {
+		return 0
+	}
+	depth, _ := allowedDepth(hosts, link)
+	return depth
+}
+
+// Run crawls from the base URL, if any, and the added seeds until the frontier is exhausted or ctx
+// is cancelled. Workers run in an errgroup, so a failing worker cancels the others; the results and
+// errors channels are closed only after every worker has returned. Run returns the first worker
+// failure, if any.
+func (c *Crawler) Run(ctx context.Context) error {
+	defer close(c.errors)
+	defer close(c.results)
+
+	interrupted := ctx.Err // Reports whether the caller cancelled the crawl, unlike the group's context
+	group, ctx := errgroup.WithContext(ctx)
+	stop := context.AfterFunc(ctx, c.frontier.close)
+	defer stop()
+	watchCtx, stopWatchdog := context.WithCancel(ctx)
+	defer stopWatchdog()
+	go c.watchdog.run(watchCtx, c.logf)
+	c.startBlackouts(watchCtx)
+	c.startMemoryGuard(watchCtx)
+	//Check if progress is written to a write-ahead log
+	if c.wal != nil {
+		go c.wal.run(watchCtx, c.logf)
+	}
+	//Check if progress is checkpointed to a state file
+	if c.state != nil {
+		go c.state.run(watchCtx, c.logf)
+	}
+
+	// Hold the frontier open until the seed is queued, so a filtered seed still ends the crawl
+	c.frontier.hold()
+	//Check if an interrupted crawl was recovered or resumed, which replaces the seeds
+	if !c.recoverWAL() && !c.recoverState() {
+		//Check if a seed was given on the command line
+		if seed := c.baseURL.String(); seed != "" {
+			c.enqueue(seed, 1, LinkMeta{Seed: seed})
+		}
+		for _, seed := range c.seedOrder {
+			c.enqueue(seed, 1, LinkMeta{Seed: seed})
+		}
+	}
+	c.startSeedFile(watchCtx)
+	c.frontier.done()
+
+	c.workerMutex.Lock()
+	c.workerGroup, c.workerCtx = group, ctx
+	for c.running < c.workers {
+		c.startWorker()
+	}
+	c.workerMutex.Unlock()
+	err := group.Wait()
+	//Check if the pages' content hashes are kept for the next crawl
+	if c.changes != nil {
+		//Check if the history could not be saved
+		if herr := c.changes.save(); herr != nil {
+			c.logf(-1, "%v", herr)
+		}
+	}
+	c.workerMutex.Lock()
+	c.workerGroup, c.workerCtx = nil, nil
+	c.workerMutex.Unlock()
+	//Check if links to fragments are verified, which needs every page to be crawled
+	if c.fragments != nil {
+		for _, broken := range c.fragments.broken() {
+			c.errors <- broken
+		}
+	}
+	//Check if AMP pages are verified, which also needs every page to be crawled
+	if c.amp != nil {
+		for _, broken := range c.amp.broken() {
+			c.errors <- broken
+		}
+	}
+	//Check if queued URL's spilled to disk could not be read back
+	if err == nil {
+		err = c.frontier.spillError()
+	}
+	// Workers abandon the URL's they pop after an interrupt, so only an uninterrupted crawl finished
+	finished := err == nil && interrupted() == nil && c.frontier.len() == 0
+	//Check if the write-ahead log has to be closed; it is removed once the frontier ran dry
+	if c.wal != nil {
+		//Check if the log could not be closed
+		if werr := c.wal.Close(finished); werr != nil {
+			c.logf(-1, "error closing write-ahead log: %v", werr)
+		}
+	}
+	//Check if the crawl state has to be saved a last time; it is removed once the frontier ran dry
+	if c.state != nil {
+		//Check if the state could not be saved
+		if serr := c.state.Close(finished); serr != nil {
+			c.logf(-1, "error saving crawl state: %v", serr)
+		}
+	}
+	return err
+}
+
+// Pause holds back new requests until Resume is called. Requests already sent finish normally
+// and the frontier and visited set are kept, so the crawl continues where it left off.
+func (c *Crawler) Pause() {
+	c.gate.set(true)
+	c.logf(0, "crawl paused")
+}
+
+// Resume lets requests proceed again after Pause
+func (c *Crawler) Resume() {
+	c.gate.set(false)
+	c.logf(0, "crawl resumed")
+}
+
+// Stop ends the crawl gracefully: queued URL's are abandoned, pages already taken by workers are
+// fetched and reported, and Run returns once the workers are done.
+func (c *Crawler) Stop() {
+	c.frontier.close()
+	c.gate.release()
+	c.logf(0, "crawl stopped")
+}
+
+// waitForTurn blocks until the crawl is not paused and the rate limiter allows a request for link.
+// Local files are not rate limited.
+func (c *Crawler) waitForTurn(ctx context.Context, link string) error {
+	for {
+		//Check if the crawl was cancelled while paused
+		if err := c.gate.wait(ctx); err != nil {
+			return err
+		}
+		//Check if the request reads a local file, a saved mirror or a recorded link graph, or is answered from the HTTP cache
+		if strings.HasPrefix(link, "file:") || c.offline != nil || c.simulated || c.httpCache.fresh(link) {
+			return nil
+		}
+		//Check if the crawl was cancelled while waiting for the host's delay
+		if err := c.hostDelay.wait(ctx, link); err != nil {
+			return err
+		}
+		//Check if hosts that served bot challenges are slowed down
+		if c.botThrottle != nil {
+			//Check if the crawl was cancelled while waiting for the host to allow a request
+			if err := c.botThrottle.wait(ctx, link); err != nil {
+				return err
+			}
+		}
+		//Check if new hosts start slowly
+		if c.rampUp != nil {
+			//Check if the crawl was cancelled while waiting for the host's ramp-up rate
+			if err := c.rampUp.wait(ctx, link); err != nil {
+				return err
+			}
+		}
+		limiter := c.limiter
+		//Check if the link's host has a rate limiter of its own
+		if c.hostLimits != nil {
+			//Check if the host has a limiter of its own
+			if hostLimiter := c.hostLimits.limiter(urlHost(link)); hostLimiter != nil {
+				limiter = hostLimiter
+			}
+		}
+		//Check if the page's seed has its own rate limiter
+		if seed := seedFrom(ctx); seed != nil && seed.limiter != nil {
+			limiter = seed.limiter
+		}
+		//Check if the crawl was cancelled while waiting for the rate limiter
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		//Check if hosts in maintenance are paused, last, so requests already waiting for the limiter wait for the pause too
+		if c.maintenance != nil {
+			//Check if the crawl was cancelled while waiting for the host's pause to end
+			if err := c.maintenance.wait(ctx, link); err != nil {
+				return err
+			}
+		}
+		//Check if the crawl was paused while waiting for the rate limiter
+		if !c.gate.isPaused() {
+			return nil
+		}
+	}
+}
+
+// worker crawls URL's taken from the frontier until it is closed. A panic while crawling a
+// page is returned as an error, which stops the whole crawl.
+func (c *Crawler) worker(ctx context.Context) (err error) {
+	for {
+		//Check if the concurrency was lowered and this worker is one too many
+		if c.retireWorker() {
+			return errWorkerRetired
+		}
+		item, ok := c.frontier.pop()
+		//Check if the frontier was closed
+		if !ok {
+			return nil
+		}
+		func() {
+			ctx := withSeed(ctx, c.seeds[item.meta.Seed])
+			defer c.frontier.finish(item)
+			defer c.finishSite(item)
+			defer func() {
+				//Check if crawling the page panicked
+				if r := recover(); r != nil {
+					err = fmt.Errorf("worker panic while crawling %s: %v\n%s", item.url, r, debug.Stack())
+				}
+			}()
+			//Check if the URL is an external link that is only checked
+			if item.external {
+				c.checkExternal(ctx, item.url, item.depth, item.meta)
+				return
+			}
+			c.crawl(ctx, item.url, item.depth, item.meta)
+		}()
+		//Check if the worker failed
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// enqueue adds a discovered URL to the frontier unless it is out of scope or already known, and
+// reports whether it was added
+func (c *Crawler) enqueue(link string, depth int, meta LinkMeta) bool {
+	normalizedURL, checkOnly, reason, err := c.admitLink(link, depth, meta)
+	//Check if parsing failed
+	if err != nil {
+		c.errors <- err
+		return false
+	}
+	//Check if a scope rule or query parameter policy excludes the URL
+	if reason != "" {
+		c.logf(2, "skip %s: %s", link, reason)
+		return false
+	}
+
+	// Check if already queued or max limit is reached
+	if c.crawled.Load() >= int64(c.maxVisited) || !c.visited.add(normalizedURL) {
+		c.logf(2, "skip %s: already visited or max visited reached", normalizedURL)
+		return false
+	}
+
+	c.logProgress(walRecord{Op: "add", URL: normalizedURL, Depth: depth, Parent: meta.Parent, Anchor: meta.AnchorText, Frame: meta.Frame, Seed: meta.Seed, External: checkOnly})
+	c.queue(c.newFrontierItem(normalizedURL, depth, meta, checkOnly))
+	return true
+}
+
+// admitLink normalizes a discovered URL the way the frontier keys it and applies the scope rules
+// and query parameter policies. It returns the normalized URL, whether the URL is only checked for
+// its status, and the rule excluding it, "" if it would be queued. DryRun uses it too, so its
+// preview matches the crawl.
+func (c *Crawler) admitLink(link string, depth int, meta LinkMeta) (string, bool, string, error) {
+	// Normalize URL
+	parsedURL, err := url.Parse(link)
+	//Check if parsing failed
+	if err != nil {
+		return "", false, "", fmt.Errorf("error parsing URL %s: %v", link, err)
+	}
+	//Check if the URL is crawled over HTTPS, which also deduplicates it with its https:// spelling
+	if c.upgradeHTTPS(parsedURL) {
+		c.logf(2, "upgrading %s to https", link)
+	}
+	canonicalizeURL(parsedURL)
+	seed := c.seeds[meta.Seed]
+	//Check if query parameters are subject to policies
+	if c.queryPolicies != nil {
+		c.queryPolicies.strip(parsedURL)
+	}
+	// External links are checked once regardless of depth when -check-external is set
+	external := c.checkExternalLinks && !c.inScope(parsedURL, seed)
+	// Assets are only checked too, but are subject to the scope rules like pages unless they are external
+	checkOnly := external || meta.Asset
+	normalizedURL := parsedURL.String()
+	//Check if the URL is filtered out by a scope rule
+	if reason := c.filterReason(parsedURL, depth, seed); reason != "" && !external {
+		return normalizedURL, checkOnly, reason, nil
+	}
+	//Check if the URL is on a host that was abandoned after repeated failures
+	if !external && c.sites.abandoned(strings.ToLower(parsedURL.Host)) {
+		return normalizedURL, checkOnly, "host-abandoned", nil
+	}
+	//Check if a query parameter policy excludes the URL
+	if c.queryPolicies != nil && !external {
+		//Check if another value of a "first" parameter was crawled already
+		if reason := c.queryPolicies.admit(parsedURL); reason != "" {
+			return normalizedURL, checkOnly, reason, nil
+		}
+	}
+	return normalizedURL, checkOnly, "", nil
+}
+
+// newFrontierItem creates a frontier entry scored by the prioritizer, if any
+func (c *Crawler) newFrontierItem(link string, depth int, meta LinkMeta, external bool) *frontierItem {
+	item := &frontierItem{url: link, depth: depth, meta: meta, external: external, host: urlHost(link)}
+	//Check if a prioritizer should score the URL
+	if c.Prioritizer != nil {
+		item.priority = c.Prioritizer(link, depth, meta)
+	}
+	return item
+}
+
+// Crawl fetches a single queued URL, reports it, and queues the links found on it
+func (c *Crawler) Crawl(ctx context.Context, pageURL string, depth int) {
+	c.crawl(ctx, pageURL, depth, LinkMeta{})
+}
+
+// crawl implements Crawl for a URL discovered as described by meta
+func (c *Crawler) crawl(ctx context.Context, pageURL string, depth int, meta LinkMeta) {
+	parsedURL, err := url.Parse(pageURL)
+	//Check if parsing failed
+	if err != nil {
+		c.errors <- fmt.Errorf("error parsing URL %s: %v", pageURL, err)
+		return
+	}
+
+	host := strings.ToLower(parsedURL.Host)
+	//Check if the host was abandoned after the page was queued
+	if c.sites.abandoned(host) {
+		c.sites.skip(host)
+		c.logf(2, "skip %s: host-abandoned", pageURL)
+		return
+	}
+
+	//Check if the crawl was interrupted after the page was taken from the frontier
+	if ctx.Err() != nil {
+		return
+	}
+	//Check if the host's robots.txt disallows the page
+	if !c.robotsAllowed(ctx, parsedURL) {
+		c.logf(2, "skip %s: disallowed by robots.txt", pageURL)
+		return
+	}
+	c.discoverPaths(ctx, parsedURL, meta)
+
+	// Check if max limit is reached
+	c.mutex.Lock()
+	if c.crawled.Load() >= int64(c.maxVisited) {
+		c.mutex.Unlock()
+		c.logf(2, "skip %s: max visited reached", pageURL)
+		return
+	}
+	//Check if the page budget of a matching path rule is exhausted
+	rule, ruleKey := c.pathRule(c.seeds[meta.Seed], parsedURL.Path)
+	if rule != nil && rule.MaxPages > 0 && c.pathPages[ruleKey] >= rule.MaxPages {
+		c.mutex.Unlock()
+		c.logf(2, "skip %s: max-pages (path rule %s)", pageURL, rule.Prefix)
+		return
+	}
+	//Check if the host's page budget is exhausted, which leaves the rest of max visited to other hosts
+	if c.maxPerHost > 0 && c.hostPages[host] >= c.maxPerHost {
+		c.mutex.Unlock()
+		c.logf(2, "skip %s: max-per-host", pageURL)
+		return
+	}
+	c.crawled.Add(1)
+	c.hostPages[host]++
+	//Check if the visit counts against a path rule budget
+	if rule != nil {
+		c.pathPages[ruleKey]++
+	}
+	c.mutex.Unlock()
+
+	// Fetch the page and extract its links
+	result, page, err := c.fetchWithRetries(ctx, pageURL, depth)
+	//Check if the crawl was interrupted during the fetch, whose outcome says nothing about the page
+	if ctx.Err() != nil {
+		c.logf(1, "abandoned %s: crawl interrupted", pageURL)
+		return
+	}
+	result.Seed, result.Parent = meta.Seed, meta.Parent
+	//Check if the page's anchors are kept to verify links to its fragments
+	if c.fragments != nil && page != nil {
+		c.fragments.recordAnchors(pageURL, result.FinalURL, page)
+	}
+	//Check if AMP pages and their canonical pages are verified
+	if c.amp != nil {
+		c.amp.record(pageURL, result, page)
+	}
+	//Check if fetching or parsing failed
+	if err != nil {
+		result.Error = err.Error()
+	}
+	//Check if the page failed before a response could be classified
+	if result.Class == "" {
+		result.Class = ClassFailure
+	}
+	//Check if the page got a 503 from a host that may be in maintenance, which fetches it again later
+	if c.retryMaintenance(pageURL, depth, meta, result) {
+		// The page is visited again, so this fetch does not count against the budgets
+		c.mutex.Lock()
+		c.crawled.Add(-1)
+		c.hostPages[host]--
+		//Check if the visit counted against a path rule budget
+		if rule != nil {
+			c.pathPages[ruleKey]--
+		}
+		c.mutex.Unlock()
+		return
+	}
+	//Check if the page's failure made its host be abandoned
+	if c.sites.record(host, result) {
+		c.logf(0, "abandoning %s after %d host failures in a row; its queued pages are skipped", host, c.sites.maxFailures)
+	}
+	//Check if the page redirected to a page another result reports, which is only recorded as an alias
+	if result.AliasOf != "" {
+		c.logf(1, "[%s] %s redirected to the page of %s, reporting it as an alias", result.RequestID, pageURL, result.AliasOf)
+		c.report(ctx, result)
+		c.logProgress(walRecord{Op: "visit", URL: pageURL})
+		return
+	}
+
+	var rejected map[string]bool
+	//Check if a script inspects parsed pages
+	if c.script != nil && page != nil {
+		var values map[string]string
+		var scriptErr error
+		values, rejected, scriptErr = c.script.inspectPage(ctx, result, page)
+		//Check if the script failed for this page
+		if scriptErr != nil {
+			c.logf(-1, "[%s] script %s failed for %s: %v", result.RequestID, c.script.path, pageURL, scriptErr)
+		}
+		annotate(&result, values)
+	}
+	//Check if WASM plugins process parsed pages
+	if page != nil {
+		for _, processor := range c.processors {
+			values, err := processor.process(ctx, result, page)
+			//Check if the plugin failed for this page
+			if err != nil {
+				c.logf(-1, "[%s] %v", result.RequestID, err)
+			}
+			annotate(&result, values)
+		}
+	}
+	var directives hookDirectives
+	//Check if a hook inspects every page
+	if c.pageHook != nil {
+		directives = c.runPageHook(ctx, result, page)
+		annotate(&result, directives.Annotations)
+	}
+
+	c.report(ctx, result)
+	c.logProgress(walRecord{Op: "visit", URL: pageURL})
+	c.queueHookURLs(pageURL, depth, meta, directives.AddURLs)
+	//Check if fetching or parsing failed
+	if err != nil {
+		c.errors <- err
+		return
+	}
+	//Check if the response had no content to follow links from
+	if page == nil {
+		return
+	}
+	// Links in a framed document belong to the page embedding it
+	parent := pageURL
+	//Check if the page is a frame of another page
+	if meta.Frame && meta.Parent != "" {
+		parent = meta.Parent
+	}
+	//Check if the link graph is exported
+	if c.edges != nil {
+		//Check if the page's edges could not be recorded
+		if err := c.edges.record(parent, page.Links); err != nil {
+			c.logf(-1, "%v", err)
+		}
+	}
+	//Check if the link graph is collected for a report
+	if c.graph != nil {
+		c.graph.record(parent, page.Links)
+	}
+	//Check if links to fragments are verified
+	if c.fragments != nil {
+		c.fragments.recordLinks(pageURL, page.Links)
+	}
+
+	//Check if focused crawling excludes the links of an irrelevant page; seeds are always followed
+	if len(c.keywords) > 0 && depth > 1 {
+		//Check if the page is not relevant enough to follow its links
+		if score := relevance(page.Text, c.keywords); score < c.minRelevance {
+			c.logf(1, "not following links of %s: relevance %.2f below %.2f", pageURL, score, c.minRelevance)
+			return
+		}
+	}
+
+	// Queue each link for crawling, skipping repeats within the page before they reach the shared visited set
+	queued := make(map[string]struct{}, len(page.Links))
+	for _, link := range page.Links {
+		//Check if the page already linked to this URL
+		if _, ok := queued[link.URL]; ok {
+			continue
+		}
+		queued[link.URL] = struct{}{}
+		//Check if the page hook dropped the link
+		if !directives.follows(link.URL) {
+			c.logf(2, "skip %s: dropped by page hook", link.URL)
+			continue
+		}
+		//Check if the script's shouldFollow rejected the link
+		if rejected[link.URL] {
+			c.logf(2, "skip %s: rejected by script", link.URL)
+			continue
+		}
+		//Check if the link is a frame, which is part of this page and so stays at its depth
+		if link.Frame {
+			c.enqueue(link.URL, depth, LinkMeta{Parent: parent, Frame: true, Seed: meta.Seed})
+			continue
+		}
+		c.enqueue(link.URL, depth+1, LinkMeta{Parent: parent, AnchorText: link.Text, Seed: meta.Seed})
+	}
+	//Check if the resources the page loads are checked, which are part of the page and so stay at its depth
+	if c.checkAssets {
+		for _, asset := range page.Assets {
+			//Check if the page already linked to or loaded this URL
+			if _, ok := queued[asset]; ok {
+				continue
+			}
+			queued[asset] = struct{}{}
+			c.enqueue(asset, depth, LinkMeta{Parent: parent, Seed: meta.Seed, Asset: true})
+		}
+	}
+	var alternates []string
+	//Check if alternate versions of the page are crawled, which are the same page and so stay at its depth
+	if c.followAlternates {
+		alternates = page.Alternates
+	}
+	//Check if the page's AMP version is crawled, which the AMP check needs too
+	if page.AMPURL != "" && (c.followAlternates || c.amp != nil) {
+		alternates = append([]string{page.AMPURL}, alternates...)
+	}
+	for _, alternate := range alternates {
+		//Check if the page already linked to or loaded this URL
+		if _, ok := queued[alternate]; ok {
+			continue
+		}
+		queued[alternate] = struct{}{}
+		c.enqueue(alternate, depth, LinkMeta{Parent: parent, Seed: meta.Seed})
+	}
+}
+
+// checkExternal requests an off-site link once to report its status; its body is never parsed
+func (c *Crawler) checkExternal(ctx context.Context, link string, depth int, meta LinkMeta) {
+	result, err := c.checkURL(ctx, link)
+	//Check if the crawl was interrupted during the check
+	if ctx.Err() != nil {
+		c.logf(1, "abandoned %s: crawl interrupted", link)
+		return
+	}
+	result.Depth, result.Seed, result.Parent = depth, meta.Seed, meta.Parent
+	//Check if the link could not be checked
+	if err != nil {
+		result.Error = err.Error()
+	}
+	c.report(ctx, result)
+	c.logProgress(walRecord{Op: "visit", URL: link})
+	//Check if the failure should be reported as an error
+	if err != nil {
+		c.errors <- err
+	}
+}
+
+// report sends a crawled page to the results channel. The send blocks while the channel is full,
+// so a slow consumer applies backpressure to the workers instead of losing results. Cancelling ctx
+// interrupts the script's onResult.
+func (c *Crawler) report(ctx context.Context, result Result) {
+	c.hosts.record(result)
+	c.recordRampUp(result)
+	//Check if the script handles results
+	if c.script != nil {
+		//Check if the script failed for this result
+		if err := c.script.reportResult(ctx, result); err != nil {
+			c.logf(-1, "script %s failed for %s: %v", c.script.path, result.URL, err)
+		}
+	}
+	//Check if failed URL's are written to a dead-letter file
+	if c.deadLetters != nil && result.Class == ClassFailure {
+		//Check if the failure could not be recorded
+		if err := c.deadLetters.record(result); err != nil {
+			c.logf(-1, "error writing dead letter for %s: %v", result.URL, err)
+		}
+	}
+	c.results <- result
+}
+
+// newRequest creates a request with the crawler's standard headers for the fetch with the given ID
+func (c *Crawler) newRequest(ctx context.Context, method, pageURL, requestID string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, pageURL, nil)
+	//Check if request creation failed
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %v", pageURL, err)
+	}
+	//Check if the host announced Strict-Transport-Security after the URL was queued
+	if c.upgradeHTTPS(req.URL) {
+		c.logf(2, "[%s] requesting %s over https", requestID, pageURL)
+	}
+	//Set headers for fetching URL's
+	req.Header.Set("User-Agent", c.userAgent)
+	c.setNegotiationHeaders(req)
+	setSeedHeaders(req)
+	//Check if there is a base URL to send as referer
+	if c.baseURL.String() != "" {
+		req.Header.Set("Referer", c.baseURL.String())
+	}
+	//Check if the request ID is sent to the server, so its logs can be matched with the crawl's
+	if c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, requestID)
+	}
+	//Check if the host's OAuth2 token could not be obtained
+	if err := c.authorizePreemptive(req); err != nil {
+		return nil, fmt.Errorf("error authorizing request for %s: %v", pageURL, err)
+	}
+	return req, nil
+}
+
+// fetchPage downloads a page, subject to the rate limiter, and returns its result and extracted content
+func (c *Crawler) fetchPage(ctx context.Context, pageURL string, depth int) (Result, *Page, error) {
+	result := Result{URL: pageURL, FinalURL: pageURL, Depth: depth, RequestID: c.newRequestID()}
+
+	//Wait for rate limiter to allow the request
+	waitStart := time.Now()
+	if err := c.waitForTurn(ctx, pageURL); err != nil {
+		return result, nil, fmt.Errorf("rate limit error for %s: %v", pageURL, err)
+	}
+	c.logf(2, "[%s] rate limiter delayed %s by %s", result.RequestID, pageURL, time.Since(waitStart).Round(time.Millisecond))
+	ctx, release := c.watchdog.track(ctx, pageURL)
+	defer release()
+
+	// Fetch the page
+	req, err := c.newRequest(ctx, "GET", pageURL, result.RequestID)
+	//Check if request creation failed
+	if err != nil {
+		return result, nil, err
+	}
+	req, trace := traceConnection(req)
+	fetchStart := time.Now()
+	resp, err := c.do(req)
+	trace.apply(&result)
+	var coalesced *coalescedRedirect
+	//Check if the fetch stopped at a redirect to a page another result reports, whose response is returned closed
+	if errors.As(err, &coalesced) && resp != nil {
+		result.Status = resp.StatusCode
+		result.Class = c.statuses.classify(req.URL.Path, resp.StatusCode)
+		result.Redirects = append(redirectChain(resp), RedirectHop{URL: resp.Request.URL.String(), Status: resp.StatusCode, To: coalesced.target})
+		result.FinalURL, result.AliasOf = coalesced.target, coalesced.owner
+		result.Elapsed = time.Since(fetchStart)
+		c.logf(1, "[%s] GET %s -> %s, not following it to %s (%s, depth %d)", result.RequestID, pageURL, resp.Status, coalesced.target, result.Elapsed.Round(time.Millisecond), depth)
+		return result, nil, nil
+	}
+	//Check if HTTP request failed
+	if err != nil {
+		err = abortCause(ctx, err)
+		c.logf(1, "[%s] GET %s failed after %s: %v", result.RequestID, pageURL, time.Since(fetchStart).Round(time.Millisecond), err)
+		return result, nil, fmt.Errorf("error fetching %s: %v", pageURL, err)
+	}
+	defer resp.Body.Close()
+	result.Status = resp.StatusCode
+	result.Class = c.statuses.classify(req.URL.Path, resp.StatusCode)
+	result.Headers = c.captureHeaders(resp.Header)
+	result.retryAfter = parseRetryAfter(resp)
+	result.Redirect = c.offHostRedirect(req.URL, resp)
+	result.Redirects = redirectChain(resp)
+	result.FinalURL = resp.Request.URL.String()
+	recordServer(&result, resp)
+	recordContent(&result, resp)
+	result.Elapsed = time.Since(fetchStart)
+	c.logf(1, "[%s] GET %s -> %s (%s, depth %d)", result.RequestID, pageURL, resp.Status, result.Elapsed.Round(time.Millisecond), depth)
+	//Check if bot protection answered instead of the site
+	if protection := detectBotChallenge(resp); protection != "" {
+		c.reportBotChallenge(&result, protection, resp.Request.URL.Host)
+		return result, nil, fmt.Errorf("blocked by bot protection (%s) for %s: %s", protection, pageURL, resp.Status)
+	}
+
+	//Check if the HTTP response status is not OK (200)
+	if resp.StatusCode != http.StatusOK {
+		//Check if the status is classified as a failure
+		if result.Class == ClassFailure {
+			//Check if the failure is a missing or rejected login
+			if resp.StatusCode == http.StatusUnauthorized {
+				return result, nil, c.authError(pageURL, resp)
+			}
+			return result, nil, fmt.Errorf("non-OK status for %s: %s", pageURL, resp.Status)
+		}
+		//Check if the response is a redirect that was not followed, whose target is queued like a link instead
+		if location, err := resp.Location(); err == nil && c.maxRedirects == 0 && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			//Check if the target is a valid link
+			if link, err := NormalizeURL(location.String(), resp.Request.URL); err == nil && link != "" {
+				return result, &Page{Links: []Link{{URL: link}}}, nil
+			}
+		}
+		return result, nil, nil // Expected status without links to follow
+	}
+
+	// Parse the body with the handler for its content type, resolving links against the final URL
+	mediaType := responseMediaType(resp)
+	handler, ok := c.handlers[mediaType]
+	var reader io.Reader = resp.Body
+	var limited *sizeLimitedReader
+	//Check if the body is over the size limit, of which only a sample is parsed, if any
+	if c.maxSize > 0 && resp.ContentLength > c.maxSize {
+		result.Truncated = true
+		//Check if large bodies are skipped, or this one would not be parsed anyway
+		if c.sampleSize == 0 || !ok {
+			c.logf(1, "[%s] not downloading %s: %d bytes is over -max-size", result.RequestID, pageURL, resp.ContentLength)
+			return result, nil, nil
+		}
+		resp.Body.Close()
+		sample, err := c.fetchSample(ctx, result.FinalURL, result.RequestID)
+		//Check if the sample could not be fetched
+		if err != nil {
+			return result, nil, err
+		}
+		defer sample.Close()
+		c.logf(1, "[%s] parsing the first %d of %d bytes of %s", result.RequestID, c.sampleSize, resp.ContentLength, pageURL)
+		reader = sample
+	} else if c.maxSize > 0 && resp.ContentLength < 0 {
+		// A body of unknown size is cut at the limit
+		limited = &sizeLimitedReader{r: resp.Body, left: c.maxSize}
+		reader = limited
+	}
+	counter := &countingReader{r: reader}
+	body, recordHash := c.hashedBody(counter)
+	body, keepBody := c.keptBody(body)
+	//Check if responses of this type are not parsed
+	if !ok {
+		c.logf(2, "[%s] not parsing %s: no handler for %s", result.RequestID, pageURL, mediaType)
+		keepBody(&result)
+		recordHash(&result)
+		return result, nil, nil
+	}
+	page, err := handler(body, resp.Request.URL)
+	//Check if parsing failed
+	if err != nil {
+		return result, nil, fmt.Errorf("error parsing %s: %v", pageURL, abortCause(ctx, err))
+	}
+	keepBody(&result)
+	recordHash(&result)
+	result.Title = page.Title
+	//Check if a body of unknown size went on past the size limit
+	if limited != nil && limited.exceeded {
+		c.logf(1, "[%s] parsed only the first %d bytes of %s: its body is over -max-size", result.RequestID, c.maxSize, pageURL)
+		result.Truncated = true
+	}
+	//Check if the handler parsed only the start of a body too large to hold in memory
+	if page.Truncated {
+		c.logf(1, "[%s] parsed only the first %d bytes of %s: its body is too large to hold in memory", result.RequestID, maxBufferedBody, pageURL)
+		result.Truncated = true
+	}
+	//Check if the server did not send the body's size, which the parser has read through
+	if result.ContentLength == 0 && !result.Truncated {
+		result.ContentLength = counter.n
+	}
+	return result, page, nil
+}
+
+// Link is a hyperlink extracted from a page
+type Link struct {
+	URL      string //Absolute URL of the link target
+	Text     string //Whitespace-normalized anchor text
+	Frame    bool   //Set for <frame> and <iframe> sources, whose documents are part of the linking page
+	NoFollow bool   //Set for anchors with rel="nofollow"
+	Position string //Landmark the link is in, one of the Position constants; empty outside of them
+}
+
+// Page holds the data extracted from a fetched document by its ContentHandler
+type Page struct {
+	Links      []Link          //Valid links found in the document
+	Assets     []string        //Absolute URL's of the images, scripts, stylesheets and other resources the document loads
+	Anchors    map[string]bool //Element ids and anchor names that fragments can point at, nil if the handler does not collect them
+	Canonical  string          //Absolute URL of the rel=canonical link, empty if there is none
+	AMPURL     string          //Absolute URL of the rel=amphtml link to the document's AMP version, empty if there is none
+	Alternates []string        //Absolute URL's of rel=alternate links to the document in other formats, e.g. feeds or PDFs
+	AMP        bool            //Whether the document is an AMP page, marked with <html amp> or <html ⚡>
+	Title      string          //Whitespace-normalized document title, e.g. the contents of the HTML <title> element
+	Text       string          //Whitespace-normalized visible text, excluding scripts and styles
+	Truncated  bool            //Set when the handler parsed only the start of the body, e.g. one over maxBufferedBody
+}
+
+// textBufferPool reuses the byte buffers that accumulate page and anchor text while parsing.
+// The html.Tokenizer itself cannot be reset for a new reader, so it is created per page.
+var textBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// maxPooledTextBuffer caps the size of buffers returned to the pool so one huge page doesn't pin memory
+const maxPooledTextBuffer = 1 << 20
+
+// getTextBuffer takes an empty text buffer from the pool
+func getTextBuffer() *[]byte {
+	buf := textBufferPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putTextBuffer returns a text buffer to the pool unless it grew too large
+func putTextBuffer(buf *[]byte) {
+	//Check if the buffer is small enough to keep around
+	if cap(*buf) <= maxPooledTextBuffer {
+		textBufferPool.Put(buf)
+	}
+}
+
+// appendCollapsed appends text to dst, collapsing each run of whitespace into a single space
+func appendCollapsed(dst, text []byte) []byte {
+	for _, ch := range text {
+		switch ch {
+		case ' ', '\t', '\n', '\r', '\f', '\v':
+			//Check if a separating space is needed
+			if len(dst) > 0 && dst[len(dst)-1] != ' ' {
+				dst = append(dst, ' ')
+			}
+		default:
+			dst = append(dst, ch)
+		}
+	}
+	return dst
+}
+
+// collapsedString converts a buffer filled by appendCollapsed into a string without the trailing space
+func collapsedString(buf []byte) string {
+	//Check if the buffer ends with a separating space
+	if len(buf) > 0 && buf[len(buf)-1] == ' ' {
+		buf = buf[:len(buf)-1]
+	}
+	return string(buf)
+}
+
+// ExtractLinks parses HTML and returns valid links along with the visible page text.
+// Tags and attributes are scanned as byte slices so only kept hrefs and text are converted to strings.
+func ExtractLinks(body io.Reader, baseURL *url.URL) (*Page, error) {
+	page := &Page{Anchors: make(map[string]bool)}
+	textBuf, anchorBuf := getTextBuffer(), getTextBuffer()
+	defer putTextBuffer(textBuf)
+	defer putTextBuffer(anchorBuf)
+	inAnchor := false   //Set while inside an anchor whose link was kept
+	skipText := false   //Set while inside a script or style element
+	inTitle := false    //Set while inside the title element
+	inNoscript := false //Set while inside a noscript element, whose content the tokenizer returns as raw text
+	var regions regionStack
+	tokenizer := html.NewTokenizer(body)
+
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			//Check if the tokenizer reached the end of the input
+			if tokenizer.Err() == io.EOF {
+				page.Text = collapsedString(*textBuf)
+				return page, nil
+			}
+			return nil, fmt.Errorf("error parsing HTML: %w", tokenizer.Err())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style":
+				//The element content is not visible text
+				skipText = tt == html.StartTagToken
+				appendAssets(page, tokenizer, string(name), hasAttr, baseURL)
+			case "img", "source", "video", "audio", "track", "embed", "link":
+				appendAssets(page, tokenizer, string(name), hasAttr, baseURL)
+			case "html":
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "id":
+						page.Anchors[string(val)] = true
+					case "amp", "⚡":
+						page.AMP = true
+					}
+				}
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "noscript":
+				inNoscript = tt == html.StartTagToken
+			case "frame", "iframe":
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					//Check if the attribute names the element
+					if string(key) == "id" {
+						page.Anchors[string(val)] = true
+					}
+					//Check if the attribute is the framed document
+					if string(key) == "src" {
+						link, err := NormalizeURL(string(val), baseURL)
+						//Check if the URL normalization succeeded and the link is non-empty
+						if err == nil && link != "" {
+							page.Links = append(page.Links, Link{URL: link, Frame: true, Position: regions.position()})
+						}
+					}
+				}
+			case "a":
+				inAnchor = false
+				kept, noFollow := false, false
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "href":
+						link, err := NormalizeURL(string(val), baseURL)
+						//Check if the URL normalization succeeded and the link is non-empty
+						if err == nil && link != "" {
+							page.Links = append(page.Links, Link{URL: link, Position: regions.position()})
+							*anchorBuf = (*anchorBuf)[:0]
+							inAnchor = tt == html.StartTagToken
+							kept = true
+						}
+					case "id", "name":
+						page.Anchors[string(val)] = true
+					case "rel":
+						for _, rel := range strings.Fields(string(val)) {
+							//Check if the link is marked as not endorsed
+							if strings.EqualFold(rel, "nofollow") {
+								noFollow = true
+							}
+						}
+					}
+				}
+				//Check if the kept link carries rel="nofollow"
+				if kept && noFollow {
+					page.Links[len(page.Links)-1].NoFollow = true
+				}
+			default:
+				role := ""
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "id":
+						page.Anchors[string(val)] = true
+					case "role":
+						role = strings.ToLower(strings.TrimSpace(string(val)))
+					}
+				}
+				//Check if the element has content, which may make it a landmark
+				if tt == html.StartTagToken {
+					regions.open(string(name), role)
+				}
+			}
+		case html.TextToken:
+			//Check if the text is inside a script or style element
+			if skipText {
+				continue
+			}
+			raw := tokenizer.Text()
+			//Check if the text is the markup of a noscript element, which a crawler without scripting sees as part of the page
+			if inNoscript {
+				fallback, err := ExtractLinks(bytes.NewReader(raw), baseURL)
+				//Check if the fallback markup could be parsed
+				if err == nil {
+					for i := range fallback.Links {
+						//Check if the fallback link is not in a landmark of its own, which leaves the noscript element's
+						if fallback.Links[i].Position == "" {
+							fallback.Links[i].Position = regions.position()
+						}
+					}
+					page.Links = append(page.Links, fallback.Links...)
+					page.Assets = append(page.Assets, fallback.Assets...)
+					for anchor := range fallback.Anchors {
+						page.Anchors[anchor] = true
+					}
+					raw = []byte(fallback.Text)
+				}
+			}
+			//Check if the text is the document title
+			if inTitle && page.Title == "" {
+				page.Title = collapsedString(appendCollapsed(nil, raw))
+			}
+			*textBuf = appendCollapsed(*textBuf, raw)
+			//Check if the text needs a separator from the next text token
+			if len(*textBuf) > 0 && (*textBuf)[len(*textBuf)-1] != ' ' {
+				*textBuf = append(*textBuf, ' ')
+			}
+			//Check if the text belongs to the current anchor
+			if inAnchor {
+				*anchorBuf = appendCollapsed(*anchorBuf, raw)
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style":
+				skipText = false
+			case "title":
+				inTitle = false
+			case "noscript":
+				inNoscript = false
+			case "a":
+				//Check if the current anchor is closed
+				if inAnchor {
+					page.Links[len(page.Links)-1].Text = collapsedString(*anchorBuf)
+					inAnchor = false
+				}
+			default:
+				regions.close(string(name))
+			}
+		}
+	}
+}
+
+// appendAssets reads the attributes of an element and appends the URL's of the resources it
+// loads to the page's assets: the src of images, media, embeds and scripts, a video's poster, and
+// the href of stylesheets, icons and manifests. The element's id is added to the page's anchors,
+// and links to other versions of the document, rel=canonical, amphtml and alternate formats, are
+// recorded in the page.
+func appendAssets(page *Page, tokenizer *html.Tokenizer, name string, hasAttr bool, baseURL *url.URL) {
+	var refs, relations []string
+	loaded := name != "link" //Whether the element loads what it references; for link elements this depends on rel
+	mediaType := ""          //Media type a link declares for its target
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = tokenizer.TagAttr()
+		switch string(key) {
+		case "id":
+			page.Anchors[string(val)] = true
+		case "src", "poster":
+			refs = append(refs, string(val))
+		case "href":
+			//Check if the element is a link, the only one whose href names a resource it loads
+			if name == "link" {
+				refs = append(refs, string(val))
+			}
+		case "type":
+			mediaType = strings.TrimSpace(string(val))
+		case "rel":
+			for _, rel := range strings.Fields(strings.ToLower(string(val))) {
+				switch rel {
+				case "stylesheet", "icon", "apple-touch-icon", "manifest":
+					//The link loads a resource rather than pointing at a related page
+					loaded = true
+				case "canonical", "amphtml", "alternate":
+					relations = append(relations, rel)
+				}
+			}
+		}
+	}
+	//Check if the link points at another version of the document
+	if name == "link" && !loaded && len(relations) > 0 && len(refs) > 0 {
+		addRelations(page, relations, mediaType, refs[0], baseURL)
+	}
+	//Check if the element only points at a resource without loading it
+	if !loaded {
+		return
+	}
+	for _, ref := range refs {
+		link, err := NormalizeURL(ref, baseURL)
+		//Check if the URL normalization succeeded and the link is non-empty
+		if err == nil && link != "" {
+			page.Assets = append(page.Assets, link)
+		}
+	}
+}
+
+// addRelations records a link to another version of a document by its relations. The first
+// canonical and AMP links count; alternates are only kept if they declare a media type, which
+// tells other formats from the language versions of hreflang links.
+func addRelations(page *Page, relations []string, mediaType, ref string, baseURL *url.URL) {
+	link, err := NormalizeURL(ref, baseURL)
+	//Check if the URL normalization failed or the link is empty
+	if err != nil || link == "" {
+		return
+	}
+	for _, rel := range relations {
+		switch {
+		case rel == "canonical" && page.Canonical == "":
+			page.Canonical = link
+		case rel == "amphtml" && page.AMPURL == "":
+			page.AMPURL = link
+		case rel == "alternate" && mediaType != "":
+			page.Alternates = append(page.Alternates, link)
+		}
+	}
+}
+
+// NormalizeURL converts relative URLs to absolute and validates
+func NormalizeURL(link string, baseURL *url.URL) (string, error) {
+	//Parse the input link
+	parsedLink, err := url.Parse(link)
+	//Check if the link parsing failed
+	if err != nil {
+		return "", err
+	}
+	absoluteURL := baseURL.ResolveReference(parsedLink)
+	//Check if the URL scheme is HTTP or HTTPS, or a local file linked from a local file
+	if absoluteURL.Scheme != "http" && absoluteURL.Scheme != "https" && !(absoluteURL.Scheme == "file" && baseURL.Scheme == "file") {
+		return "", nil // Skip non-HTTP(S) links
+	}
+	return absoluteURL.String(), nil
+}
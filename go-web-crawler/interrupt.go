@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptContext returns a context that is cancelled by the first SIGINT or SIGTERM, which
+// cancels the requests in flight and ends the crawl with the results so far. The signals are
+// released once the context is cancelled, so a second one kills the process as usual.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, func() {
+		stop()
+		fmt.Fprintln(os.Stderr, "\nInterrupted: cancelling requests and printing the results so far; interrupt again to quit immediately")
+	})
+	return ctx
+}
@@ -0,0 +1,745 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-web-crawler/go-web-crawler/crawler"
+)
+
+// main parses command-line arguments and coordinates the web crawling process
+func main() {
+	format := flag.String("format", "text", "output format: \"text\" (one URL per line, with the final URL after redirects), \"template\", \"json\" or \"jsonl\" (one JSON object per line, see -schema) or \"tree\" (URL's grouped by path once the crawl ends)")
+	pageHook := flag.String("hook-on-page", "", "program run for every crawled page with its result as JSON on stdin; it may print directives as JSON to skip or add links and annotate the result")
+	hookTimeout := flag.Duration("hook-timeout", 10*time.Second, "time a -hook-on-page program may run per page before it is killed")
+	scriptPath := flag.String("script", "", "Lua script defining shouldFollow(url, page), extract(page) and/or onResult(result) to customize the crawl")
+	wasmExtractors := flag.String("wasm-extractor", "", "comma-separated media-type=plugin.wasm pairs; responses of the type are parsed for links by the WASM plugin")
+	wasmProcessors := flag.String("wasm-processor", "", "comma-separated WASM plugins that process every parsed page and annotate its result")
+	printSchema := flag.Bool("schema", false, "print the JSON Schema of -format json and -dlq output and exit")
+	historyDir := flag.String("history", "", "directory keeping a summary of every run, for -history-list and -history-compare")
+	historyKeep := flag.Int("history-keep", 0, "runs kept in -history; older ones are removed after each run (0 keeps all)")
+	historyDays := flag.Int("history-days", 0, "days runs are kept in -history; older ones are removed after each run (0 keeps them forever)")
+	historyList := flag.Bool("history-list", false, "list the runs in -history and exit")
+	historyCompare := flag.String("history-compare", "", "compare two runs in -history, given as 'id1,id2' or \"last\" for the latest two, and exit")
+	tmpl := flag.String("template", "", "Go template applied to each result with -format template, e.g. '{{.URL}} {{.Status}} {{.Title}}'")
+	filterExpr := flag.String("filter", "", "expression selecting which results to print, e.g. 'status >= 400 && elapsed > 500ms'; durations compare in milliseconds; empty prints all")
+	sortMode := flag.String("sort", "", "buffer results and print them sorted by \"url\" or \"depth\"")
+	quiet := flag.Bool("q", false, "quiet: print only errors and a final summary")
+	verbose := flag.Bool("v", false, "verbose: log every request with its status and timing")
+	veryVerbose := flag.Bool("vv", false, "very verbose: also log skipped URLs and rate limiter delays")
+	configPath := flag.String("config", "", "path to a JSON config file with crawl settings and profiles")
+	profile := flag.String("profile", "", "name of a profile from the config file to apply")
+	prioritize := flag.String("prioritize", "", "comma-separated substrings; URL's containing them are crawled first")
+	keywords := flag.String("keywords", "", "comma-separated keywords; only follow links from pages relevant to them")
+	minRelevance := flag.Float64("min-relevance", 0.5, "fraction of -keywords a page must contain for its links to be followed")
+	workers := flag.Int("workers", 10, "number of concurrent crawl workers")
+	watchdogFactor := flag.Float64("watchdog", 3, "report fetches running longer than this many request timeouts (0 disables)")
+	watchdogAbort := flag.Bool("watchdog-abort", false, "abort fetches reported by the watchdog")
+	validateList := flag.String("validate", "", "check the URL's listed in this file (\"-\" for stdin) with HEAD requests instead of crawling")
+	controlSocket := flag.String("control-socket", "", "path of a Unix socket accepting JSON commands to query, pause, resume, stop, re-rate or add seeds to the running crawl")
+	statusFile := flag.String("status-file", "", "write status dumps requested with SIGQUIT to this file instead of stderr")
+	userAgent := flag.String("user-agent", crawler.DefaultUserAgent, "User-Agent header sent with requests; its product token, e.g. \"MyBot\" of \"MyBot/1.0\", selects the robots.txt rules")
+	maxCrawlDelay := flag.Duration("max-crawl-delay", 30*time.Second, "longest robots.txt Crawl-delay honored; hosts asking for more are reported and get this delay (0 for no limit)")
+	ignoreRobots := flag.Bool("ignore-robots", false, "crawl pages that robots.txt disallows and ignore its Crawl-delay")
+	accept := flag.String("accept", crawler.DefaultAccept, "Accept header sent with requests; empty to leave it out")
+	acceptLanguage := flag.String("accept-language", crawler.DefaultAcceptLanguage, "Accept-Language header sent with requests; empty to leave it out")
+	requestIDHeader := flag.String("request-id-header", "", "send each fetch's request ID in this request header, e.g. 'X-Request-ID'")
+	headers := flag.String("headers", "", "comma-separated response headers to record per URL, e.g. 'cache-control,x-cache', or '*' for all")
+	allowedDomains := flag.String("allowed-domains", "", "comma-separated hosts that may be crawled besides the seed host, each optionally with its own max depth, *.domain for a domain and its subdomains and * for any other host, e.g. 'docs.example.com=10,*.status.example.com,*=2'")
+	discover := flag.Bool("discover", false, "probe /sitemap.xml, /sitemap_index.xml, /feed and /archive on every crawled host and queue those that exist")
+	include := flag.String("include", "", "regular expression, e.g. '/blog/'; pages other than the seeds are only crawled if their URL matches it")
+	exclude := flag.String("exclude", "", "regular expression, e.g. '/calendar/|[?&]sort='; URL's matching it are not crawled")
+	pathPrefixes := flag.String("path-prefix", "", "comma-separated path prefixes, e.g. '/docs/,/blog/'; pages other than the seeds are only crawled under them")
+	httpCacheDir := flag.String("http-cache", "", "directory of an on-disk HTTP cache that serves fresh responses and revalidates stale ones across crawls")
+	hostConcurrency := flag.Int("host-concurrency", 0, "most pages of one host fetched at once, so a slow host cannot occupy every worker (0 for no limit)")
+	maxPerHost := flag.Int("max-per-host", 0, "most pages visited per host, so one large site cannot use up max_visited in a multi-site crawl (0 for no limit)")
+	hostMaxFailures := flag.Int("host-max-failures", 0, "abandon a host after this many failed requests, 5xx responses or bot challenges in a row (0 never abandons)")
+	seedFile := flag.String("seed-file", "", "file watched while the crawl runs; every URL appended to it, one per line, is queued as a seed")
+	hsts := flag.Bool("hsts", true, "remember hosts that send Strict-Transport-Security over HTTPS and request their http:// URL's over HTTPS")
+	preferHTTPS := flag.Bool("prefer-https", false, "crawl every http:// URL on the default port as https://, so both spellings of a page are crawled once")
+	changeHistory := flag.String("change-history", "", "JSON file tracking page content hashes across crawls; frequently changing pages are crawled first and results get their estimated change interval")
+	hostRate := flag.Float64("host-rate", 0, "requests per second per host, each host getting a rate limiter of its own instead of all hosts sharing 5 per second (0 shares one limiter)")
+	hostRates := flag.String("host-rates", "", "comma-separated host=rate pairs overriding the requests per second of single hosts, e.g. 'example.com=10,*.cdn.example.com=1'")
+	hostDelay := flag.Duration("host-delay", 0, "least time between two requests to the same host (0 for none)")
+	rampUp := flag.Float64("ramp-up", 0, "requests per second each new host starts at, doubled after every 5 responses in a row without a host failure until the crawl's rate is reached, and halved after a failure (0 starts hosts at full speed)")
+	maintenancePause := flag.Duration("maintenance-pause", 0, "after 3 responses in a row with 503 Service Unavailable, pause the host this long, longer with each further pause, and fetch its 503 pages again (0 reports them as failures)")
+	retries := flag.Int("retries", 0, "times a URL is fetched again after a timeout, dropped connection or 429, 502, 503 or 504 response (0 reports the failure at once)")
+	retryBackoff := flag.Duration("retry-backoff", time.Second, "wait before the first retry, doubled for each further one with random jitter; a Retry-After header on 429 and 503 responses replaces it")
+	retryMaxWait := flag.Duration("retry-max-wait", time.Minute, "longest wait before a retry, also when Retry-After asks for more")
+	botSlowdown := flag.Duration("bot-slowdown", 0, "after a host serves a bot challenge, space its requests this far apart, doubling with each further challenge (0 disables)")
+	mapHost := flag.String("map-host", "", "comma-separated host=substitute pairs; requests for host go to substitute while output keeps the original URL's")
+	connectTo := flag.String("connect-to", "", "comma-separated curl-style HOST1:PORT1:HOST2:PORT2 rules; connections to HOST1:PORT1 go to HOST2:PORT2 while Host and TLS SNI keep the URL's host")
+	bind := flag.String("bind", "", "local IP address or network interface to make connections from, e.g. 10.1.2.3 or eth1")
+	ipVersion := flag.String("ip-version", "auto", "address family of connections: 4, 6 or auto for both")
+	dialTimeout := flag.Duration("dial-timeout", 0, "time each IP address of a host gets to accept a connection before the next one is tried (0 shares 30s between them)")
+	fallbackDelay := flag.Duration("dial-fallback-delay", 300*time.Millisecond, "time the first address family of a dual-stack host gets before the other one is tried in parallel (Happy Eyeballs); negative tries it only after the first failed")
+	mapHostHeader := flag.Bool("map-host-header", false, "send the original host in the Host header of requests rerouted by -map-host")
+	checkExternal := flag.Bool("check-external", false, "request links to other hosts once to report their status, without following their links")
+	checkAssets := flag.Bool("check-assets", false, "request the images, scripts, stylesheets and other resources of crawled pages once to report their status")
+	offline := flag.String("offline", "", "crawl a saved mirror instead of the network: a directory with a subdirectory per host, as saved by wget --mirror, or a WARC file; also checks assets")
+	checkFragments := flag.Bool("check-fragments", false, "report links to a fragment, e.g. /page#section, whose crawled target has no element with that id or anchor name")
+	followAlternates := flag.Bool("follow-alternates", false, "crawl the AMP version (rel=amphtml) and alternate formats (rel=alternate with a type) of pages, at their page's depth")
+	checkAMP := flag.Bool("check-amp", false, "report AMP pages without a rel=canonical link, and canonical and AMP pages that do not link to each other; crawls rel=amphtml links")
+	simulatePath := flag.String("simulate", "", "replay the link graph written by -edges in an earlier crawl instead of fetching pages, and print the share of its URL's the crawl's depth, budget and scope settings reach per section")
+	maxRedirects := flag.Int("max-redirects", 20, "redirects to follow per URL before it fails; 0 reports 3xx responses as results and queues their targets")
+	redirectPreserve := flag.Bool("redirect-preserve", true, "repeat the original request's method and headers on redirect hops; when false, hops are plain GET requests")
+	offHostRedirects := flag.String("offhost-redirects", "follow", "when an in-scope URL redirects to another host: \"follow\" it, \"record\" the redirect without following it, or treat it as an \"error\"")
+	reportPath := flag.String("report", "", "write a crawl report to this .html or .md file, or \"html\" or \"md\" to print it to stdout after the results")
+	patternReportPath := flag.String("pattern-report", "", "write the page count, error rate and average latency per URL template, e.g. /products/:id, to this file (\"-\" for stdout)")
+	patternVariants := flag.Int("pattern-variants", 10, "values a path segment must take under the same parent for -pattern-report to learn it as :slug (0 disables learning)")
+	cacheReportPath := flag.String("cache-report", "", "write a report of pages with missing, conflicting or short-lived caching to this file (\"-\" for stdout)")
+	cacheMinTTL := flag.Duration("cache-min-ttl", 5*time.Minute, "TTLs below this are reported as short by -cache-report")
+	maxSize := flag.String("max-size", "", "largest body downloaded, e.g. '10MB'; larger ones are reported as truncated without being parsed")
+	sampleSize := flag.String("sample-size", "", "with -max-size, fetch the first bytes of larger bodies, e.g. '64KB', with a Range request and parse them for metadata and early links")
+	maxMemory := flag.String("max-memory", "", "soft memory ceiling, e.g. '1GB'; near it, queued URL's and sorted results are spilled to disk")
+	walPath := flag.String("wal", "", "record crawl progress in this write-ahead log and resume from it after a crash")
+	statePath := flag.String("state-file", "", "checkpoint the queue and visited set to this file, so the crawl can be continued with -resume")
+	stateInterval := flag.Duration("state-interval", 30*time.Second, "time between -state-file checkpoints")
+	resume := flag.Bool("resume", false, "continue the crawl saved in -state-file instead of starting over")
+	archivePath := flag.String("archive", "", "write results and page bodies to this zstd-compressed tar archive, indexed by its index.jsonl member")
+	edgesPath := flag.String("edges", "", "write the link graph to this CSV file as source,target,anchor_text,nofollow rows")
+	dlqPath := flag.String("dlq", "", "write URL's that failed to this NDJSON dead-letter file")
+	dryRun := flag.Bool("dry-run", false, "fetch only the seeds, list which of their links are in scope or filtered, and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: web_crawler [flags] <url|directory> [max_depth] [max_visited]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       web_crawler [flags] retry-dlq <dead_letter_file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	//Check if only the output schema was requested
+	if *printSchema {
+		os.Stdout.Write(crawler.OutputSchema)
+		return
+	}
+	var history *runHistory
+	//Check if runs are recorded or looked up
+	if *historyDir != "" {
+		//Check if the retention is negative
+		if *historyKeep < 0 || *historyDays < 0 {
+			fmt.Fprintln(os.Stderr, "Error: -history-keep and -history-days must not be negative")
+			os.Exit(1)
+		}
+		var err error
+		//Check if the history directory could not be created
+		if history, err = openRunHistory(*historyDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if past runs are listed or compared instead of crawling
+	if *historyList || *historyCompare != "" {
+		//Check if there is no history to read
+		if history == nil {
+			fmt.Fprintln(os.Stderr, "Error: -history-list and -history-compare require -history")
+			os.Exit(1)
+		}
+		runs, err := history.load()
+		//Check if the runs could be read and printed
+		if err == nil && *historyList {
+			err = writeRunList(os.Stdout, runs)
+		} else if err == nil {
+			var a, b runSummary
+			//Check if both runs were found
+			if a, b, err = compareRuns(runs, *historyCompare); err == nil {
+				err = writeRunComparison(os.Stdout, a, b)
+			}
+		}
+		//Check if the history could not be read or printed
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	//Check if the minimum required arguments are provided; a config file may provide the seeds instead
+	if len(args) < 1 && *validateList == "" && *configPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	retryFile := ""
+	//Check if dead-lettered URL's should be retried instead of crawling
+	if len(args) > 0 && args[0] == "retry-dlq" {
+		//Check if the dead-letter file is missing
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		retryFile, args = args[1], nil
+	}
+	cfg := &crawler.Config{}
+	//Check if a config file was provided
+	if *configPath != "" {
+		loaded, err := crawler.LoadConfig(*configPath)
+		//Check if the config file is invalid
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		//Check if the selected profile is not defined
+		if cfg, err = loaded.Profile(*profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		//Check if the config sets flags that do not exist or have invalid values
+		if err := cfg.ApplyFlags(flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *profile != "" {
+		fmt.Fprintln(os.Stderr, "Error: -profile requires -config")
+		os.Exit(1)
+	}
+	//Check if there is nothing to crawl
+	if len(args) < 1 && *validateList == "" && len(cfg.Seeds) == 0 && retryFile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	//Check if the sort mode is supported
+	if *sortMode != "" && *sortMode != "url" && *sortMode != "depth" {
+		fmt.Fprintf(os.Stderr, "Error: invalid sort mode %q (expected \"url\" or \"depth\")\n", *sortMode)
+		os.Exit(1)
+	}
+
+	var tree *siteTree
+	var printResult func(crawler.Result)
+	var err error
+	//Check if results are collected into a tree printed once the crawl ends
+	if *format == "tree" {
+		tree = newSiteTree()
+		printResult = tree.add
+	} else if printResult, err = newResultPrinter(os.Stdout, *format, *tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter, err := crawler.ParseFilter(*filterExpr)
+	//Check if the filter expression is invalid
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	startURL := ""
+	//Check if a seed URL was provided
+	if len(args) > 0 {
+		startURL = args[0]
+	}
+	opts := crawler.Options{MaxDepth: 2, MaxPages: 100, Config: cfg}
+	//Check if the config overrides the default depth
+	if cfg.MaxDepth > 0 {
+		opts.MaxDepth = cfg.MaxDepth
+	}
+	//Check if the config overrides the default max visited URL's
+	if cfg.MaxVisited > 0 {
+		opts.MaxPages = cfg.MaxVisited
+	}
+	//Check if max depth is provided
+	if len(args) > 1 {
+		//Check if the max depth argument is a valid non-negative integer
+		if d, err := strconv.Atoi(args[1]); err == nil && d >= 0 {
+			opts.MaxDepth = d
+		}
+	}
+	//Check if max visited is provided
+	if len(args) > 2 {
+		//Check if the max visited argument is a valid positive integer
+		if v, err := strconv.Atoi(args[2]); err == nil && v > 0 {
+			opts.MaxPages = v
+		}
+	}
+
+	//Check if a negative value was given for a count or duration
+	for _, check := range []struct {
+		negative bool
+		flags    string
+	}{
+		{*dialTimeout < 0, "-dial-timeout"},
+		{*maxCrawlDelay < 0, "-max-crawl-delay"},
+		{*hostConcurrency < 0 || *hostMaxFailures < 0 || *maxPerHost < 0, "-host-concurrency, -host-max-failures and -max-per-host"},
+		{*hostDelay < 0, "-host-delay"},
+		{*retries < 0, "-retries"},
+		{*hostRate < 0, "-host-rate"},
+		{*rampUp < 0, "-ramp-up"},
+		{*maxRedirects < 0, "-max-redirects"},
+		{*patternVariants < 0, "-pattern-variants"},
+	} {
+		//Check if the flags hold a negative value
+		if check.negative {
+			fmt.Fprintf(os.Stderr, "Error: %s must not be negative\n", check.flags)
+			os.Exit(1)
+		}
+	}
+	//Check if the worker count is usable
+	if *workers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -workers must be at least 1")
+		os.Exit(1)
+	}
+	//Check if transient failures are retried with unusable waits
+	if *retries > 0 && (*retryBackoff <= 0 || *retryMaxWait <= 0) {
+		fmt.Fprintln(os.Stderr, "Error: -retry-backoff and -retry-max-wait must be positive")
+		os.Exit(1)
+	}
+	opts.ConnectTo, opts.IPVersion, opts.Bind = splitList(*connectTo), *ipVersion, *bind
+	opts.DialTimeout, opts.DialFallbackDelay = *dialTimeout, *fallbackDelay
+	opts.MapHosts, opts.MapHostHeader = splitList(*mapHost), *mapHostHeader
+	opts.HTTPCache, opts.Offline = *httpCacheDir, *offline
+	//Check if pages are replayed from a recorded link graph
+	if *simulatePath != "" {
+		//Check if the graph could not be loaded
+		if opts.Simulation, err = crawler.LoadSimulation(*simulatePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts.UserAgent, opts.Accept, opts.AcceptLanguage = *userAgent, *accept, *acceptLanguage
+	//Check if the Accept header is left out
+	if *accept == "" {
+		opts.Accept = "-"
+	}
+	//Check if the Accept-Language header is left out
+	if *acceptLanguage == "" {
+		opts.AcceptLanguage = "-"
+	}
+	opts.IgnoreRobots, opts.MaxCrawlDelay = *ignoreRobots, *maxCrawlDelay
+	//Check if robots.txt is ignored, which site owners may object to
+	if *ignoreRobots {
+		fmt.Fprintln(os.Stderr, "Warning: ignoring robots.txt; crawling pages that site owners excluded may get the crawler blocked")
+	} else if *maxCrawlDelay == 0 {
+		opts.MaxCrawlDelay = -1 // No limit
+	}
+	opts.Workers = *workers
+	opts.MaxPerHost, opts.HostConcurrency, opts.HostMaxFailures = *maxPerHost, *hostConcurrency, *hostMaxFailures
+	opts.HostDelay = *hostDelay
+	opts.SeedFile = *seedFile
+	opts.IgnoreHSTS, opts.PreferHTTPS = !*hsts, *preferHTTPS
+	opts.PageHook, opts.HookTimeout = *pageHook, *hookTimeout
+	opts.Script = *scriptPath
+	opts.WASMExtractors, opts.WASMProcessors = splitList(*wasmExtractors), splitList(*wasmProcessors)
+	opts.Watchdog, opts.WatchdogAbort = *watchdogFactor, *watchdogAbort
+	//Check if the watchdog is disabled
+	if *watchdogFactor == 0 {
+		opts.Watchdog = -1
+	}
+	opts.RecordHeaders = splitList(*headers)
+	opts.RequestIDHeader = *requestIDHeader
+	opts.BotSlowdown = *botSlowdown
+	opts.Retries, opts.RetryBackoff, opts.RetryMaxWait = *retries, *retryBackoff, *retryMaxWait
+	opts.MaintenancePause = *maintenancePause
+	opts.HostRate, opts.HostRates = *hostRate, splitList(*hostRates)
+	opts.RampUp = *rampUp
+	opts.AllowedDomains = splitList(*allowedDomains)
+	for _, prefix := range splitList(*pathPrefixes) {
+		//Check if the prefix is not an absolute path
+		if !strings.HasPrefix(prefix, "/") {
+			fmt.Fprintf(os.Stderr, "Error: -path-prefix %q must start with \"/\"\n", prefix)
+			os.Exit(1)
+		}
+		opts.PathPrefixes = append(opts.PathPrefixes, prefix)
+	}
+	opts.Discover = *discover
+	//Check if pages must match a pattern
+	if *include != "" {
+		//Check if the pattern is malformed
+		if opts.Include, err = regexp.Compile(*include); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -include: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if URL's matching a pattern are skipped
+	if *exclude != "" {
+		//Check if the pattern is malformed
+		if opts.Exclude, err = regexp.Compile(*exclude); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -exclude: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts.CheckExternal, opts.CheckAssets = *checkExternal, *checkAssets
+	opts.CheckFragments, opts.FollowAlternates, opts.CheckAMP = *checkFragments, *followAlternates, *checkAMP
+	opts.MaxRedirects, opts.PlainRedirects, opts.OffHostRedirects = *maxRedirects, !*redirectPreserve, *offHostRedirects
+	//Check if 3xx responses are reported instead of followed
+	if *maxRedirects == 0 {
+		opts.MaxRedirects = -1
+	}
+	var report *crawler.Report
+	var writeCrawlReport func(io.Writer) error
+	reportFile := ""
+	//Check if a crawl report was requested
+	if *reportPath != "" {
+		report = crawler.NewReport(time.Now())
+		//Check if the report format is unsupported
+		if writeCrawlReport, reportFile, err = report.Writer(*reportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Report = report
+	}
+	var patternStats *crawler.PatternReport
+	//Check if statistics per URL template were requested
+	if *patternReportPath != "" {
+		//Check if the configured templates are malformed
+		if patternStats, err = crawler.NewPatternReport(cfg.URLPatterns, *patternVariants); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var cacheStats *crawler.CacheReport
+	//Check if a cacheability report was requested
+	if *cacheReportPath != "" {
+		cacheStats = crawler.NewCacheReport(*cacheMinTTL)
+		opts.RecordHeaders = append(opts.RecordHeaders, crawler.CacheHeaders...)
+	}
+	opts.ChangeHistory = *changeHistory
+	//Check if URL's matching given substrings should be crawled first, which takes precedence over change frequencies
+	if *prioritize != "" {
+		opts.Prioritizer = crawler.SubstringPrioritizer(strings.Split(*prioritize, ","))
+	}
+	//Check if a focused crawl was requested
+	if *keywords != "" {
+		opts.Keywords, opts.MinRelevance = splitList(*keywords), *minRelevance
+	}
+	//Check which verbosity level was requested, the most detailed one wins
+	switch {
+	case *veryVerbose:
+		opts.Verbosity = 2
+	case *verbose:
+		opts.Verbosity = 1
+	case *quiet:
+		opts.Verbosity = -1
+	}
+	//Check if bodies are limited in size
+	if *maxSize != "" {
+		//Check if the limit is malformed
+		if opts.MaxSize, err = crawler.ParseByteSize(*maxSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -max-size: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if large bodies are sampled
+	if *sampleSize != "" {
+		//Check if there is no size limit to sample bodies above
+		if *maxSize == "" {
+			fmt.Fprintln(os.Stderr, "Error: -sample-size needs -max-size")
+			os.Exit(1)
+		}
+		//Check if the sample size is malformed
+		if opts.SampleSize, err = crawler.ParseByteSize(*sampleSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -sample-size: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if a memory ceiling was requested
+	if *maxMemory != "" {
+		//Check if the ceiling is malformed
+		if opts.MaxMemory, err = crawler.ParseByteSize(*maxMemory); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -max-memory: %v\n", err)
+			os.Exit(1)
+		}
+		// The ceiling also becomes the runtime's soft memory limit, which the crawler package leaves alone
+		debug.SetMemoryLimit(opts.MaxMemory)
+	}
+
+	//Check if only a scope preview was requested, which writes no files
+	if *dryRun {
+		c, err := crawler.New(startURL, opts)
+		//Check if the crawler initialization failed
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer c.Close()
+		//Check if the seeds could not be previewed
+		if err := c.DryRun(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	var retryLetters []crawler.DeadLetter
+	//Check if dead-lettered URL's are retried; they are read before -dlq may truncate the same file
+	if retryFile != "" {
+		//Check if the dead-letter file could not be read
+		if retryLetters, err = crawler.ReadDeadLetters(retryFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts.DeadLetters, opts.Edges = *dlqPath, *edgesPath
+	var buffered *crawler.ResultBuffer
+	//Check if output has to be buffered for sorting
+	if *sortMode != "" {
+		buffered = crawler.NewResultBuffer(*sortMode)
+		opts.Buffer = buffered
+	}
+	//Check if crawl progress should be logged for crash recovery
+	if *walPath != "" {
+		//Check if the log is used outside a crawl
+		if retryFile != "" || *validateList != "" {
+			fmt.Fprintln(os.Stderr, "Error: -wal cannot be combined with -validate or retry-dlq")
+			os.Exit(1)
+		}
+		opts.WAL = *walPath
+	}
+	//Check if a crawl is resumed without a state file to resume from
+	if *resume && *statePath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -resume needs -state-file")
+		os.Exit(1)
+	}
+	//Check if crawl progress should be checkpointed for -resume
+	if *statePath != "" {
+		//Check if the state is kept outside a crawl, or next to a write-ahead log recovering the same progress
+		if retryFile != "" || *validateList != "" || *walPath != "" {
+			fmt.Fprintln(os.Stderr, "Error: -state-file cannot be combined with -wal, -validate or retry-dlq")
+			os.Exit(1)
+		}
+		//Check if the checkpoint interval is not positive
+		if *stateInterval <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: -state-interval must be positive")
+			os.Exit(1)
+		}
+		//Check if a new crawl replaces the state of an unfinished one
+		if _, err := os.Stat(*statePath); err == nil && !*resume {
+			fmt.Fprintf(os.Stderr, "Warning: %s holds an unfinished crawl, which this crawl replaces; use -resume to continue it\n", *statePath)
+		}
+		opts.StateFile, opts.StateInterval, opts.Resume = *statePath, *stateInterval, *resume
+	}
+	var archive *crawler.Archive
+	//Check if results and bodies are archived
+	if *archivePath != "" {
+		//Check if the archive could not be created
+		if archive, err = crawler.CreateArchive(*archivePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Archive = archive
+	}
+
+	//Initialize the crawler
+	c, err := crawler.New(startURL, opts)
+	//Check if the crawler initialization failed
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer c.Close()
+	started := time.Now()
+
+	handleControlSignals(c, *statusFile)
+	//Check if the crawl is controlled through a Unix socket
+	if *controlSocket != "" {
+		listener, err := listenControl(*controlSocket)
+		//Check if the socket could not be created
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer listener.Close()
+		go c.ServeControl(listener)
+	}
+	ctx := interruptContext()
+	runDone := make(chan error, 1) //Receives the outcome of the crawl once its channels are closed
+	//Check which mode the crawler runs in
+	if retryFile != "" {
+		go func() {
+			runDone <- c.RetryDeadLetters(ctx, retryLetters)
+		}()
+	} else if *validateList != "" {
+		urls, err := readURLList(*validateList)
+		//Check if the URL list could not be read
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		go func() {
+			runDone <- c.Validate(ctx, urls)
+		}()
+	} else {
+		// Start crawling
+		go func() {
+			runDone <- c.Run(ctx)
+		}()
+	}
+
+	// Print results, buffering them first when sorted output is requested, and collect errors
+	// while doing so, so neither can fill up and stall the workers
+	var aggregatedErrors []error
+	crawled := 0
+	classes := make(map[string]int)
+	var failures []string //Failed URL's for the run history
+	for result, err := range c.Results() {
+		//Check if an error arrived instead of a result
+		if err != nil {
+			aggregatedErrors = append(aggregatedErrors, err)
+			continue
+		}
+		crawled++
+		classes[result.Class]++
+		//Check if the failed URL is recorded in the run history
+		if history != nil && result.Class == crawler.ClassFailure {
+			failures = append(failures, result.URL)
+		}
+		//Check if the result is archived
+		if archive != nil {
+			//Check if the result could not be archived
+			if err := archive.Add(&result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		//Check if the result is counted for the pattern report
+		if patternStats != nil {
+			patternStats.Add(result)
+		}
+		//Check if the result is analyzed for the cache report
+		if cacheStats != nil {
+			cacheStats.Add(result)
+		}
+		//Check if the result counts towards the simulated coverage
+		if opts.Simulation != nil {
+			opts.Simulation.Add(result)
+		}
+		//Check if the result is collected for the crawl report
+		if report != nil {
+			report.Add(result)
+		}
+		//Check if results are suppressed in quiet mode or by the filter
+		if opts.Verbosity < 0 || !filter(result) {
+			continue
+		}
+		//Check if output has to be sorted before printing
+		if buffered != nil {
+			buffered.Add(result)
+			continue
+		}
+		printResult(result)
+	}
+	//Check if buffered results have to be printed
+	if buffered != nil {
+		//Check if spilled results could not be read back
+		if err := buffered.Each(printResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	//Check if the archive has to be completed
+	if archive != nil {
+		//Check if the archive could not be completed
+		if err := archive.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	//Check if the site tree should be printed
+	if tree != nil {
+		tree.write(os.Stdout)
+	}
+	//Check if the crawl report should be written
+	if report != nil {
+		//Check if the report could not be written
+		if err := writeReport(reportFile, writeCrawlReport); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if the pattern report should be written
+	if patternStats != nil {
+		//Check if the report could not be written
+		if err := writeReport(*patternReportPath, patternStats.Print); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if the simulated coverage should be printed
+	if opts.Simulation != nil {
+		//Check if the coverage could not be written
+		if err := writeReport("-", opts.Simulation.Print); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	//Check if the cache report should be written
+	if cacheStats != nil {
+		//Check if the report could not be written
+		if err := writeReport(*cacheReportPath, cacheStats.Print); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	//Check if the crawl was aborted by a worker failure
+	if err := <-runDone; err != nil {
+		fmt.Fprintf(os.Stderr, "Error: crawl aborted: %v\n", err)
+		os.Exit(1)
+	}
+	//Check if any errors were collected
+	if len(aggregatedErrors) > 0 {
+		fmt.Fprintf(os.Stderr, "\nAggregated Errors:\n")
+		for _, err := range aggregatedErrors {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	interrupted := ctx.Err() != nil
+	//Check if a summary should be printed for quiet, verbose or interrupted runs
+	if opts.Verbosity != 0 || interrupted {
+		fmt.Fprintf(os.Stderr, "\nCrawled %d pages (%d success, %d warning, %d failure) with %d errors in %s\n",
+			crawled, classes[crawler.ClassSuccess], classes[crawler.ClassWarning], classes[crawler.ClassFailure], len(aggregatedErrors), time.Since(started).Round(time.Millisecond))
+		//Check if responses came through the HTTP cache
+		if *httpCacheDir != "" {
+			fresh, revalidated, downloaded := c.HTTPCacheStats()
+			fmt.Fprintf(os.Stderr, "HTTP cache: %d fresh, %d revalidated, %d downloaded\n", fresh, revalidated, downloaded)
+		}
+	}
+	//Check if the run is recorded in the history
+	if history != nil {
+		sort.Strings(failures)
+		run := runSummary{ID: c.RunID(), Seeds: c.Seeds(), Profile: *profile, Started: started, Duration: time.Since(started),
+			Pages: crawled, Success: classes[crawler.ClassSuccess], Warning: classes[crawler.ClassWarning], Failure: classes[crawler.ClassFailure],
+			Errors: len(aggregatedErrors), Failures: failures}
+		//Check if the run could not be saved
+		if err := history.save(run); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		removed, err := history.prune(*historyKeep, *historyDays)
+		//Check if old runs could not be removed
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		//Check if the saved run is logged
+		if opts.Verbosity >= 1 {
+			fmt.Fprintf(os.Stderr, "%s run %s saved to %s, %d old runs removed\n", time.Now().Format("15:04:05.000"), run.ID, *historyDir, removed)
+		}
+	}
+	//Check if the crawl was interrupted, which exits like a process killed by SIGINT
+	if interrupted {
+		os.Exit(130)
+	}
+	//Check if any page was classified as a failure, which fails CI runs
+	if classes[crawler.ClassFailure] > 0 {
+		os.Exit(2)
+	}
+}
+
+// splitList splits a comma-separated flag value into its trimmed entries, dropping blanks
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		//Check if the entry is not blank, e.g. from a trailing comma
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
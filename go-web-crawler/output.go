@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+
+	"go-web-crawler/go-web-crawler/crawler"
+)
+
+// newResultPrinter returns a function that writes one result per line to w in the given format
+func newResultPrinter(w io.Writer, format, tmpl string) (func(crawler.Result), error) {
+	switch format {
+	case "text":
+		return func(result crawler.Result) {
+			//Check if the URL only redirected to a page listed already
+			if result.AliasOf != "" {
+				return
+			}
+			//Check if the page was fetched from another URL after redirects
+			if result.FinalURL != "" && result.FinalURL != result.URL {
+				fmt.Fprintf(w, "%s -> %s\n", result.URL, result.FinalURL)
+				return
+			}
+			fmt.Fprintln(w, result.URL)
+		}, nil
+	case "template":
+		//Check if a template was provided
+		if tmpl == "" {
+			return nil, fmt.Errorf("-format template requires -template")
+		}
+		t, err := template.New("result").Parse(tmpl)
+		//Check if the template is invalid
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return func(result crawler.Result) {
+			//Check if the template failed for this result
+			if err := t.Execute(w, result); err != nil {
+				fmt.Fprintf(os.Stderr, "template error for %s: %v\n", result.URL, err)
+			}
+			fmt.Fprintln(w)
+		}, nil
+	case "json", "jsonl":
+		return crawler.NewJSONPrinter(w), nil
+	default:
+		return nil, fmt.Errorf("invalid format %q (expected \"text\", \"template\", \"json\" or \"jsonl\")", format)
+	}
+}
+
+// writeReport writes a report to the file at path, or to stdout for "-"
+func writeReport(path string, write func(io.Writer) error) error {
+	//Check if the report goes to stdout
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	//Check if the report file could not be created
+	if err != nil {
+		return fmt.Errorf("error creating report: %w", err)
+	}
+	//Check if writing the report failed
+	if err := write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runSummary is the record of one crawl kept in the -history directory
+type runSummary struct {
+	ID       string        `json:"id"`       //Run ID, the prefix of the run's request ID's
+	Seeds    []string      `json:"seeds"`    //Seed URL's of the run
+	Profile  string        `json:"profile"`  //Config profile the run used, empty for none
+	Started  time.Time     `json:"started"`  //When the crawl started
+	Duration time.Duration `json:"duration"` //How long the crawl took, in nanoseconds
+	Pages    int           `json:"pages"`    //Results reported
+	Success  int           `json:"success"`  //Results classified as success
+	Warning  int           `json:"warning"`  //Results classified as warning
+	Failure  int           `json:"failure"`  //Results classified as failure
+	Errors   int           `json:"errors"`   //Errors collected during the run
+	Failures []string      `json:"failures"` //URL's classified as failures, sorted
+}
+
+// runHistory is a directory holding one JSON file per crawl, named by start time and run ID
+type runHistory struct {
+	dir string //Directory of the summaries
+}
+
+// openRunHistory creates the history directory if needed
+func openRunHistory(dir string) (*runHistory, error) {
+	//Check if the directory could not be created
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating run history: %w", err)
+	}
+	return &runHistory{dir: dir}, nil
+}
+
+// fileName returns the name a run's summary is stored under, which sorts by start time
+func (h *runHistory) fileName(run runSummary) string {
+	return filepath.Join(h.dir, run.Started.UTC().Format("20060102T150405Z")+"-"+run.ID+".json")
+}
+
+// save writes a run's summary
+func (h *runHistory) save(run runSummary) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	//Check if the summary could not be encoded
+	if err != nil {
+		return fmt.Errorf("error encoding run summary: %w", err)
+	}
+	//Check if the summary could not be written
+	if err := os.WriteFile(h.fileName(run), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error saving run summary: %w", err)
+	}
+	return nil
+}
+
+// load returns the summaries of all runs, oldest first
+func (h *runHistory) load() ([]runSummary, error) {
+	names, err := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	//Check if the directory could not be listed
+	if err != nil {
+		return nil, fmt.Errorf("error reading run history: %w", err)
+	}
+	runs := make([]runSummary, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		//Check if the summary could not be read
+		if err != nil {
+			return nil, fmt.Errorf("error reading run history: %w", err)
+		}
+		var run runSummary
+		//Check if the file is not a run summary
+		if err := json.Unmarshal(data, &run); err != nil || run.ID == "" {
+			return nil, fmt.Errorf("%s is not a run summary", name)
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	return runs, nil
+}
+
+// prune removes the runs beyond the newest keepRuns and those older than keepDays days; 0 keeps
+// any number and any age. It returns how many runs were removed.
+func (h *runHistory) prune(keepRuns, keepDays int) (int, error) {
+	runs, err := h.load()
+	//Check if the history could not be read
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+	removed := 0
+	for i, run := range runs {
+		expired := keepDays > 0 && run.Started.Before(cutoff)
+		surplus := keepRuns > 0 && i < len(runs)-keepRuns
+		//Check if the run is kept
+		if !expired && !surplus {
+			continue
+		}
+		//Check if the summary could not be removed
+		if err := os.Remove(h.fileName(run)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("error pruning run history: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// findRun returns the run with the given ID or ID prefix
+func findRun(runs []runSummary, id string) (runSummary, error) {
+	var found []runSummary
+	for _, run := range runs {
+		//Check if the run's ID starts with the given one
+		if strings.HasPrefix(run.ID, id) {
+			found = append(found, run)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return runSummary{}, fmt.Errorf("no run %q in the history", id)
+	case 1:
+		return found[0], nil
+	default:
+		return runSummary{}, fmt.Errorf("run ID %q is ambiguous", id)
+	}
+}
+
+// compareRuns returns the two runs named by a "-history-compare" value: two run ID's separated by
+// a comma, or "last" for the latest run and the one before
+func compareRuns(runs []runSummary, value string) (runSummary, runSummary, error) {
+	//Check if the latest two runs are compared
+	if value == "last" {
+		//Check if there are fewer than two runs
+		if len(runs) < 2 {
+			return runSummary{}, runSummary{}, fmt.Errorf("the history holds %d runs, comparing needs two", len(runs))
+		}
+		return runs[len(runs)-2], runs[len(runs)-1], nil
+	}
+	first, second, ok := strings.Cut(value, ",")
+	//Check if two run ID's were given
+	if !ok {
+		return runSummary{}, runSummary{}, fmt.Errorf("-history-compare needs two run ID's like 'a1b2,c3d4' or \"last\"")
+	}
+	a, err := findRun(runs, strings.TrimSpace(first))
+	//Check if the first run is unknown
+	if err != nil {
+		return runSummary{}, runSummary{}, err
+	}
+	b, err := findRun(runs, strings.TrimSpace(second))
+	return a, b, err
+}
+
+// writeRunList prints one line per run, oldest first
+func writeRunList(w io.Writer, runs []runSummary) error {
+	fmt.Fprintf(w, "%-8s %-20s %10s %7s %7s %7s %7s %7s  %s\n", "RUN", "STARTED", "DURATION", "PAGES", "SUCCESS", "WARNING", "FAILURE", "ERRORS", "SEEDS")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%-8s %-20s %10s %7d %7d %7d %7d %7d  %s\n", run.ID, run.Started.Local().Format("2006-01-02 15:04:05"),
+			run.Duration.Round(time.Second), run.Pages, run.Success, run.Warning, run.Failure, run.Errors, strings.Join(run.Seeds, " "))
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writeRunComparison prints the counts of two runs with their differences, and the URL's that
+// started or stopped failing between them
+func writeRunComparison(w io.Writer, a, b runSummary) error {
+	fmt.Fprintf(w, "Comparing run %s (%s) with run %s (%s)\n\n", a.ID, a.Started.Local().Format("2006-01-02 15:04"), b.ID, b.Started.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "%-10s %10s %10s %10s\n", "", a.ID, b.ID, "CHANGE")
+	for _, row := range []struct {
+		name string
+		a, b int
+	}{
+		{"pages", a.Pages, b.Pages},
+		{"success", a.Success, b.Success},
+		{"warning", a.Warning, b.Warning},
+		{"failure", a.Failure, b.Failure},
+		{"errors", a.Errors, b.Errors},
+	} {
+		fmt.Fprintf(w, "%-10s %10d %10d %+10d\n", row.name, row.a, row.b, row.b-row.a)
+	}
+	fmt.Fprintf(w, "%-10s %10s %10s %10s\n", "duration", a.Duration.Round(time.Second), b.Duration.Round(time.Second), (b.Duration - a.Duration).Round(time.Second))
+
+	before := make(map[string]bool, len(a.Failures))
+	for _, link := range a.Failures {
+		before[link] = true
+	}
+	after := make(map[string]bool, len(b.Failures))
+	for _, link := range b.Failures {
+		after[link] = true
+	}
+	var started, fixed []string
+	for _, link := range b.Failures {
+		//Check if the URL did not fail in the earlier run
+		if !before[link] {
+			started = append(started, link)
+		}
+	}
+	for _, link := range a.Failures {
+		//Check if the URL no longer fails in the later run
+		if !after[link] {
+			fixed = append(fixed, link)
+		}
+	}
+	fmt.Fprintf(w, "\nNew failures (%d):\n", len(started))
+	for _, link := range started {
+		fmt.Fprintf(w, "  %s\n", link)
+	}
+	fmt.Fprintf(w, "\nNo longer failing (%d):\n", len(fixed))
+	for _, link := range fixed {
+		fmt.Fprintf(w, "  %s\n", link)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
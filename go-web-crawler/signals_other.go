@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "go-web-crawler/go-web-crawler/crawler"
+
+// handleControlSignals is a no-op on platforms without SIGUSR1, SIGUSR2 and SIGQUIT
+func handleControlSignals(c *crawler.Crawler, statusFile string) {}
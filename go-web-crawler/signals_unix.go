@@ -0,0 +1,52 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-web-crawler/go-web-crawler/crawler"
+)
+
+// handleControlSignals pauses the crawl on SIGUSR1, resumes it on SIGUSR2 and dumps its status on
+// SIGQUIT, to statusFile or to stderr if it is empty
+func handleControlSignals(c *crawler.Crawler, statusFile string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGQUIT)
+	go func() {
+		for sig := range signals {
+			//Check which control signal was received
+			switch sig {
+			case syscall.SIGUSR1:
+				c.Pause()
+			case syscall.SIGUSR2:
+				c.Resume()
+			case syscall.SIGQUIT:
+				dumpStatus(c, statusFile)
+			}
+		}
+	}()
+}
+
+// dumpStatus writes a status snapshot of the crawl to statusFile, or to stderr if it is empty
+func dumpStatus(c *crawler.Crawler, statusFile string) {
+	//Check if the status should go to stderr
+	if statusFile == "" {
+		c.WriteStatus(os.Stderr)
+		return
+	}
+	file, err := os.Create(statusFile)
+	//Check if the status file could not be created
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing status: %v\n", err)
+		return
+	}
+	defer file.Close()
+	//Check if writing the status failed
+	if err := c.WriteStatus(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing status: %v\n", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go-web-crawler/go-web-crawler/crawler"
+)
+
+// treeNode is a path segment in the site tree
+type treeNode struct {
+	children map[string]*treeNode //Child segments by name
+	pages    int                  //Pages at or below this node
+}
+
+// siteTree groups crawled URL's by host and path segment for -format tree
+type siteTree struct {
+	hosts map[string]*treeNode //Root nodes by scheme and host
+}
+
+// newSiteTree creates an empty tree
+func newSiteTree() *siteTree {
+	return &siteTree{hosts: make(map[string]*treeNode)}
+}
+
+// add places a result's URL in the tree
+func (t *siteTree) add(result crawler.Result) {
+	root, segments := result.URL, []string(nil)
+	//Check if the URL can be split into a host and path segments
+	if u, err := url.Parse(result.URL); err == nil {
+		root = u.Scheme + "://" + u.Host
+		path := strings.TrimPrefix(u.EscapedPath(), "/")
+		//Check if the URL has a path below the root
+		if path != "" {
+			segments = strings.SplitAfter(path, "/")
+			//Check if the path ends in a directory, which leaves an empty last segment
+			if segments[len(segments)-1] == "" {
+				segments = segments[:len(segments)-1]
+			}
+		}
+		//Check if the query distinguishes the page from others with the same path
+		if u.RawQuery != "" {
+			//Check if the query belongs to the root page
+			if len(segments) == 0 {
+				segments = []string{""}
+			}
+			segments[len(segments)-1] += "?" + u.RawQuery
+		}
+	}
+	node, ok := t.hosts[root]
+	//Check if this is the first page of the host
+	if !ok {
+		node = &treeNode{}
+		t.hosts[root] = node
+	}
+	node.pages++
+	for _, segment := range segments {
+		//Check if the node has no children yet
+		if node.children == nil {
+			node.children = make(map[string]*treeNode)
+		}
+		child, ok := node.children[segment]
+		//Check if the segment is new
+		if !ok {
+			child = &treeNode{}
+			node.children[segment] = child
+		}
+		child.pages++
+		node = child
+	}
+}
+
+// write prints the tree with ASCII branches and a page count after every node with children
+func (t *siteTree) write(w io.Writer) error {
+	hosts := make([]string, 0, len(t.hosts))
+	for host := range t.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		//Check if the host's root could not be written
+		if _, err := fmt.Fprintln(w, host+"/"+t.hosts[host].label()); err != nil {
+			return err
+		}
+		//Check if the host's pages could not be written
+		if err := t.hosts[host].writeChildren(w, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// label returns the page count printed after the name of a node with children
+func (n *treeNode) label() string {
+	//Check if the node is a leaf
+	if len(n.children) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d)", n.pages)
+}
+
+// writeChildren prints the children of a node sorted by name, each line prefixed by indent
+func (n *treeNode) writeChildren(w io.Writer, indent string) error {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		branch, next := "|-- ", "|   "
+		//Check if this is the last child
+		if i == len(names)-1 {
+			branch, next = "`-- ", "    "
+		}
+		child := n.children[name]
+		//Check if the line could not be written
+		if _, err := fmt.Fprintln(w, indent+branch+name+child.label()); err != nil {
+			return err
+		}
+		//Check if the subtree could not be written
+		if err := child.writeChildren(w, indent+next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readURLList reads one URL per line from a file, or stdin for "-", skipping blank lines and # comments
+func readURLList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	//Check if the list should be read from a file
+	if path != "-" {
+		file, err := os.Open(path)
+		//Check if the file could not be opened
+		if err != nil {
+			return nil, fmt.Errorf("error opening URL list: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		//Check if the line holds a URL
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	//Check if reading the list failed
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading URL list: %w", err)
+	}
+	return urls, nil
+}
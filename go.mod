@@ -7,3 +7,15 @@ toolchain go1.23.12
 require golang.org/x/net v0.43.0
 
 require golang.org/x/time v0.12.0
+
+require golang.org/x/sync v0.16.0
+
+require golang.org/x/oauth2 v0.30.0
+
+require golang.org/x/text v0.28.0
+
+require github.com/yuin/gopher-lua v1.1.2
+
+require github.com/tetratelabs/wazero v1.9.0
+
+require github.com/klauspost/compress v1.18.0